@@ -10,6 +10,7 @@ import (
 
 	"github.com/joelovien/go-xlsx-api/internal/api"
 	"github.com/joelovien/go-xlsx-api/internal/config"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
 	"github.com/rs/zerolog"
 )
 
@@ -22,10 +23,16 @@ func main() {
 		Str("port", cfg.Port).
 		Int64("max_upload_mb", cfg.MaxUploadSizeMB).
 		Int("rate_limit", cfg.RateLimit).
+		Str("storage", cfg.StorageBackend).
 		Bool("api_key_enabled", cfg.APIKey != "").
 		Msg("Starting server")
 
-	router := api.NewRouter(cfg, &logger)
+	store, err := storage.Open(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Str("backend", cfg.StorageBackend).Msg("Failed to open storage")
+	}
+
+	router := api.NewRouter(cfg, store, &logger)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -57,9 +64,14 @@ func main() {
 	// Attempt graceful shutdown
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error().Err(err).Msg("Server forced to shutdown")
+		store.Close()
 		os.Exit(1)
 	}
 
+	if err := store.Close(); err != nil {
+		logger.Error().Err(err).Msg("Failed to close storage")
+	}
+
 	logger.Info().Msg("Server exited gracefully")
 }
 
@@ -3,7 +3,6 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"strconv"
 
 	"github.com/joelovien/go-xlsx-api/internal/models"
 	"github.com/joelovien/go-xlsx-api/internal/storage"
@@ -11,11 +10,11 @@ import (
 )
 
 type ListHandler struct {
-	storage *storage.MemoryStorage
+	storage storage.Store
 	logger  *zerolog.Logger
 }
 
-func NewListHandler(storage *storage.MemoryStorage, logger *zerolog.Logger) *ListHandler {
+func NewListHandler(storage storage.Store, logger *zerolog.Logger) *ListHandler {
 	return &ListHandler{
 		storage: storage,
 		logger:  logger,
@@ -23,33 +22,10 @@ func NewListHandler(storage *storage.MemoryStorage, logger *zerolog.Logger) *Lis
 }
 
 func (h *ListHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 10
-	offset := 0
-
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err != nil || parsedLimit < 0 {
-			h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid limit parameter")
-			return
-		}
-		limit = parsedLimit
-	}
-
-	if offsetStr != "" {
-		parsedOffset, err := strconv.Atoi(offsetStr)
-		if err != nil || parsedOffset < 0 {
-			h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid offset parameter")
-			return
-		}
-		offset = parsedOffset
-	}
-
-	if limit > 1000 {
-		limit = 1000
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
 	}
 
 	records, total, err := h.storage.List(limit, offset)
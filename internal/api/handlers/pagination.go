@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 1000
+)
+
+// invalidParamError names the query parameter that failed to parse; its
+// message is returned to clients as is.
+type invalidParamError string
+
+func (e invalidParamError) Error() string {
+	return "Invalid " + string(e) + " parameter"
+}
+
+// parsePagination reads the limit and offset query parameters shared by the
+// listing endpoints.
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit = defaultPageLimit
+	offset = 0
+
+	if limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit < 0 {
+			return 0, 0, invalidParamError("limit")
+		}
+		limit = parsedLimit
+	}
+
+	if offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			return 0, 0, invalidParamError("offset")
+		}
+		offset = parsedOffset
+	}
+
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/rs/zerolog"
+)
+
+// maxProfileBytes bounds the size of a profile definition.
+const maxProfileBytes = 1 << 20
+
+type ProfileHandler struct {
+	registry *mapping.Registry
+	logger   *zerolog.Logger
+}
+
+func NewProfileHandler(registry *mapping.Registry, logger *zerolog.Logger) *ProfileHandler {
+	return &ProfileHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+func (h *ProfileHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var definition mapping.Profile
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxProfileBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definition); err != nil {
+		h.writeError(w, http.StatusBadRequest, "bad_request", "Invalid profile definition: "+err.Error())
+		return
+	}
+
+	registered, err := h.registry.Register(definition)
+	if errors.Is(err, mapping.ErrExists) {
+		h.writeError(w, http.StatusConflict, "conflict", "Profile "+definition.Name+" already exists")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_profile", err.Error())
+		return
+	}
+
+	h.logger.Info().Str("profile", registered.Name).Int("fields", len(registered.Fields)).Msg("Profile registered")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(registered)
+}
+
+// ListProfilesResponse is the body of GET /v1/profiles.
+type ListProfilesResponse struct {
+	Profiles []*mapping.Profile `json:"profiles"`
+}
+
+func (h *ProfileHandler) List(w http.ResponseWriter, r *http.Request) {
+	response := ListProfilesResponse{
+		Profiles: h.registry.List(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	p, err := h.registry.Get(name)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "not_found", "Profile not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *ProfileHandler) writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
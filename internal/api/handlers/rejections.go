@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+type RejectionsHandler struct {
+	storage storage.Store
+	logger  *zerolog.Logger
+}
+
+func NewRejectionsHandler(storage storage.Store, logger *zerolog.Logger) *RejectionsHandler {
+	return &RejectionsHandler{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (h *RejectionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "id")
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+
+	rejections, total, err := h.storage.ListRejections(uploadID, limit, offset)
+	if errors.Is(err, storage.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "not_found", "Upload not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to list rejections")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve row errors")
+		return
+	}
+
+	response := models.ListRowErrorsResponse{
+		UploadID: uploadID,
+		Errors:   rejections,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *RejectionsHandler) writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
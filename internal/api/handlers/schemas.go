@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/rs/zerolog"
+)
+
+// maxSchemaBytes bounds the size of a schema definition.
+const maxSchemaBytes = 1 << 20
+
+type SchemaHandler struct {
+	registry *schema.Registry
+	logger   *zerolog.Logger
+}
+
+func NewSchemaHandler(registry *schema.Registry, logger *zerolog.Logger) *SchemaHandler {
+	return &SchemaHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+func (h *SchemaHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var definition schema.Schema
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSchemaBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definition); err != nil {
+		h.writeError(w, http.StatusBadRequest, "bad_request", "Invalid schema definition: "+err.Error())
+		return
+	}
+
+	registered, err := h.registry.Register(definition)
+	if errors.Is(err, schema.ErrExists) {
+		h.writeError(w, http.StatusConflict, "conflict", "Schema "+definition.Name+" already exists")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_schema", err.Error())
+		return
+	}
+
+	h.logger.Info().Str("schema", registered.Name).Int("columns", len(registered.Columns)).Msg("Schema registered")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(registered)
+}
+
+// ListSchemasResponse is the body of GET /v1/schemas.
+type ListSchemasResponse struct {
+	Schemas []*schema.Schema `json:"schemas"`
+}
+
+func (h *SchemaHandler) List(w http.ResponseWriter, r *http.Request) {
+	response := ListSchemasResponse{
+		Schemas: h.registry.List(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *SchemaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	s, err := h.registry.Get(name)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "not_found", "Schema not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s)
+}
+
+func (h *SchemaHandler) writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+type TransactionsHandler struct {
+	storage storage.Store
+	logger  *zerolog.Logger
+}
+
+func NewTransactionsHandler(storage storage.Store, logger *zerolog.Logger) *TransactionsHandler {
+	return &TransactionsHandler{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// Handle lists normalized transactions, optionally those of one upload.
+func (h *TransactionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+	uploadID := r.URL.Query().Get("uploadId")
+
+	transactions, total, err := h.storage.ListTransactions(uploadID, limit, offset)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list transactions")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve transactions")
+		return
+	}
+
+	response := models.ListTransactionsResponse{
+		Transactions: transactions,
+		Total:        total,
+		Limit:        limit,
+		Offset:       offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *TransactionsHandler) writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
@@ -2,29 +2,45 @@ package handlers
 
 import (
 	"encoding/json"
-	"io"
+	"errors"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/joelovien/go-xlsx-api/internal/ledger"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
 	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
 	"github.com/joelovien/go-xlsx-api/internal/storage"
 	"github.com/joelovien/go-xlsx-api/internal/xlsx"
 	"github.com/rs/zerolog"
 )
 
+const (
+	multipartMemoryBytes = 1 << 20
+
+	// errorPreviewLimit caps the row errors returned with an upload; the
+	// full list is available from GET /v1/uploads/{id}/errors.
+	errorPreviewLimit = 10
+)
+
 type UploadHandler struct {
-	storage        *storage.MemoryStorage
+	storage        storage.Store
 	parser         *xlsx.Parser
+	schemas        *schema.Registry
+	profiles       *mapping.Registry
 	maxUploadBytes int64
 	logger         *zerolog.Logger
 }
 
-func NewUploadHandler(storage *storage.MemoryStorage, parser *xlsx.Parser, maxUploadMB int64, logger *zerolog.Logger) *UploadHandler {
+func NewUploadHandler(storage storage.Store, parser *xlsx.Parser, schemas *schema.Registry, profiles *mapping.Registry, maxUploadMB int64, logger *zerolog.Logger) *UploadHandler {
 	return &UploadHandler{
 		storage:        storage,
 		parser:         parser,
+		schemas:        schemas,
+		profiles:       profiles,
 		maxUploadBytes: maxUploadMB * 1024 * 1024,
 		logger:         logger,
 	}
@@ -35,12 +51,15 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadBytes)
 
-	err := r.ParseMultipartForm(h.maxUploadBytes)
+	// Keep only small uploads in memory; larger ones are spooled to a
+	// temporary file that the parser reads from directly.
+	err := r.ParseMultipartForm(multipartMemoryBytes)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to parse multipart form")
 		h.writeError(w, http.StatusBadRequest, "bad_request", "File size exceeds maximum allowed size")
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -50,20 +69,121 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext != ".xlsx" {
+	format, ok := xlsx.FormatFromFilename(header.Filename)
+	if !ok {
+		ext := strings.ToLower(filepath.Ext(header.Filename))
 		h.logger.Warn().Str("filename", header.Filename).Str("ext", ext).Msg("Invalid file extension")
-		h.writeError(w, http.StatusBadRequest, "invalid_file_type", "Only .xlsx files are accepted")
+		h.writeError(w, http.StatusBadRequest, "invalid_file_type", "Only .xlsx, .xls, .ods, .csv and .tsv files are accepted")
 		return
 	}
 
 	contentType := header.Header.Get("Content-Type")
-	if contentType != "" && !strings.Contains(contentType, "spreadsheet") && !strings.Contains(contentType, "excel") && !strings.Contains(contentType, "octet-stream") {
+	if contentType != "" && !acceptedContentType(contentType) {
 		h.logger.Warn().Str("content_type", contentType).Msg("Invalid content type")
-		h.writeError(w, http.StatusBadRequest, "invalid_content_type", "Invalid content type for XLSX file")
+		h.writeError(w, http.StatusBadRequest, "invalid_content_type", "Invalid content type for uploaded file")
+		return
+	}
+
+	sheets, err := xlsx.ParseSheetSelector(r.FormValue("sheets"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid sheets parameter: "+err.Error())
+		return
+	}
+
+	headerRow, err := xlsx.ParseHeaderRow(r.FormValue("headerRow"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid headerRow parameter: "+err.Error())
+		return
+	}
+
+	formulas, err := xlsx.ParseFormulaMode(r.FormValue("formulas"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid formulas parameter: "+err.Error())
+		return
+	}
+	if formulas != xlsx.FormulasCached && format != xlsx.FormatXLSX {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid formulas parameter: formulas can only be read from .xlsx files")
+		return
+	}
+
+	headerRows, err := xlsx.ParseHeaderRows(r.FormValue("headerRows"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid headerRows parameter: "+err.Error())
+		return
+	}
+
+	mergedCells, err := xlsx.ParseMergePolicy(r.FormValue("mergedCells"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid mergedCells parameter: "+err.Error())
+		return
+	}
+
+	values, err := xlsx.ParseValueMode(r.FormValue("values"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid values parameter: "+err.Error())
+		return
+	}
+
+	hidden, err := xlsx.ParseHiddenPolicy(r.FormValue("hidden"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid hidden parameter: "+err.Error())
+		return
+	}
+
+	meta, err := xlsx.ParseMetaFlag(r.FormValue("meta"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid meta parameter: "+err.Error())
+		return
+	}
+
+	duplicateHeaders, err := xlsx.ParseDuplicatePolicy(r.FormValue("duplicateHeaders"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid duplicateHeaders parameter: "+err.Error())
+		return
+	}
+
+	blankHeaders, err := xlsx.ParseBlankPolicy(r.FormValue("blankHeaders"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid blankHeaders parameter: "+err.Error())
+		return
+	}
+
+	normalize, err := xlsx.ParseNormalizeMode(r.FormValue("normalize"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid normalize parameter: "+err.Error())
+		return
+	}
+
+	locale, err := ledger.ParseLocale(r.FormValue("locale"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid locale parameter: "+err.Error())
+		return
+	}
+
+	reconcile, err := xlsx.ParseReconcilePolicy(r.FormValue("reconcile"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid reconcile parameter: "+err.Error())
 		return
 	}
 
+	var profile *mapping.Profile
+	if name := strings.TrimSpace(r.FormValue("profile")); name != "" {
+		profile, err = h.profiles.Get(name)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid_profile", "Unknown profile: "+name)
+			return
+		}
+	}
+
+	var uploadSchema *schema.Schema
+	if name := strings.TrimSpace(r.FormValue("schema")); name != "" {
+		uploadSchema, err = h.schemas.Get(name)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid_schema", "Unknown schema: "+name)
+			return
+		}
+	}
+
 	uploadID := uuid.New().String()
 
 	h.logger.Info().
@@ -72,52 +192,131 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		Int64("size", header.Size).
 		Msg("Processing file upload")
 
-
-	fileBytes, err := io.ReadAll(file)
+	// Records, transactions and rejections go to storage in batches while
+	// the file is parsed; the rest of the upload follows once parsing
+	// succeeded, and until then readers see none of it. The first rejections
+	// are kept for the response.
+	writer, err := h.storage.BeginUpload(ctx, uploadID)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to read file")
-		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to read uploaded file")
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to begin upload")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to store upload")
 		return
 	}
+	defer writer.Abort()
 
-	reader := strings.NewReader(string(fileBytes))
+	preview := make([]models.RowError, 0, errorPreviewLimit)
+	rejectionSink := func(rejections []models.RowError) error {
+		if n := errorPreviewLimit - len(preview); n > 0 {
+			preview = append(preview, rejections[:min(n, len(rejections))]...)
+		}
+		return writer.WriteRejections(rejections)
+	}
 
-	result, err := h.parser.Parse(ctx, reader, uploadID)
+	result, err := h.parser.ParseWithOptions(ctx, file, uploadID, xlsx.Options{
+		Format:           format,
+		Password:         r.FormValue("password"),
+		Sheets:           sheets,
+		HeaderRow:        headerRow,
+		HeaderRows:       headerRows,
+		MergedCells:      mergedCells,
+		Values:           values,
+		Formulas:         formulas,
+		Hidden:           hidden,
+		Meta:             meta,
+		DuplicateHeaders: duplicateHeaders,
+		BlankHeaders:     blankHeaders,
+		Profile:          profile,
+		Schema:           uploadSchema,
+		Normalize:        normalize,
+		Locale:           locale,
+		Reconcile:        reconcile,
+		RecordSink:       writer.WriteRecords,
+		TransactionSink:  writer.WriteTransactions,
+		RejectionSink:    rejectionSink,
+	})
 	if err != nil {
-		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to parse XLSX file")
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to parse uploaded file")
 
 		errMsg := err.Error()
-		if strings.Contains(errMsg, "no sheets") {
-			h.writeError(w, http.StatusBadRequest, "invalid_file", "XLSX file has no sheets")
-		} else if strings.Contains(errMsg, "no data") {
-			h.writeError(w, http.StatusBadRequest, "invalid_file", "XLSX file has no data")
-		} else if strings.Contains(errMsg, "header") {
+		switch {
+		case errors.Is(err, xlsx.ErrRecordSink):
+			h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to store upload")
+		case errors.Is(err, xlsx.ErrUnsupportedFormat):
+			h.writeError(w, http.StatusBadRequest, "invalid_file_type", errMsg)
+		case errors.Is(err, xlsx.ErrEncrypted):
+			h.writeError(w, http.StatusBadRequest, "encrypted_file", "File is encrypted: "+errMsg)
+		case errors.Is(err, xlsx.ErrWrongPassword):
+			h.writeError(w, http.StatusBadRequest, "wrong_password", "The password does not open the file")
+		case errors.Is(err, xlsx.ErrSheetNotFound):
+			h.writeError(w, http.StatusBadRequest, "invalid_sheet", errMsg)
+		case errors.Is(err, xlsx.ErrDuplicateHeaders):
+			h.writeError(w, http.StatusBadRequest, "duplicate_headers", errMsg)
+		case errors.Is(err, xlsx.ErrSchemaMismatch):
+			h.writeError(w, http.StatusUnprocessableEntity, "schema_mismatch", errMsg)
+		case errors.Is(err, ledger.ErrNotTransactions):
+			h.writeError(w, http.StatusUnprocessableEntity, "not_transactions", errMsg)
+		case errors.Is(err, ledger.ErrNotReconciled):
+			h.writeError(w, http.StatusUnprocessableEntity, "not_reconciled", errMsg)
+		case errors.Is(err, xlsx.ErrNoSheets):
+			h.writeError(w, http.StatusBadRequest, "invalid_file", "File has no sheets")
+		case errors.Is(err, xlsx.ErrNoData):
+			h.writeError(w, http.StatusBadRequest, "invalid_file", "File has no data")
+		case errors.Is(err, xlsx.ErrInvalidHeaders):
 			h.writeError(w, http.StatusBadRequest, "invalid_headers", errMsg)
-		} else {
-			h.writeError(w, http.StatusBadRequest, "parse_error", "Failed to parse XLSX file: "+errMsg)
+		default:
+			h.writeError(w, http.StatusBadRequest, "parse_error", "Failed to parse file: "+errMsg)
 		}
 		return
 	}
 
-	if len(result.Records) > 0 {
-		err = h.storage.Store(result.Records)
-		if err != nil {
-			h.logger.Error().Err(err).Msg("Failed to store records")
-			h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to store records")
-			return
-		}
+	upload := models.Upload{
+		ID:           uploadID,
+		Filename:     header.Filename,
+		Size:         header.Size,
+		Metadata:     result.Metadata,
+		RowsAccepted: result.RowsAccepted,
+		RowsRejected: result.RowsRejected,
+		Sheets:       result.Sheets,
+		CreatedAt:    time.Now().UTC(),
+
+		Reconciliation: result.Reconciliation,
+	}
+	if profile != nil {
+		upload.Profile = profile.Name
+	}
+	if uploadSchema != nil {
+		upload.Schema = uploadSchema.Name
+	}
+	err = writer.Commit(storage.UploadBatch{
+		Upload:       upload,
+		Records:      result.Records,
+		Transactions: result.Transactions,
+		Rejections:   result.Errors,
+	})
+	if err != nil {
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to store upload")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to store upload")
+		return
 	}
 
 	h.logger.Info().
 		Str("upload_id", uploadID).
 		Int("rows_accepted", result.RowsAccepted).
 		Int("rows_rejected", result.RowsRejected).
+		Int("sheets", len(result.Sheets)).
 		Msg("Upload processed successfully")
 
 	response := models.UploadResponse{
 		UploadID:     uploadID,
+		Profile:      upload.Profile,
+		Schema:       upload.Schema,
+		Metadata:     upload.Metadata,
 		RowsAccepted: result.RowsAccepted,
 		RowsRejected: result.RowsRejected,
+		Sheets:       result.Sheets,
+		Errors:       preview,
+
+		Reconciliation: result.Reconciliation,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -125,6 +324,19 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// acceptedContentType allows the content types browsers and HTTP clients
+// send for spreadsheets and delimited text. Windows commonly labels CSV files
+// as application/vnd.ms-excel.
+func acceptedContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, accepted := range []string{"spreadsheet", "excel", "octet-stream", "csv", "tab-separated-values", "text/plain"} {
+		if strings.Contains(contentType, accepted) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *UploadHandler) writeError(w http.ResponseWriter, statusCode int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+type UploadsHandler struct {
+	storage storage.Store
+	logger  *zerolog.Logger
+}
+
+func NewUploadsHandler(storage storage.Store, logger *zerolog.Logger) *UploadsHandler {
+	return &UploadsHandler{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// List pages through the uploads, newest first. They can be filtered by
+// status and by creation time, from and to being RFC 3339 times or dates;
+// a date in to includes that day.
+func (h *UploadsHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+	filter, err := parseUploadFilter(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+
+	uploads, total, err := h.storage.ListUploads(filter, limit, offset)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list uploads")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve uploads")
+		return
+	}
+
+	response := models.ListUploadsResponse{
+		Uploads: uploads,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func parseUploadFilter(r *http.Request) (storage.UploadFilter, error) {
+	query := r.URL.Query()
+	filter := storage.UploadFilter{Status: query.Get("status")}
+	switch filter.Status {
+	case "", models.UploadStatusCommitted, models.UploadStatusRolledBack:
+	default:
+		return filter, invalidParamError("status")
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+		return filter, invalidParamError("from")
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+		return filter, invalidParamError("to")
+	}
+	return filter, nil
+}
+
+// parseTimeParam reads an RFC 3339 time or a date, which stands for the
+// start of that day in UTC, or of the next day when it ends a range.
+func parseTimeParam(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// Get returns an upload with the metadata read from its statement preambles
+// and the number of its records still stored.
+func (h *UploadsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "id")
+
+	upload, err := h.storage.GetUpload(uploadID)
+	if errors.Is(err, storage.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "not_found", "Upload not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to get upload")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve upload")
+		return
+	}
+
+	stored, err := h.storage.CountByUploadID(uploadID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to count upload records")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve upload")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.UploadDetail{Upload: upload, StoredRecords: stored})
+}
+
+// Rollback removes the records and transactions of an upload and marks it
+// rolled back. The upload and its rejections are kept as a record of what
+// was undone.
+func (h *UploadsHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "id")
+
+	upload, err := h.storage.RollbackUpload(uploadID, time.Now().UTC())
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "not_found", "Upload not found")
+		return
+	case errors.Is(err, storage.ErrRolledBack):
+		h.writeError(w, http.StatusConflict, "already_rolled_back", "Upload was already rolled back")
+		return
+	case err != nil:
+		h.logger.Error().Err(err).Str("upload_id", uploadID).Msg("Failed to roll back upload")
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "Failed to roll back upload")
+		return
+	}
+
+	h.logger.Info().Str("upload_id", uploadID).Msg("Upload rolled back")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(upload)
+}
+
+func (h *UploadsHandler) writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
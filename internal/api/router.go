@@ -8,19 +8,29 @@ import (
 	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
 	custommw "github.com/joelovien/go-xlsx-api/internal/api/middleware"
 	"github.com/joelovien/go-xlsx-api/internal/config"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
 	"github.com/joelovien/go-xlsx-api/internal/storage"
 	"github.com/joelovien/go-xlsx-api/internal/xlsx"
 	"github.com/rs/zerolog"
 )
 
-func NewRouter(cfg *config.Config, logger *zerolog.Logger) http.Handler {
+// NewRouter builds the HTTP API on top of store, which the caller opens and
+// closes.
+func NewRouter(cfg *config.Config, store storage.Store, logger *zerolog.Logger) http.Handler {
 	r := chi.NewRouter()
 
-	store := storage.NewMemoryStorage()
 	parser := xlsx.NewParser(cfg.WorkerPoolSize)
+	schemas := schema.NewRegistry()
+	profiles := mapping.NewRegistry()
 
-	uploadHandler := handlers.NewUploadHandler(store, parser, cfg.MaxUploadSizeMB, logger)
+	uploadHandler := handlers.NewUploadHandler(store, parser, schemas, profiles, cfg.MaxUploadSizeMB, logger)
 	listHandler := handlers.NewListHandler(store, logger)
+	transactionsHandler := handlers.NewTransactionsHandler(store, logger)
+	rejectionsHandler := handlers.NewRejectionsHandler(store, logger)
+	uploadsHandler := handlers.NewUploadsHandler(store, logger)
+	schemaHandler := handlers.NewSchemaHandler(schemas, logger)
+	profileHandler := handlers.NewProfileHandler(profiles, logger)
 	healthHandler := handlers.NewHealthHandler()
 
 	rateLimiter := custommw.NewRateLimiter(cfg.RateLimit)
@@ -45,9 +55,28 @@ func NewRouter(cfg *config.Config, logger *zerolog.Logger) http.Handler {
 
 		// Upload endpoint
 		r.Post("/uploads", uploadHandler.Handle)
+		r.Get("/uploads", uploadsHandler.List)
+		r.Get("/uploads/{id}", uploadsHandler.Get)
+		r.Post("/uploads/{id}/rollback", uploadsHandler.Rollback)
+
+		// Row rejections of an upload
+		r.Get("/uploads/{id}/errors", rejectionsHandler.Handle)
+
+		// Validation schemas
+		r.Post("/schemas", schemaHandler.Create)
+		r.Get("/schemas", schemaHandler.List)
+		r.Get("/schemas/{name}", schemaHandler.Get)
+
+		// Header mapping profiles
+		r.Post("/profiles", profileHandler.Create)
+		r.Get("/profiles", profileHandler.List)
+		r.Get("/profiles/{name}", profileHandler.Get)
 
 		// List records endpoint
 		r.Get("/records", listHandler.Handle)
+
+		// Normalized bank transactions
+		r.Get("/transactions", transactionsHandler.Handle)
 	})
 
 	return r
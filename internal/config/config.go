@@ -15,6 +15,14 @@ type Config struct {
 	RequestTimeout  time.Duration
 	WorkerPoolSize  int
 	LogLevel        string
+
+	// StorageBackend selects where records are kept: "memory", "file" or
+	// "postgres".
+	StorageBackend string
+	// StoragePath is the directory of the file backend.
+	StoragePath string
+	// DatabaseURL is the connection string of the postgres backend.
+	DatabaseURL string
 }
 
 func Load() *Config {
@@ -27,6 +35,9 @@ func Load() *Config {
 		RequestTimeout:  getEnvAsDuration("REQUEST_TIMEOUT", 60*time.Second),
 		WorkerPoolSize:  getEnvAsInt("WORKER_POOL_SIZE", 10),
 		LogLevel:        getEnv("LOG_LEVEL", "info"),
+		StorageBackend:  getEnv("STORAGE_BACKEND", "memory"),
+		StoragePath:     getEnv("STORAGE_PATH", "data"),
+		DatabaseURL:     getEnv("DATABASE_URL", ""),
 	}
 }
 
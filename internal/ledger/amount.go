@@ -0,0 +1,174 @@
+package ledger
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var errInvalidAmount = errors.New("value is not an amount")
+
+// ParseAmount reads a monetary amount. Numbers are taken as they are; text
+// may carry a currency symbol or code, grouping separators, a leading or
+// trailing minus, parentheses or a DR suffix for negative amounts and a CR
+// suffix for positive ones. ok is false for blank values such as "" or "-".
+func ParseAmount(value interface{}, locale Locale) (amount float64, ok bool, err error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, false, nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, false, errInvalidAmount
+		}
+		return v, true, nil
+	case float32:
+		return ParseAmount(float64(v), locale)
+	case int:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case string:
+		return parseAmountText(v, locale)
+	default:
+		return 0, false, errInvalidAmount
+	}
+}
+
+func parseAmountText(text string, locale Locale) (float64, bool, error) {
+	s := strings.TrimSpace(text)
+	if s == "" || s == "-" || s == "–" || s == "—" {
+		return 0, false, nil
+	}
+
+	negative := false
+	switch upper := strings.ToUpper(s); {
+	case strings.HasSuffix(upper, "DR"):
+		negative, s = true, strings.TrimSpace(s[:len(s)-2])
+	case strings.HasSuffix(upper, "CR"):
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, s[1:len(s)-1]
+	}
+
+	// Drop the currency, whitespace and apostrophes used for grouping. A
+	// currency code or abbreviation is the only text allowed.
+	var b strings.Builder
+	letters, words := 0, 0
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r):
+			if letters == 0 {
+				words++
+			}
+			letters++
+			if letters > 3 || words > 1 {
+				return 0, false, errInvalidAmount
+			}
+			continue
+		case unicode.IsSpace(r), unicode.Is(unicode.Sc, r), r == '\'', r == '’':
+		case r == '−' || r == '–':
+			b.WriteByte('-')
+		default:
+			b.WriteRune(r)
+		}
+		letters = 0
+	}
+	s = b.String()
+
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative, s = !negative, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	case strings.HasSuffix(s, "-"):
+		negative, s = !negative, s[:len(s)-1]
+	}
+
+	number, ok := locale.normalizeNumber(s)
+	if !ok {
+		return 0, false, errInvalidAmount
+	}
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, false, errInvalidAmount
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, true, nil
+}
+
+// normalizeNumber turns digits with grouping and decimal separators into
+// the form strconv understands. Without a locale the separator that comes
+// last is the decimal one, and a lone separator followed by exactly three
+// digits groups thousands.
+func (l Locale) normalizeNumber(s string) (string, bool) {
+	if s == "" || strings.Trim(s, "0123456789.,") != "" || strings.Trim(s, ".,") == "" {
+		return "", false
+	}
+
+	decimal := l.decimal
+	if decimal == 0 {
+		dot, comma := strings.LastIndexByte(s, '.'), strings.LastIndexByte(s, ',')
+		switch {
+		case dot >= 0 && comma >= 0:
+			decimal = '.'
+			if comma > dot {
+				decimal = ','
+			}
+		case dot >= 0 || comma >= 0:
+			sep := byte('.')
+			if comma >= 0 {
+				sep = ','
+			}
+			last := max(dot, comma)
+			if strings.Count(s, string(sep)) > 1 || (len(s)-last-1 == 3 && last > 0) {
+				decimal = otherSeparator(sep)
+			} else {
+				decimal = sep
+			}
+		default:
+			return s, true
+		}
+	}
+	group := otherSeparator(decimal)
+
+	whole, fraction, hasFraction := strings.Cut(s, string(decimal))
+	if strings.IndexByte(fraction, decimal) >= 0 || strings.IndexByte(fraction, group) >= 0 {
+		return "", false
+	}
+	if hasFraction && fraction == "" {
+		return "", false
+	}
+
+	// Grouped digits come in threes after the first group.
+	if strings.IndexByte(whole, group) >= 0 {
+		groups := strings.Split(whole, string(group))
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return "", false
+		}
+		for _, g := range groups[1:] {
+			if len(g) != 3 {
+				return "", false
+			}
+		}
+		whole = strings.Join(groups, "")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !hasFraction {
+		return whole, true
+	}
+	return whole + "." + fraction, true
+}
+
+func otherSeparator(sep byte) byte {
+	if sep == '.' {
+		return ','
+	}
+	return '.'
+}
@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidDate = errors.New("value is not a date")
+
+var (
+	isoDatePattern     = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})(?:[T ].*)?$`)
+	numericDatePattern = regexp.MustCompile(`^(\d{1,4})[./-](\d{1,2})[./-](\d{2,4})(?: .*)?$`)
+	compactDatePattern = regexp.MustCompile(`^(\d{4})(\d{2})(\d{2})$`)
+)
+
+// namedMonthLayouts are the dates spelling out the month, in English.
+var namedMonthLayouts = []string{
+	"2 Jan 2006", "2 January 2006", "2-Jan-2006", "2-Jan-06", "2 Jan 06",
+	"Jan 2, 2006", "January 2, 2006", "Jan 2 2006", "January 2 2006",
+}
+
+// ParseDate reads a calendar date and returns it as YYYY-MM-DD, dropping any
+// time of day. Besides ISO-8601 it accepts day, month and year separated by
+// slashes, dots or dashes, ordered by the locale, YYYYMMDD and English month
+// names. Without a locale an ambiguous date such as 01/02/2024 is read day
+// first, unless its second part cannot be a month. ok is false for blank
+// values.
+func ParseDate(value interface{}, locale Locale) (date string, ok bool, err error) {
+	switch v := value.(type) {
+	case nil:
+		return "", false, nil
+	case time.Time:
+		return v.Format("2006-01-02"), true, nil
+	case string:
+		return parseDateText(v, locale)
+	default:
+		return "", false, errInvalidDate
+	}
+}
+
+func parseDateText(text string, locale Locale) (string, bool, error) {
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return "", false, nil
+	}
+
+	if m := isoDatePattern.FindStringSubmatch(s); m != nil {
+		return civilDate(m[1], m[2], m[3])
+	}
+	if m := compactDatePattern.FindStringSubmatch(s); m != nil {
+		return civilDate(m[1], m[2], m[3])
+	}
+	if m := numericDatePattern.FindStringSubmatch(s); m != nil {
+		if len(m[1]) == 4 {
+			return civilDate(m[1], m[2], m[3])
+		}
+		if len(m[1]) > 2 {
+			return "", false, errInvalidDate
+		}
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		monthFirst := locale.monthFirst
+		if locale.Tag == "" {
+			monthFirst = first <= 12 && second > 12
+		}
+		if monthFirst {
+			return civilDate(m[3], m[1], m[2])
+		}
+		return civilDate(m[3], m[2], m[1])
+	}
+
+	for _, layout := range namedMonthLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true, nil
+		}
+	}
+	return "", false, errInvalidDate
+}
+
+// civilDate checks a year, month and day and formats them. Two-digit years
+// fall between 1970 and 2069.
+func civilDate(year, month, day string) (string, bool, error) {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	switch len(year) {
+	case 2:
+		y += 2000
+		if y >= 2070 {
+			y -= 100
+		}
+	case 4:
+	default:
+		return "", false, errInvalidDate
+	}
+
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Year() != y || int(t.Month()) != m || t.Day() != d {
+		return "", false, errInvalidDate
+	}
+	return t.Format("2006-01-02"), true, nil
+}
@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// ErrInvalidLocale is returned for locales that are not language tags.
+var ErrInvalidLocale = errors.New("locale must be auto or a language tag such as en-US or de-DE")
+
+// Locale decides how amounts and dates written as text are read. The zero
+// value infers the separators of each amount from the amount itself and
+// reads ambiguous dates day first.
+type Locale struct {
+	// Tag is the language tag the locale was parsed from, empty for auto.
+	Tag string
+
+	// decimal is the decimal separator, or zero to infer it.
+	decimal byte
+	// monthFirst reads 01/02/2024 as January 2.
+	monthFirst bool
+}
+
+// decimalComma lists the languages that write 1.234,56.
+var decimalComma = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true,
+	"es": true, "et": true, "fi": true, "fr": true, "hr": true, "hu": true,
+	"id": true, "is": true, "it": true, "lt": true, "lv": true, "nb": true,
+	"nl": true, "nn": true, "no": true, "pl": true, "pt": true, "ro": true,
+	"ru": true, "sk": true, "sl": true, "sr": true, "sv": true, "tr": true,
+	"uk": true, "vi": true,
+}
+
+// decimalPointRegions overrides decimalComma for countries that write
+// 1'234.56 or 1,234.56 despite their language.
+var decimalPointRegions = map[string]bool{"CH": true, "LI": true, "MX": true}
+
+// ParseLocale parses the value of the "locale" upload parameter: "auto" or
+// empty, or a BCP 47 language tag. The language picks the decimal separator
+// and the region the order of day and month; a bare "en" reads as en-US.
+func ParseLocale(value string) (Locale, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "auto") {
+		return Locale{}, nil
+	}
+
+	tag, err := language.Parse(value)
+	if err != nil {
+		return Locale{}, ErrInvalidLocale
+	}
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+
+	locale := Locale{Tag: tag.String(), decimal: '.'}
+	if decimalComma[base.String()] && !decimalPointRegions[region.String()] {
+		locale.decimal = ','
+	}
+	locale.monthFirst = region.String() == "US"
+	return locale, nil
+}
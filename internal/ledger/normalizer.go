@@ -0,0 +1,255 @@
+// Package ledger turns bank statement records into canonical transactions.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+)
+
+// Fields of the canonical transaction, which statement columns are mapped
+// onto. A mapping profile can name columns after them directly.
+const (
+	FieldValueDate   = "valueDate"
+	FieldBookingDate = "bookingDate"
+	FieldDescription = "description"
+	FieldDebit       = "debit"
+	FieldCredit      = "credit"
+	FieldAmount      = "amount"
+	FieldBalance     = "balance"
+	FieldCurrency    = "currency"
+	FieldReference   = "reference"
+
+	// fieldDirection is a column telling debits from credits when amounts
+	// are unsigned.
+	fieldDirection = "direction"
+)
+
+// Violation codes reported for rows that cannot be normalized.
+const (
+	CodeInvalidAmount = "invalid_amount"
+	CodeInvalidDate   = "invalid_date"
+	CodeMissingAmount = "missing_amount"
+	CodeMissingDate   = "missing_date"
+)
+
+// ErrNotTransactions is returned for sheets without the columns of a bank
+// statement.
+var ErrNotTransactions = errors.New("sheet is not a bank statement")
+
+// columnAliases lists the headers each field is known by, compared without
+// case, spaces or punctuation. Earlier columns of a sheet win.
+var columnAliases = map[string][]string{
+	FieldValueDate:   {"value date", "valuta", "valutadatum", "wertstellung", "date valeur", "fecha valor", "effective date"},
+	FieldBookingDate: {"date", "booking date", "posting date", "posted date", "transaction date", "txn date", "trans date", "entry date", "buchungstag", "buchungsdatum", "datum", "date operation", "fecha"},
+	FieldDescription: {"description", "details", "transaction details", "narrative", "memo", "payee", "particulars", "text", "verwendungszweck", "buchungstext", "beschreibung", "libelle", "concepto"},
+	FieldDebit:       {"debit", "debits", "debit amount", "amount debit", "withdrawal", "withdrawals", "paid out", "money out", "soll"},
+	FieldCredit:      {"credit", "credits", "credit amount", "amount credit", "deposit", "deposits", "paid in", "money in", "haben"},
+	FieldAmount:      {"amount", "transaction amount", "betrag", "umsatz", "montant", "importe"},
+	FieldBalance:     {"balance", "running balance", "closing balance", "ledger balance", "saldo", "kontostand", "solde"},
+	FieldCurrency:    {"currency", "ccy", "curr", "währung", "devise", "moneda"},
+	FieldReference:   {"reference", "ref", "reference number", "transaction reference", "bank reference", "transaction id", "cheque number", "cheque no", "check number", "referenz"},
+	fieldDirection:   {"dr/cr", "cr/dr", "debit/credit", "credit/debit", "type", "transaction type", "soll/haben", "s/h"},
+}
+
+var aliasFields = func() map[string]string {
+	fields := make(map[string]string)
+	for field, aliases := range columnAliases {
+		fields[foldHeader(field)] = field
+		for _, alias := range aliases {
+			fields[foldHeader(alias)] = field
+		}
+	}
+	return fields
+}()
+
+// directions maps the values of a direction column onto the sign of the
+// amount.
+var directions = map[string]float64{
+	"d": -1, "dr": -1, "debit": -1, "s": -1, "soll": -1, "withdrawal": -1,
+	"c": 1, "cr": 1, "credit": 1, "h": 1, "haben": 1, "deposit": 1,
+}
+
+// Normalizer maps the records of one sheet onto transactions.
+type Normalizer struct {
+	columns  map[string]string
+	currency string
+	locale   Locale
+}
+
+// NewNormalizer finds the transaction columns among the record keys of a
+// sheet. currency is used for rows without a currency column, typically the
+// currency of the statement preamble. A sheet needs a date and an amount,
+// debit or credit column.
+func NewNormalizer(keys []string, currency string, locale Locale) (*Normalizer, error) {
+	n := &Normalizer{
+		columns:  make(map[string]string),
+		currency: strings.ToUpper(strings.TrimSpace(currency)),
+		locale:   locale,
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		field, ok := aliasFields[foldHeader(key)]
+		if !ok {
+			// Hierarchical headers such as "Amount.Debit" also match by
+			// their last part.
+			if i := strings.LastIndexByte(key, '.'); i >= 0 {
+				field, ok = aliasFields[foldHeader(key[i+1:])]
+			}
+		}
+		if _, taken := n.columns[field]; ok && !taken {
+			n.columns[field] = key
+		}
+	}
+
+	if n.columns[FieldBookingDate] == "" && n.columns[FieldValueDate] == "" {
+		return nil, fmt.Errorf("%w: no date column", ErrNotTransactions)
+	}
+	if n.columns[FieldAmount] == "" && n.columns[FieldDebit] == "" && n.columns[FieldCredit] == "" {
+		return nil, fmt.Errorf("%w: no amount, debit or credit column", ErrNotTransactions)
+	}
+	return n, nil
+}
+
+// Columns returns the record key mapped onto each field.
+func (n *Normalizer) Columns() map[string]string {
+	columns := make(map[string]string, len(n.columns))
+	for field, key := range n.columns {
+		if field != fieldDirection {
+			columns[field] = key
+		}
+	}
+	return columns
+}
+
+// Normalize maps a record onto a transaction. The violations name the
+// record keys of the offending cells.
+func (n *Normalizer) Normalize(data map[string]interface{}) (models.Transaction, []schema.Violation) {
+	var tx models.Transaction
+	var violations []schema.Violation
+	fail := func(field, code, message string) {
+		column := n.columns[field]
+		violations = append(violations, schema.Violation{Column: column, Code: code, Message: message, Value: data[column]})
+	}
+
+	date := func(field string) string {
+		column, ok := n.columns[field]
+		if !ok {
+			return ""
+		}
+		date, _, err := ParseDate(data[column], n.locale)
+		if err != nil {
+			fail(field, CodeInvalidDate, "value is not a date")
+		}
+		return date
+	}
+	tx.BookingDate = date(FieldBookingDate)
+	tx.ValueDate = date(FieldValueDate)
+	if tx.BookingDate == "" && tx.ValueDate == "" && len(violations) == 0 {
+		field := FieldBookingDate
+		if _, ok := n.columns[field]; !ok {
+			field = FieldValueDate
+		}
+		fail(field, CodeMissingDate, "transaction has no date")
+	}
+
+	amount := func(field string) (float64, bool) {
+		column, ok := n.columns[field]
+		if !ok {
+			return 0, false
+		}
+		amount, ok, err := ParseAmount(data[column], n.locale)
+		if err != nil {
+			fail(field, CodeInvalidAmount, "value is not an amount")
+		}
+		return amount, ok
+	}
+	signed, hasAmount := amount(FieldAmount)
+	debit, hasDebit := amount(FieldDebit)
+	credit, hasCredit := amount(FieldCredit)
+	switch {
+	case hasAmount:
+	case hasDebit || hasCredit:
+		signed = math.Abs(credit) - math.Abs(debit)
+	case len(violations) == 0:
+		field := FieldAmount
+		if _, ok := n.columns[field]; !ok {
+			field = FieldDebit
+			if _, ok := n.columns[field]; !ok {
+				field = FieldCredit
+			}
+		}
+		fail(field, CodeMissingAmount, "transaction has no amount")
+	}
+	if sign, ok := directions[foldHeader(n.text(data, fieldDirection))]; ok {
+		signed = sign * math.Abs(signed)
+	}
+	tx.Amount = round(signed)
+	if tx.Amount < 0 {
+		tx.Debit = -tx.Amount
+	} else {
+		tx.Credit = tx.Amount
+	}
+
+	if balance, ok := amount(FieldBalance); ok {
+		balance = round(balance)
+		tx.Balance = &balance
+	}
+
+	tx.Description = n.text(data, FieldDescription)
+	tx.Reference = n.text(data, FieldReference)
+	tx.Currency = strings.ToUpper(n.text(data, FieldCurrency))
+	if tx.Currency == "" {
+		tx.Currency = n.currency
+	}
+
+	if len(violations) > 0 {
+		return models.Transaction{}, violations
+	}
+	return tx, nil
+}
+
+// text returns the value of a field as text. Numbers, such as references,
+// are written out in full.
+func (n *Normalizer) text(data map[string]interface{}, field string) string {
+	column, ok := n.columns[field]
+	if !ok {
+		return ""
+	}
+	switch v := data[column].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// foldHeader lowercases a header and drops everything but letters and
+// digits, so that "Value Date", "value_date" and "valueDate" compare equal.
+func foldHeader(header string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(header) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// round drops the noise that float arithmetic leaves below the smallest
+// currency unit in use.
+func round(amount float64) float64 {
+	return math.Round(amount*1e6) / 1e6
+}
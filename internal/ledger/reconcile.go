@@ -0,0 +1,263 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+)
+
+// Reconciliation statuses.
+const (
+	StatusReconciled = "reconciled"
+	StatusBroken     = "broken"
+	// StatusUnchecked is reported for statements without balances to
+	// check.
+	StatusUnchecked = "unchecked"
+)
+
+const (
+	orderAscending  = "ascending"
+	orderDescending = "descending"
+
+	// balanceTolerance absorbs rounding in balances shown to the cent.
+	balanceTolerance = 0.005
+
+	// maxReportedBreaks bounds the breaks listed in a summary; every break
+	// is still counted and flagged on its transaction.
+	maxReportedBreaks = 100
+)
+
+// ErrNotReconciled is returned when an upload is required to reconcile and
+// does not.
+var ErrNotReconciled = errors.New("statement does not reconcile")
+
+// Reconcile walks the running balance of a sheet's transactions, in sheet
+// order, and flags the transactions where it breaks. The statement may list
+// its newest transaction first; the order with fewer breaks is taken.
+// opening and closing are the balances stated in the preamble, if any.
+// Without an opening balance the chain starts at the first balance found.
+func Reconcile(transactions []models.Transaction, opening, closing *float64) models.Reconciliation {
+	r := NewReconciler(opening)
+	for i := range transactions {
+		r.Add(transactions[i])
+	}
+	rec, breaks := r.Finish(closing)
+	for _, i := range breaks {
+		transactions[i].BalanceBreak = true
+	}
+	return rec
+}
+
+// Reconciler reconciles a sheet's transactions as they are handed to it, in
+// sheet order, so that they need not be held: it keeps the running balance
+// of both orders and the positions of their breaks.
+type Reconciler struct {
+	opening *float64
+	count   int
+	debits  float64
+	credits float64
+
+	asc  ascendingWalk
+	desc descendingWalk
+}
+
+// NewReconciler starts a reconciliation from the opening balance stated in
+// the preamble, if any.
+func NewReconciler(opening *float64) *Reconciler {
+	r := &Reconciler{opening: opening}
+	r.asc.rec.Order = orderAscending
+	r.desc.rec.Order = orderDescending
+	if opening != nil {
+		r.asc.started = true
+		r.asc.balance = *opening
+		r.asc.rec.OpeningBalance = floatPtr(*opening)
+	}
+	return r
+}
+
+// Add takes the next transaction of the sheet.
+func (r *Reconciler) Add(tx models.Transaction) {
+	r.debits += tx.Debit
+	r.credits += tx.Credit
+	r.asc.add(r.count, tx)
+	r.desc.add(r.count, tx)
+	r.count++
+}
+
+// Finish returns the reconciliation against the closing balance stated in
+// the preamble, if any, and the positions of the transactions where the
+// balance breaks, in sheet order.
+func (r *Reconciler) Finish(closing *float64) (models.Reconciliation, []int) {
+	if r.asc.started {
+		r.asc.rec.ClosingBalance = floatPtr(r.asc.balance)
+	}
+	r.desc.finish(r.opening)
+
+	rec, breaks := r.asc.rec, r.asc.breaks
+	if len(breaks) > 0 && len(r.desc.breaks) < len(breaks) {
+		rec, breaks = r.desc.rec, r.desc.breaks
+	}
+	rec.TotalDebits = round(r.debits)
+	rec.TotalCredits = round(r.credits)
+	rec.BreakCount = len(breaks)
+
+	if closing != nil && rec.ClosingBalance != nil {
+		if diff := round(*closing - *rec.ClosingBalance); math.Abs(diff) > balanceTolerance {
+			rec.ClosingDifference = diff
+		}
+	}
+
+	switch {
+	case rec.BreakCount > 0 || rec.ClosingDifference != 0:
+		rec.Status = StatusBroken
+	case rec.Checked > 0 || (closing != nil && rec.ClosingBalance != nil):
+		rec.Status = StatusReconciled
+	default:
+		rec.Status = StatusUnchecked
+	}
+	return rec, breaks
+}
+
+// balanceWalk is what both orders report: the reconciliation and the
+// positions of the breaks.
+type balanceWalk struct {
+	rec    models.Reconciliation
+	breaks []int
+}
+
+// check compares a balance with the one the chain expects there.
+func (w *balanceWalk) check(i, row, sourceRow int, expected, actual float64) {
+	w.rec.Checked++
+	diff := round(actual - expected)
+	if math.Abs(diff) <= balanceTolerance {
+		return
+	}
+	w.breaks = append(w.breaks, i)
+	if len(w.rec.Breaks) < maxReportedBreaks {
+		w.rec.Breaks = append(w.rec.Breaks, models.BalanceBreak{
+			Row:        row,
+			SourceRow:  sourceRow,
+			Expected:   expected,
+			Actual:     actual,
+			Difference: diff,
+		})
+	}
+}
+
+// ascendingWalk follows the balance chain down the sheet, oldest
+// transaction first.
+type ascendingWalk struct {
+	balanceWalk
+	started bool
+	balance float64
+
+	// pending holds the amounts before the first balance, when there is no
+	// opening balance, which are taken back out of it to find where the
+	// statement started.
+	pending float64
+}
+
+func (w *ascendingWalk) add(i int, tx models.Transaction) {
+	if !w.started {
+		w.pending += tx.Amount
+		if tx.Balance != nil {
+			w.started = true
+			w.balance = *tx.Balance
+			w.rec.OpeningBalance = floatPtr(round(w.balance - w.pending))
+		}
+		return
+	}
+
+	expected := round(w.balance + tx.Amount)
+	if tx.Balance == nil {
+		w.balance = expected
+		return
+	}
+	w.check(i, tx.Row, tx.SourceRow, expected, *tx.Balance)
+	w.balance = *tx.Balance
+}
+
+// descendingWalk follows the balance chain of a statement listing its
+// newest transaction first. Reading down the sheet, each balance is checked
+// once the next balance below it, the one it follows from, is known.
+type descendingWalk struct {
+	balanceWalk
+
+	// head sums the amounts above the first balance, which lead from it to
+	// the closing balance.
+	head  float64
+	first *float64
+
+	// last is the latest balance found and segment the sum of the amounts
+	// from its row down to the current one.
+	last    *balancedRow
+	segment float64
+}
+
+type balancedRow struct {
+	index, row, sourceRow int
+	balance               float64
+}
+
+func (w *descendingWalk) add(i int, tx models.Transaction) {
+	if tx.Balance == nil {
+		if w.last == nil {
+			w.head += tx.Amount
+		} else {
+			w.segment += tx.Amount
+		}
+		return
+	}
+
+	if w.last == nil {
+		w.first = floatPtr(*tx.Balance)
+	} else {
+		w.check(w.last.index, w.last.row, w.last.sourceRow, round(*tx.Balance+w.segment), w.last.balance)
+	}
+	w.last = &balancedRow{index: i, row: tx.Row, sourceRow: tx.SourceRow, balance: *tx.Balance}
+	w.segment = tx.Amount
+}
+
+// finish checks the oldest balance against the opening balance, or derives
+// the opening balance from it, and sets the closing balance.
+func (w *descendingWalk) finish(opening *float64) {
+	switch {
+	case w.last == nil:
+		if opening != nil {
+			w.rec.OpeningBalance = floatPtr(*opening)
+			w.rec.ClosingBalance = floatPtr(round(*opening + w.head))
+		}
+		return
+	case opening != nil:
+		w.rec.OpeningBalance = floatPtr(*opening)
+		w.check(w.last.index, w.last.row, w.last.sourceRow, round(*opening+w.segment), w.last.balance)
+	default:
+		w.rec.OpeningBalance = floatPtr(round(w.last.balance - w.segment))
+	}
+	w.rec.ClosingBalance = floatPtr(round(*w.first + w.head))
+}
+
+// CheckReconciled returns an error wrapping ErrNotReconciled that describes
+// the first break of a broken reconciliation.
+func CheckReconciled(rec models.Reconciliation) error {
+	if rec.Status != StatusBroken {
+		return nil
+	}
+	if len(rec.Breaks) == 0 {
+		return fmt.Errorf("%w: closing balance differs by %s", ErrNotReconciled, formatAmount(rec.ClosingDifference))
+	}
+	first := rec.Breaks[0]
+	return fmt.Errorf("%w: %d balance breaks, the first at row %d: expected %s, found %s",
+		ErrNotReconciled, rec.BreakCount, first.SourceRow, formatAmount(first.Expected), formatAmount(first.Actual))
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,91 @@
+package mapping
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PreambleField is a value found in the rows above the header of a sheet,
+// such as the account number of a bank statement, and the labels it
+// appears under. Patterns are regular expressions matched against the whole
+// label, ignoring case.
+type PreambleField struct {
+	Name     string   `json:"name"`
+	Patterns []string `json:"patterns"`
+
+	compiled []*regexp.Regexp
+}
+
+// DefaultPreamble recognises the labels common to bank statements. Fields
+// of a profile are tried first, so a profile can add labels or take over a
+// name.
+var DefaultPreamble = mustCompilePreamble([]PreambleField{
+	{Name: "accountName", Patterns: []string{`account\s*(name|holder)`, `(customer|client)\s*name`, `name`}},
+	{Name: "accountNumber", Patterns: []string{`(account|acct\.?|a/c)\s*(number|no\.?|num|#)?`, `iban`}},
+	{Name: "currency", Patterns: []string{`(account\s*)?(currency|ccy)`}},
+	{Name: "statementPeriod", Patterns: []string{`(statement\s*)?period`, `statement\s*(dates?|range)`, `from\s*-\s*to`}},
+	{Name: "openingBalance", Patterns: []string{`(opening|starting|start|previous)\s*balance`, `balance\s*brought\s*forward`, `(anfangs|alter\s*)saldo`}},
+	{Name: "closingBalance", Patterns: []string{`(closing|ending|end|new)\s*balance`, `balance\s*carried\s*forward`, `(end|neuer\s*)saldo`}},
+})
+
+func (f *PreambleField) compile() error {
+	if strings.TrimSpace(f.Name) == "" {
+		return fmt.Errorf("%w: preamble field has no name", ErrInvalidProfile)
+	}
+	if len(f.Patterns) == 0 {
+		return fmt.Errorf("%w: preamble field %s has no patterns", ErrInvalidProfile, f.Name)
+	}
+
+	f.compiled = make([]*regexp.Regexp, len(f.Patterns))
+	for i, pattern := range f.Patterns {
+		re, err := regexp.Compile(`(?i)^(?:` + pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("%w: preamble field %s: invalid pattern %q", ErrInvalidProfile, f.Name, pattern)
+		}
+		f.compiled[i] = re
+	}
+	return nil
+}
+
+func (f *PreambleField) matches(label string) bool {
+	for _, re := range f.compiled {
+		if re.MatchString(label) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustCompilePreamble(fields []PreambleField) []PreambleField {
+	for i := range fields {
+		if err := fields[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+	return fields
+}
+
+// PreambleKey returns the metadata key for a preamble label: the name of the
+// first profile or default field matching it, or else the label itself,
+// snake_cased when the profile asks for it. p may be nil.
+func (p *Profile) PreambleKey(label string) string {
+	label = strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(label), ":")), " ")
+
+	var fields []PreambleField
+	if p != nil {
+		fields = p.Preamble
+	}
+	for _, set := range [][]PreambleField{fields, DefaultPreamble} {
+		for i := range set {
+			if set[i].matches(label) {
+				return set[i].Name
+			}
+		}
+	}
+
+	if p != nil && p.SnakeCase {
+		return SnakeCase(label)
+	}
+	return label
+}
@@ -0,0 +1,141 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidProfile is wrapped by every error returned from Compile.
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// Profile renames worksheet headers to canonical field names so that
+// records from differently labelled sources share one shape.
+type Profile struct {
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+
+	// FoldCase matches aliases regardless of letter case.
+	FoldCase bool `json:"foldCase,omitempty"`
+	// FoldWhitespace trims headers and collapses inner runs of whitespace
+	// before matching.
+	FoldWhitespace bool `json:"foldWhitespace,omitempty"`
+	// SnakeCase converts every resulting key, mapped or not, to snake_case.
+	SnakeCase bool `json:"snakeCase,omitempty"`
+	// DropUnmapped discards columns whose header matches no field.
+	DropUnmapped bool `json:"dropUnmapped,omitempty"`
+
+	// Preamble names the values found above the header, such as the
+	// account number of a statement, for the sources this profile covers.
+	Preamble []PreambleField `json:"preamble,omitempty"`
+
+	aliases map[string]string
+}
+
+// Field is a canonical field and the headers it is known by. The field name
+// itself always counts as an alias.
+type Field struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Compile checks the profile definition and builds its alias index. It must
+// be called before the profile is applied.
+func (p *Profile) Compile() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+	if len(p.Fields) == 0 && len(p.Preamble) == 0 {
+		return fmt.Errorf("%w: at least one field or preamble field is required", ErrInvalidProfile)
+	}
+	for i := range p.Preamble {
+		if err := p.Preamble[i].compile(); err != nil {
+			return err
+		}
+	}
+
+	p.aliases = make(map[string]string)
+	for i, field := range p.Fields {
+		if strings.TrimSpace(field.Name) == "" {
+			return fmt.Errorf("%w: field %d has no name", ErrInvalidProfile, i)
+		}
+		for _, alias := range append([]string{field.Name}, field.Aliases...) {
+			key := p.fold(alias)
+			if key == "" {
+				return fmt.Errorf("%w: field %s has a blank alias", ErrInvalidProfile, field.Name)
+			}
+			if owner, ok := p.aliases[key]; ok && owner != field.Name {
+				return fmt.Errorf("%w: alias %q is claimed by both %s and %s", ErrInvalidProfile, alias, owner, field.Name)
+			}
+			p.aliases[key] = field.Name
+		}
+	}
+	return nil
+}
+
+// Apply maps a header row onto field names. The result has one entry per
+// header; dropped and blank columns map to "".
+func (p *Profile) Apply(headers []string) []string {
+	mapped := make([]string, len(headers))
+	for i, header := range headers {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+
+		key, ok := p.aliases[p.fold(header)]
+		if !ok {
+			if p.DropUnmapped {
+				continue
+			}
+			key = header
+		}
+		if p.SnakeCase {
+			key = SnakeCase(key)
+		}
+		mapped[i] = key
+	}
+	return mapped
+}
+
+func (p *Profile) fold(header string) string {
+	if p.FoldWhitespace {
+		header = strings.Join(strings.Fields(header), " ")
+	}
+	if p.FoldCase {
+		header = strings.ToLower(header)
+	}
+	return header
+}
+
+// SnakeCase lowercases a header and joins its words with underscores, so
+// that "Txn Date", "TxnDate" and "txn-date" all become "txn_date".
+func SnakeCase(header string) string {
+	var b strings.Builder
+	runes := []rune(strings.TrimSpace(header))
+	pendingSeparator := false
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingSeparator = b.Len() > 0
+			continue
+		}
+
+		// Split camelCase and the tail of acronyms such as "IDNumber".
+		if unicode.IsUpper(r) && i > 0 && b.Len() > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				pendingSeparator = true
+			}
+		}
+
+		if pendingSeparator {
+			b.WriteByte('_')
+			pendingSeparator = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package mapping
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("profile not found")
+	ErrExists   = errors.New("profile already exists")
+)
+
+// Registry holds the mapping profiles registered through the API.
+type Registry struct {
+	mu       sync.RWMutex
+	profiles map[string]*Profile
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		profiles: make(map[string]*Profile),
+	}
+}
+
+// Register compiles and stores a profile. Names are unique; registering a
+// name twice returns ErrExists.
+func (r *Registry) Register(p Profile) (*Profile, error) {
+	if err := p.Compile(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.profiles[p.Name]; ok {
+		return nil, ErrExists
+	}
+	r.profiles[p.Name] = &p
+	return &p, nil
+}
+
+func (r *Registry) Get(name string) (*Profile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.profiles[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
+// List returns every registered profile ordered by name.
+func (r *Registry) List() []*Profile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profiles := make([]*Profile, 0, len(r.profiles))
+	for _, p := range r.profiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
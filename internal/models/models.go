@@ -6,14 +6,238 @@ import "time"
 type Record struct {
 	ID        string                 `json:"id"`
 	UploadID  string                 `json:"uploadId"`
+	Sheet     string                 `json:"sheet"`
+	Row       int                    `json:"row"`
+	SourceRow int                    `json:"sourceRow"`
+	SourceRef string                 `json:"sourceRef"`
 	Data      map[string]interface{} `json:"data"`
 	CreatedAt time.Time              `json:"createdAt"`
+
+	// Meta describes the cells of Data, under the same keys, when cell
+	// metadata was requested. Cells without any are left out.
+	Meta map[string]CellMeta `json:"meta,omitempty"`
+
+	// Hidden marks rows hidden in the workbook when they are tagged.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// Transaction is a bank statement row in canonical form. Dates are
+// YYYY-MM-DD; Amount is signed, negative for money leaving the account, and
+// split into the positive Debit and Credit.
+type Transaction struct {
+	ID        string `json:"id"`
+	UploadID  string `json:"uploadId"`
+	RecordID  string `json:"recordId"`
+	Sheet     string `json:"sheet"`
+	Row       int    `json:"row"`
+	SourceRow int    `json:"sourceRow"`
+
+	ValueDate   string   `json:"valueDate,omitempty"`
+	BookingDate string   `json:"bookingDate,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Debit       float64  `json:"debit"`
+	Credit      float64  `json:"credit"`
+	Amount      float64  `json:"amount"`
+	Balance     *float64 `json:"balance,omitempty"`
+	Currency    string   `json:"currency,omitempty"`
+	Reference   string   `json:"reference,omitempty"`
+
+	// BalanceBreak marks the rows whose balance does not follow from the
+	// previous balance and the amount.
+	BalanceBreak bool `json:"balanceBreak,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Reconciliation reports whether the running balance of a statement agrees
+// with its transactions. Order is "descending" for statements listing the
+// newest transaction first.
+type Reconciliation struct {
+	Status         string         `json:"status"`
+	Order          string         `json:"order,omitempty"`
+	OpeningBalance *float64       `json:"openingBalance,omitempty"`
+	ClosingBalance *float64       `json:"closingBalance,omitempty"`
+	TotalDebits    float64        `json:"totalDebits"`
+	TotalCredits   float64        `json:"totalCredits"`
+	Checked        int            `json:"checked"`
+	BreakCount     int            `json:"breakCount"`
+	Breaks         []BalanceBreak `json:"breaks,omitempty"`
+
+	// ClosingDifference is set when the closing balance stated in the
+	// preamble differs from the one the transactions lead to.
+	ClosingDifference float64 `json:"closingDifference,omitempty"`
+}
+
+// BalanceBreak is a row whose balance differs from the previous balance
+// plus its amount. The chain resumes from the balance found.
+type BalanceBreak struct {
+	Row        int     `json:"row"`
+	SourceRow  int     `json:"sourceRow"`
+	Expected   float64 `json:"expected"`
+	Actual     float64 `json:"actual"`
+	Difference float64 `json:"difference"`
+}
+
+// CellMeta is what a cell carries besides its value
+type CellMeta struct {
+	Hyperlink    string        `json:"hyperlink,omitempty"`
+	Comment      *CellComment  `json:"comment,omitempty"`
+	NumberFormat string        `json:"numberFormat,omitempty"`
+	RichText     []RichTextRun `json:"richText,omitempty"`
+}
+
+// CellComment is a note attached to a cell
+type CellComment struct {
+	Author string `json:"author,omitempty"`
+	Text   string `json:"text"`
+}
+
+// RichTextRun is a stretch of a cell's text sharing the same formatting
+type RichTextRun struct {
+	Text      string `json:"text"`
+	Bold      bool   `json:"bold,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+	Strike    bool   `json:"strike,omitempty"`
+	Color     string `json:"color,omitempty"`
 }
 
 type UploadResponse struct {
-	UploadID     string `json:"uploadId"`
+	UploadID     string            `json:"uploadId"`
+	Profile      string            `json:"profile,omitempty"`
+	Schema       string            `json:"schema,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	RowsAccepted int               `json:"rowsAccepted"`
+	RowsRejected int               `json:"rowsRejected"`
+	Sheets       []SheetSummary    `json:"sheets"`
+	Errors       []RowError        `json:"errors"`
+
+	// Reconciliation is the worst reconciliation status of the sheets when
+	// transactions are normalized.
+	Reconciliation string `json:"reconciliation,omitempty"`
+}
+
+// Upload statuses
+const (
+	UploadStatusCommitted  = "committed"
+	UploadStatusRolledBack = "rolled_back"
+)
+
+// Upload is a processed file and what was learnt about it
+type Upload struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Profile  string `json:"profile,omitempty"`
+	Schema   string `json:"schema,omitempty"`
+
+	// Status tells whether the records of the upload are stored or were
+	// rolled back, at RolledBackAt.
+	Status       string     `json:"status"`
+	RolledBackAt *time.Time `json:"rolledBackAt,omitempty"`
+
+	// Metadata holds the labelled values of the statement preambles above
+	// the headers, such as the account number; per sheet in Sheets.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	Reconciliation string `json:"reconciliation,omitempty"`
+
+	RowsAccepted int            `json:"rowsAccepted"`
+	RowsRejected int            `json:"rowsRejected"`
+	Sheets       []SheetSummary `json:"sheets"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// UploadDetail is an upload with the number of its records still stored,
+// which drops to zero when it is rolled back.
+type UploadDetail struct {
+	Upload
+	StoredRecords int `json:"storedRecords"`
+}
+
+// SheetSummary reports the outcome of parsing a single worksheet
+type SheetSummary struct {
+	Name         string `json:"name"`
+	HeaderRow    int    `json:"headerRow"`
 	RowsAccepted int    `json:"rowsAccepted"`
 	RowsRejected int    `json:"rowsRejected"`
+
+	// HeaderRows is set when the header spans several rows.
+	HeaderRows int `json:"headerRows,omitempty"`
+
+	// Metadata holds the labelled values found above the header.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// TransactionColumns maps the transaction fields onto the record keys
+	// they were read from when transactions are normalized.
+	TransactionColumns map[string]string `json:"transactionColumns,omitempty"`
+	Reconciliation     *Reconciliation   `json:"reconciliation,omitempty"`
+
+	// Hidden, HiddenRows and HiddenColumns report what the workbook hides
+	// from view when hidden cells are excluded or tagged: whether the sheet
+	// is hidden, how many data rows are and which columns are.
+	Hidden        bool     `json:"hidden,omitempty"`
+	HiddenRows    int      `json:"hiddenRows,omitempty"`
+	HiddenColumns []string `json:"hiddenColumns,omitempty"`
+
+	// FormulaMismatches lists the first formula cells whose cached value
+	// differs from the recalculated one, or that could not be recalculated.
+	// FormulaMismatchCount counts all of them.
+	FormulaMismatches    []FormulaMismatch `json:"formulaMismatches,omitempty"`
+	FormulaMismatchCount int               `json:"formulaMismatchCount,omitempty"`
+
+	HeaderConflicts []HeaderConflict `json:"headerConflicts,omitempty"`
+}
+
+// FormulaMismatch reports a formula cell found while recalculating.
+type FormulaMismatch struct {
+	Cell     string `json:"cell"`
+	Formula  string `json:"formula"`
+	Cached   string `json:"cached"`
+	Computed string `json:"computed,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HeaderConflict reports a header shared by several columns and how it was
+// resolved
+type HeaderConflict struct {
+	Header     string   `json:"header"`
+	Cells      []string `json:"cells"`
+	Resolution string   `json:"resolution"`
+	Names      []string `json:"names"`
+}
+
+// RowError describes why a row, or one of its cells, was rejected
+type RowError struct {
+	Sheet   string      `json:"sheet"`
+	Row     int         `json:"row"`
+	Column  string      `json:"column,omitempty"`
+	Cell    string      `json:"cell,omitempty"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Value   interface{} `json:"value,omitempty"`
+}
+
+type ListRowErrorsResponse struct {
+	UploadID string     `json:"uploadId"`
+	Errors   []RowError `json:"errors"`
+	Total    int        `json:"total"`
+	Limit    int        `json:"limit"`
+	Offset   int        `json:"offset"`
+}
+
+type ListTransactionsResponse struct {
+	Transactions []Transaction `json:"transactions"`
+	Total        int           `json:"total"`
+	Limit        int           `json:"limit"`
+	Offset       int           `json:"offset"`
+}
+
+type ListUploadsResponse struct {
+	Uploads []Upload `json:"uploads"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
 }
 
 type ListRecordsResponse struct {
@@ -33,7 +257,15 @@ type ErrorResponse struct {
 }
 
 type ParsedRow struct {
-	Data  map[string]interface{}
-	Valid bool
-	Error string
+	Index     int
+	RowNumber int
+	Data      map[string]interface{}
+	Meta      map[string]CellMeta
+	Hidden    bool
+	Valid     bool
+	Errors    []RowError
+
+	// Transaction is the row in canonical form when transactions are
+	// normalized.
+	Transaction *Transaction
 }
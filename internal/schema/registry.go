@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("schema not found")
+	ErrExists   = errors.New("schema already exists")
+)
+
+// Registry holds the schemas registered through the API.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]*Schema
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas: make(map[string]*Schema),
+	}
+}
+
+// Register compiles and stores a schema. Names are unique; registering a
+// name twice returns ErrExists.
+func (r *Registry) Register(s Schema) (*Schema, error) {
+	if err := s.Compile(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.schemas[s.Name]; ok {
+		return nil, ErrExists
+	}
+	r.schemas[s.Name] = &s
+	return &s, nil
+}
+
+func (r *Registry) Get(name string) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// List returns every registered schema ordered by name.
+func (r *Registry) List() []*Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schemas := make([]*Schema, 0, len(r.schemas))
+	for _, s := range r.schemas {
+		schemas = append(schemas, s)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+	return schemas
+}
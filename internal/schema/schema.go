@@ -0,0 +1,326 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types understood by the validator.
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+)
+
+// Violation codes reported for rows that fail validation.
+const (
+	CodeRequired        = "required"
+	CodeInvalidType     = "invalid_type"
+	CodePatternMismatch = "pattern_mismatch"
+	CodeNotInEnum       = "not_in_enum"
+	CodeBelowMin        = "below_min"
+	CodeAboveMax        = "above_max"
+	CodeDuplicateValue  = "duplicate_value"
+)
+
+// ErrInvalidSchema is wrapped by every error returned from Compile.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Schema is a named set of column rules that uploads can be validated
+// against.
+type Schema struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+}
+
+// Column describes the rules for a single column. Min and Max bound numeric
+// values, and the length of string values.
+type Column struct {
+	Name     string   `json:"name"`
+	Required bool     `json:"required,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Unique   bool     `json:"unique,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Violation is a single failed rule on a row.
+type Violation struct {
+	Column  string
+	Code    string
+	Message string
+	Value   interface{}
+}
+
+// Compile checks the schema definition and prepares its patterns. It must be
+// called before the schema is used for validation.
+func (s *Schema) Compile() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSchema)
+	}
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("%w: at least one column is required", ErrInvalidSchema)
+	}
+
+	seen := make(map[string]bool, len(s.Columns))
+	for i := range s.Columns {
+		column := &s.Columns[i]
+		if column.Name == "" {
+			return fmt.Errorf("%w: column %d has no name", ErrInvalidSchema, i)
+		}
+		if seen[column.Name] {
+			return fmt.Errorf("%w: column %s is defined twice", ErrInvalidSchema, column.Name)
+		}
+		seen[column.Name] = true
+
+		switch column.Type {
+		case "", TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeDate:
+		default:
+			return fmt.Errorf("%w: column %s has unknown type %q", ErrInvalidSchema, column.Name, column.Type)
+		}
+
+		if column.Pattern != "" {
+			pattern, err := regexp.Compile(column.Pattern)
+			if err != nil {
+				return fmt.Errorf("%w: column %s: %v", ErrInvalidSchema, column.Name, err)
+			}
+			column.pattern = pattern
+		}
+
+		if column.Min != nil && column.Max != nil && *column.Min > *column.Max {
+			return fmt.Errorf("%w: column %s has min greater than max", ErrInvalidSchema, column.Name)
+		}
+	}
+	return nil
+}
+
+// MissingColumns returns the required columns absent from a header row.
+func (s *Schema) MissingColumns(headers []string) []string {
+	present := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		present[header] = true
+	}
+
+	var missing []string
+	for _, column := range s.Columns {
+		if column.Required && !present[column.Name] {
+			missing = append(missing, column.Name)
+		}
+	}
+	return missing
+}
+
+// Validate checks a row against every rule that does not depend on other
+// rows. Uniqueness is checked separately by a Validator.
+func (s *Schema) Validate(data map[string]interface{}) []Violation {
+	var violations []Violation
+	for i := range s.Columns {
+		if v, ok := s.Columns[i].validate(data[s.Columns[i].Name]); !ok {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+func (c *Column) validate(value interface{}) (Violation, bool) {
+	if isBlank(value) {
+		if c.Required {
+			return c.violation(CodeRequired, "value is required", nil), false
+		}
+		return Violation{}, true
+	}
+
+	text := toString(value)
+
+	var number float64
+	isNumber := false
+	switch c.Type {
+	case TypeNumber:
+		n, ok := toNumber(value)
+		if !ok {
+			return c.violation(CodeInvalidType, "value is not a number", value), false
+		}
+		number, isNumber = n, true
+	case TypeInteger:
+		n, ok := toNumber(value)
+		if !ok || n != math.Trunc(n) {
+			return c.violation(CodeInvalidType, "value is not an integer", value), false
+		}
+		number, isNumber = n, true
+	case TypeBoolean:
+		if _, ok := toBool(value); !ok {
+			return c.violation(CodeInvalidType, "value is not a boolean", value), false
+		}
+	case TypeDate:
+		if !isDate(text) {
+			return c.violation(CodeInvalidType, "value is not an ISO-8601 date", value), false
+		}
+	}
+
+	if c.pattern != nil && !c.pattern.MatchString(text) {
+		return c.violation(CodePatternMismatch, fmt.Sprintf("value does not match pattern %s", c.Pattern), value), false
+	}
+
+	if len(c.Enum) > 0 {
+		allowed := false
+		for _, option := range c.Enum {
+			if option == text {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return c.violation(CodeNotInEnum, fmt.Sprintf("value must be one of %s", strings.Join(c.Enum, ", ")), value), false
+		}
+	}
+
+	measure := float64(len([]rune(text)))
+	unit := "length"
+	if isNumber {
+		measure, unit = number, "value"
+	}
+	if c.Min != nil && measure < *c.Min {
+		return c.violation(CodeBelowMin, fmt.Sprintf("%s is below the minimum of %v", unit, *c.Min), value), false
+	}
+	if c.Max != nil && measure > *c.Max {
+		return c.violation(CodeAboveMax, fmt.Sprintf("%s is above the maximum of %v", unit, *c.Max), value), false
+	}
+
+	return Violation{}, true
+}
+
+func (c *Column) violation(code, message string, value interface{}) Violation {
+	return Violation{Column: c.Name, Code: code, Message: message, Value: value}
+}
+
+// Validator applies a schema across all rows of an upload. Unlike Validate
+// it keeps state, so CheckUnique must be called in row order from a single
+// goroutine.
+type Validator struct {
+	schema *Schema
+	seen   map[string]map[string]bool
+}
+
+func NewValidator(s *Schema) *Validator {
+	v := &Validator{schema: s, seen: make(map[string]map[string]bool)}
+	for _, column := range s.Columns {
+		if column.Unique {
+			v.seen[column.Name] = make(map[string]bool)
+		}
+	}
+	return v
+}
+
+func (v *Validator) Schema() *Schema {
+	return v.schema
+}
+
+// CheckUnique reports values already seen in an earlier row. Values of rows
+// that fail the check are not remembered.
+func (v *Validator) CheckUnique(data map[string]interface{}) []Violation {
+	if len(v.seen) == 0 {
+		return nil
+	}
+
+	var violations []Violation
+	for _, c := range v.schema.Columns {
+		seen, ok := v.seen[c.Name]
+		column := c.Name
+		value := data[column]
+		if !ok || isBlank(value) {
+			continue
+		}
+		if seen[toString(value)] {
+			violations = append(violations, Violation{
+				Column:  column,
+				Code:    CodeDuplicateValue,
+				Message: "value already appears in an earlier row",
+				Value:   value,
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+
+	for column, seen := range v.seen {
+		if value := data[column]; !isBlank(value) {
+			seen[toString(value)] = true
+		}
+	}
+	return nil
+}
+
+func isBlank(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	return false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toNumber reads a finite number. strconv also accepts "NaN", "Inf" and
+// "Infinity", which are not numbers a column can hold.
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+	default:
+		return 0, false
+	}
+}
+
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+var dateLayouts = []string{"2006-01-02", "2006-01-02T15:04:05", time.RFC3339}
+
+func isDate(value string) bool {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,623 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+)
+
+// logFileName is the name of the log within the storage directory.
+const logFileName = "store.log"
+
+// Operations recorded in the log.
+const (
+	opStage    = "stage"
+	opCommit   = "commit"
+	opAbort    = "abort"
+	opRollback = "rollback"
+)
+
+// ErrCorruptLog is returned when the storage log is damaged anywhere but at
+// its end, which a crash cannot explain.
+var ErrCorruptLog = errors.New("storage log is corrupt")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// logEntry is one write, as appended to the log.
+type logEntry struct {
+	Op           string               `json:"op"`
+	UploadID     string               `json:"uploadId,omitempty"`
+	Records      []models.Record      `json:"records,omitempty"`
+	Rejections   []models.RowError    `json:"rejections,omitempty"`
+	Upload       *models.Upload       `json:"upload,omitempty"`
+	Transactions []models.Transaction `json:"transactions,omitempty"`
+	At           *time.Time           `json:"at,omitempty"`
+}
+
+// FileStorage keeps everything in an append-only log on local disk, so that
+// it survives restarts. Each write is one line of the log, JSON behind a
+// CRC-32C checksum, synced to disk before it is acknowledged.
+//
+// Only an index of the log stays in memory: where each entry lies and how
+// many items it holds, plus the uploads themselves. Reads seek to the
+// entries a page needs and decode just those, so neither the stored data
+// nor the batches of uploads still in progress are held in memory.
+//
+// Values of record data come back as JSON reads them: numbers as float64
+// and times as RFC 3339 text, as the API returns them anyway. The directory
+// must not be shared between processes.
+type FileStorage struct {
+	mu   sync.RWMutex
+	file *os.File
+	end  int64
+
+	commits []*fileCommit
+	uploads map[string]*fileUpload
+	// staged locates what uploads that have not committed yet wrote, which
+	// reads must not serve.
+	staged map[string][]span
+
+	records      int
+	transactions int
+}
+
+var _ Store = (*FileStorage)(nil)
+
+// span locates one entry of the log and counts what it holds.
+type span struct {
+	offset       int64
+	length       int
+	records      int
+	transactions int
+	rejections   int
+}
+
+// fileCommit is what one commit made visible: the entries an upload staged
+// followed by its commit entry, in log order.
+type fileCommit struct {
+	spans        []span
+	records      int
+	transactions int
+	rolledBack   bool
+}
+
+type fileUpload struct {
+	upload     models.Upload
+	commits    []*fileCommit
+	rejections []span
+}
+
+// NewFileStorage opens the log in dir, creating both when missing, and
+// replays it. An entry cut short by a crash at the end of the log is
+// dropped; damage anywhere else fails with ErrCorruptLog.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage directory: %w", err)
+	}
+
+	path := filepath.Join(dir, logFileName)
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open storage log: %w", err)
+	}
+	if os.IsNotExist(statErr) {
+		// The new file only survives a crash once its directory entry does.
+		if err := syncDir(dir); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	s := &FileStorage{
+		file:    file,
+		uploads: make(map[string]*fileUpload),
+		staged:  make(map[string][]span),
+	}
+	if err := s.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay rebuilds the index from the log and drops a torn entry from its
+// end.
+func (s *FileStorage) replay() error {
+	reader := bufio.NewReader(s.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// The last write never completed.
+				return s.truncate()
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read storage log: %w", err)
+		}
+
+		entry, decodeErr := decodeEntry(line)
+		if decodeErr != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return s.truncate()
+			}
+			return fmt.Errorf("%w: entry at byte %d: %v", ErrCorruptLog, s.end, decodeErr)
+		}
+		if err := s.apply(entry, len(line)); err != nil {
+			return err
+		}
+	}
+
+	// Uploads still staged were cut short by a crash and never committed.
+	clear(s.staged)
+	return nil
+}
+
+// truncate drops a torn entry from the end of the log.
+func (s *FileStorage) truncate() error {
+	if err := s.file.Truncate(s.end); err != nil {
+		return fmt.Errorf("truncate storage log: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync storage log: %w", err)
+	}
+	return nil
+}
+
+// apply indexes an entry of the given length written at the end of the
+// log.
+func (s *FileStorage) apply(entry logEntry, length int) error {
+	sp := span{
+		offset:       s.end,
+		length:       length,
+		records:      len(entry.Records),
+		transactions: len(entry.Transactions),
+		rejections:   len(entry.Rejections),
+	}
+
+	switch entry.Op {
+	case opStage:
+		s.staged[entry.UploadID] = append(s.staged[entry.UploadID], sp)
+	case opCommit:
+		if entry.Upload == nil {
+			return fmt.Errorf("%w: commit entry without an upload", ErrCorruptLog)
+		}
+		id := entry.Upload.ID
+		commit := &fileCommit{spans: append(s.staged[id], sp)}
+		delete(s.staged, id)
+		for _, sp := range commit.spans {
+			commit.records += sp.records
+			commit.transactions += sp.transactions
+		}
+		s.commits = append(s.commits, commit)
+		s.records += commit.records
+		s.transactions += commit.transactions
+
+		u, ok := s.uploads[id]
+		if !ok {
+			u = &fileUpload{}
+			s.uploads[id] = u
+		}
+		u.upload = *entry.Upload
+		u.upload.Status = models.UploadStatusCommitted
+		u.commits = append(u.commits, commit)
+		u.rejections = commit.spans
+	case opAbort:
+		delete(s.staged, entry.UploadID)
+	case opRollback:
+		if entry.At == nil {
+			return fmt.Errorf("%w: rollback entry without a time", ErrCorruptLog)
+		}
+		u, ok := s.uploads[entry.UploadID]
+		if !ok {
+			return ErrNotFound
+		}
+		if u.upload.Status == models.UploadStatusRolledBack {
+			return ErrRolledBack
+		}
+		for _, commit := range u.commits {
+			if !commit.rolledBack {
+				commit.rolledBack = true
+				s.records -= commit.records
+				s.transactions -= commit.transactions
+			}
+		}
+		at := *entry.At
+		u.upload.Status = models.UploadStatusRolledBack
+		u.upload.RolledBackAt = &at
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrCorruptLog, entry.Op)
+	}
+	s.end += int64(length)
+	return nil
+}
+
+// write appends an entry to the log, syncs it and indexes it. Entries are
+// indexed in log order, so a replay rebuilds the same index.
+func (s *FileStorage) write(entry logEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writeLocked(entry)
+}
+
+func (s *FileStorage) writeLocked(entry logEntry) error {
+	line, err := encodeEntry(entry)
+	if err != nil {
+		return err
+	}
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if _, err = s.file.WriteAt(line, s.end); err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// Leave no partial entry behind that a replay would apply after the
+		// caller was told it failed.
+		s.file.Truncate(s.end)
+		return fmt.Errorf("write storage log: %w", err)
+	}
+	return s.apply(entry, len(line))
+}
+
+// encodeEntry formats an entry as a log line: the hex CRC-32C of the JSON,
+// a space, the JSON and a newline.
+func encodeEntry(entry logEntry) ([]byte, error) {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("encode storage entry: %w", err)
+	}
+	line := make([]byte, 0, 8+1+len(payload)+1)
+	line = fmt.Appendf(line, "%08x ", crc32.Checksum(payload, crcTable))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+func decodeEntry(line []byte) (logEntry, error) {
+	var entry logEntry
+	checksum, payload, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !ok {
+		return entry, errors.New("missing checksum")
+	}
+	want, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil || len(checksum) != 8 {
+		return entry, errors.New("malformed checksum")
+	}
+	if uint32(want) != crc32.Checksum(payload, crcTable) {
+		return entry, errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open storage directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync storage directory: %w", err)
+	}
+	return nil
+}
+
+// readSpan reads the entry a span locates back from the log.
+func readSpan(file *os.File, sp span) (logEntry, error) {
+	if file == nil {
+		return logEntry{}, os.ErrClosed
+	}
+	line := make([]byte, sp.length)
+	if _, err := file.ReadAt(line, sp.offset); err != nil {
+		return logEntry{}, fmt.Errorf("read storage log: %w", err)
+	}
+	entry, err := decodeEntry(line)
+	if err != nil {
+		return logEntry{}, fmt.Errorf("%w: entry at byte %d: %v", ErrCorruptLog, sp.offset, err)
+	}
+	return entry, nil
+}
+
+// readPage reads one page of the items the spans hold, where count tells
+// how many a span holds and items picks them out of its entry. Spans that
+// end before the page starts are skipped without being read.
+func readPage[T any](file *os.File, spans []span, count func(span) int, items func(logEntry) []T, limit, offset int) ([]T, error) {
+	page := make([]T, 0)
+	for _, sp := range spans {
+		if len(page) >= limit {
+			break
+		}
+		n := count(sp)
+		if offset >= n {
+			offset -= n
+			continue
+		}
+		entry, err := readSpan(file, sp)
+		if err != nil {
+			return nil, err
+		}
+		batch := items(entry)[offset:]
+		offset = 0
+		page = append(page, batch[:min(len(batch), limit-len(page))]...)
+	}
+	return page, nil
+}
+
+func spanRecords(sp span) int                           { return sp.records }
+func spanTransactions(sp span) int                      { return sp.transactions }
+func spanRejections(sp span) int                        { return sp.rejections }
+func entryRecords(e logEntry) []models.Record           { return e.Records }
+func entryTransactions(e logEntry) []models.Transaction { return e.Transactions }
+func entryRejections(e logEntry) []models.RowError      { return e.Rejections }
+
+// visible collects the spans of the commits that were not rolled back.
+func visible(commits []*fileCommit) []span {
+	var spans []span
+	for _, commit := range commits {
+		if !commit.rolledBack {
+			spans = append(spans, commit.spans...)
+		}
+	}
+	return spans
+}
+
+func (s *FileStorage) Store(records []models.Record) error {
+	return storeRecords(s, records)
+}
+
+func (s *FileStorage) List(limit, offset int) ([]models.Record, int, error) {
+	s.mu.RLock()
+	file, spans, total := s.file, visible(s.commits), s.records
+	s.mu.RUnlock()
+
+	records, err := readPage(file, spans, spanRecords, entryRecords, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+func (s *FileStorage) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.records, nil
+}
+
+func (s *FileStorage) GetByUploadID(uploadID string) ([]models.Record, error) {
+	s.mu.RLock()
+	file := s.file
+	var spans []span
+	if u, ok := s.uploads[uploadID]; ok {
+		spans = visible(u.commits)
+	}
+	s.mu.RUnlock()
+
+	return readPage(file, spans, spanRecords, entryRecords, math.MaxInt, 0)
+}
+
+func (s *FileStorage) CountByUploadID(uploadID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	if u, ok := s.uploads[uploadID]; ok {
+		for _, commit := range u.commits {
+			if !commit.rolledBack {
+				count += commit.records
+			}
+		}
+	}
+	return count, nil
+}
+
+func (s *FileStorage) ListRejections(uploadID string, limit, offset int) ([]models.RowError, int, error) {
+	s.mu.RLock()
+	file := s.file
+	u, ok := s.uploads[uploadID]
+	var spans []span
+	if ok {
+		spans = u.rejections
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	total := 0
+	for _, sp := range spans {
+		total += sp.rejections
+	}
+	rejections, err := readPage(file, spans, spanRejections, entryRejections, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rejections, total, nil
+}
+
+func (s *FileStorage) GetUpload(id string) (models.Upload, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return models.Upload{}, ErrNotFound
+	}
+	return u.upload, nil
+}
+
+func (s *FileStorage) ListUploads(filter UploadFilter, limit, offset int) ([]models.Upload, int, error) {
+	s.mu.RLock()
+	uploads := make([]models.Upload, 0)
+	for _, u := range s.uploads {
+		if filter.Match(u.upload) {
+			uploads = append(uploads, u.upload)
+		}
+	}
+	s.mu.RUnlock()
+
+	page, total := pageUploads(uploads, limit, offset)
+	return page, total, nil
+}
+
+func (s *FileStorage) ListTransactions(uploadID string, limit, offset int) ([]models.Transaction, int, error) {
+	s.mu.RLock()
+	file := s.file
+	var spans []span
+	total := 0
+	if uploadID == "" {
+		spans, total = visible(s.commits), s.transactions
+	} else if u, ok := s.uploads[uploadID]; ok {
+		spans = visible(u.commits)
+		for _, sp := range spans {
+			total += sp.transactions
+		}
+	}
+	s.mu.RUnlock()
+
+	transactions, err := readPage(file, spans, spanTransactions, entryTransactions, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// BeginUpload returns a writer that logs each batch it is handed as a stage
+// entry and the rest of the upload as a commit entry. Reads only serve the
+// upload once the commit entry is written, and a replay drops stages that
+// no commit followed.
+func (s *FileStorage) BeginUpload(ctx context.Context, uploadID string) (UploadWriter, error) {
+	return &fileUploadWriter{storage: s, ctx: ctx, uploadID: uploadID}, nil
+}
+
+type fileUploadWriter struct {
+	storage  *FileStorage
+	ctx      context.Context
+	uploadID string
+	staged   bool
+	done     bool
+}
+
+func (w *fileUploadWriter) WriteRecords(records []models.Record) error {
+	return w.stage(logEntry{Records: records})
+}
+
+func (w *fileUploadWriter) WriteTransactions(transactions []models.Transaction) error {
+	return w.stage(logEntry{Transactions: transactions})
+}
+
+func (w *fileUploadWriter) WriteRejections(rejections []models.RowError) error {
+	return w.stage(logEntry{Rejections: rejections})
+}
+
+// stage logs a stage entry, unless there is nothing in it.
+func (w *fileUploadWriter) stage(entry logEntry) error {
+	if w.done {
+		return ErrWriterClosed
+	}
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if len(entry.Records) == 0 && len(entry.Transactions) == 0 && len(entry.Rejections) == 0 {
+		return nil
+	}
+	w.staged = true
+	entry.Op, entry.UploadID = opStage, w.uploadID
+	return w.storage.write(entry)
+}
+
+func (w *fileUploadWriter) Commit(batch UploadBatch) error {
+	if w.done {
+		return ErrWriterClosed
+	}
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	err := w.storage.write(logEntry{
+		Op:           opCommit,
+		UploadID:     w.uploadID,
+		Upload:       &batch.Upload,
+		Records:      batch.Records,
+		Transactions: batch.Transactions,
+		Rejections:   batch.Rejections,
+	})
+	if err != nil {
+		return err
+	}
+	w.done = true
+	return nil
+}
+
+// Abort logs an abort entry so that a replay can let go of the staged
+// entries early; they are dropped from the index even when that write
+// fails.
+func (w *fileUploadWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	if !w.staged {
+		return nil
+	}
+	err := w.storage.write(logEntry{Op: opAbort, UploadID: w.uploadID})
+	if err != nil {
+		w.storage.mu.Lock()
+		delete(w.storage.staged, w.uploadID)
+		w.storage.mu.Unlock()
+	}
+	return err
+}
+
+func (s *FileStorage) RollbackUpload(id string, at time.Time) (models.Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only rollbacks that succeed are logged, so that a replay succeeds too.
+	u, ok := s.uploads[id]
+	if !ok {
+		return models.Upload{}, ErrNotFound
+	}
+	if u.upload.Status == models.UploadStatusRolledBack {
+		return u.upload, ErrRolledBack
+	}
+	if err := s.writeLocked(logEntry{Op: opRollback, UploadID: id, At: &at}); err != nil {
+		return models.Upload{}, err
+	}
+	return u.upload, nil
+}
+
+// Close syncs the log and closes it. Reads and writes after Close fail with
+// os.ErrClosed.
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	if syncErr != nil {
+		return fmt.Errorf("sync storage log: %w", syncErr)
+	}
+	return closeErr
+}
@@ -1,28 +1,39 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/joelovien/go-xlsx-api/internal/models"
 )
 
+// ErrNotFound is returned when a lookup refers to an unknown upload.
+var ErrNotFound = errors.New("not found")
+
 type MemoryStorage struct {
-	mu      sync.RWMutex
-	records []models.Record
+	mu         sync.RWMutex
+	records    []models.Record
+	rejections map[string][]models.RowError
+	uploads    map[string]models.Upload
+
+	transactions []models.Transaction
 }
 
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		records: make([]models.Record, 0),
+		records:    make([]models.Record, 0),
+		rejections: make(map[string][]models.RowError),
+		uploads:    make(map[string]models.Upload),
+
+		transactions: make([]models.Transaction, 0),
 	}
 }
 
 func (s *MemoryStorage) Store(records []models.Record) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.records = append(s.records, records...)
-	return nil
+	return storeRecords(s, records)
 }
 
 func (s *MemoryStorage) List(limit, offset int) ([]models.Record, int, error) {
@@ -47,11 +58,16 @@ func (s *MemoryStorage) List(limit, offset int) ([]models.Record, int, error) {
 	return result, total, nil
 }
 
-func (s *MemoryStorage) Count() int {
+func (s *MemoryStorage) Count() (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return len(s.records)
+	return len(s.records), nil
+}
+
+// Close is a no-op; the records live as long as the storage.
+func (s *MemoryStorage) Close() error {
+	return nil
 }
 
 func (s *MemoryStorage) Clear() {
@@ -59,9 +75,12 @@ func (s *MemoryStorage) Clear() {
 	defer s.mu.Unlock()
 
 	s.records = make([]models.Record, 0)
+	s.rejections = make(map[string][]models.RowError)
+	s.uploads = make(map[string]models.Upload)
+	s.transactions = make([]models.Transaction, 0)
 }
 
-func (s *MemoryStorage) GetByUploadID(uploadID string) []models.Record {
+func (s *MemoryStorage) GetByUploadID(uploadID string) ([]models.Record, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -72,5 +91,231 @@ func (s *MemoryStorage) GetByUploadID(uploadID string) []models.Record {
 		}
 	}
 
-	return result
+	return result, nil
+}
+
+func (s *MemoryStorage) CountByUploadID(uploadID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, record := range s.records {
+		if record.UploadID == uploadID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStorage) ListRejections(uploadID string, limit, offset int) ([]models.RowError, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rejections, ok := s.rejections[uploadID]
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+
+	total := len(rejections)
+	if offset >= total {
+		return []models.RowError{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	result := make([]models.RowError, end-offset)
+	copy(result, rejections[offset:end])
+
+	return result, total, nil
+}
+
+func (s *MemoryStorage) GetUpload(id string) (models.Upload, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	upload, ok := s.uploads[id]
+	if !ok {
+		return models.Upload{}, ErrNotFound
+	}
+	return upload, nil
+}
+
+func (s *MemoryStorage) ListUploads(filter UploadFilter, limit, offset int) ([]models.Upload, int, error) {
+	s.mu.RLock()
+	uploads := make([]models.Upload, 0)
+	for _, upload := range s.uploads {
+		if filter.Match(upload) {
+			uploads = append(uploads, upload)
+		}
+	}
+	s.mu.RUnlock()
+
+	page, total := pageUploads(uploads, limit, offset)
+	return page, total, nil
+}
+
+// pageUploads orders uploads newest first, ties broken by ID, and returns
+// one page of them along with their number.
+func pageUploads(uploads []models.Upload, limit, offset int) ([]models.Upload, int) {
+	sort.Slice(uploads, func(i, j int) bool {
+		if !uploads[i].CreatedAt.Equal(uploads[j].CreatedAt) {
+			return uploads[i].CreatedAt.After(uploads[j].CreatedAt)
+		}
+		return uploads[i].ID < uploads[j].ID
+	})
+
+	total := len(uploads)
+	if offset >= total {
+		return []models.Upload{}, total
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return uploads[offset:end], total
+}
+
+// ListTransactions pages through the stored transactions, of one upload
+// when uploadID is set.
+func (s *MemoryStorage) ListTransactions(uploadID string, limit, offset int) ([]models.Transaction, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transactions := s.transactions
+	if uploadID != "" {
+		transactions = make([]models.Transaction, 0)
+		for _, tx := range s.transactions {
+			if tx.UploadID == uploadID {
+				transactions = append(transactions, tx)
+			}
+		}
+	}
+
+	total := len(transactions)
+	if offset >= total {
+		return []models.Transaction{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	result := make([]models.Transaction, end-offset)
+	copy(result, transactions[offset:end])
+
+	return result, total, nil
+}
+
+// BeginUpload stages the records of an upload in memory until it commits.
+func (s *MemoryStorage) BeginUpload(ctx context.Context, uploadID string) (UploadWriter, error) {
+	return &memoryUploadWriter{storage: s, ctx: ctx}, nil
+}
+
+type memoryUploadWriter struct {
+	storage *MemoryStorage
+	ctx     context.Context
+	staged  UploadBatch
+	done    bool
+}
+
+// usable fails once the writer is closed or its context is done.
+func (w *memoryUploadWriter) usable() error {
+	if w.done {
+		return ErrWriterClosed
+	}
+	return w.ctx.Err()
+}
+
+func (w *memoryUploadWriter) WriteRecords(records []models.Record) error {
+	if err := w.usable(); err != nil {
+		return err
+	}
+	w.staged.Records = append(w.staged.Records, records...)
+	return nil
+}
+
+func (w *memoryUploadWriter) WriteTransactions(transactions []models.Transaction) error {
+	if err := w.usable(); err != nil {
+		return err
+	}
+	w.staged.Transactions = append(w.staged.Transactions, transactions...)
+	return nil
+}
+
+func (w *memoryUploadWriter) WriteRejections(rejections []models.RowError) error {
+	if err := w.usable(); err != nil {
+		return err
+	}
+	w.staged.Rejections = append(w.staged.Rejections, rejections...)
+	return nil
+}
+
+func (w *memoryUploadWriter) Commit(batch UploadBatch) error {
+	if err := w.usable(); err != nil {
+		return err
+	}
+	w.done = true
+	w.storage.commitUpload(w.staged.followedBy(batch))
+	w.staged = UploadBatch{}
+	return nil
+}
+
+func (w *memoryUploadWriter) Abort() error {
+	w.done = true
+	w.staged = UploadBatch{}
+	return nil
+}
+
+func (s *MemoryStorage) commitUpload(batch UploadBatch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, batch.Records...)
+	s.transactions = append(s.transactions, batch.Transactions...)
+	rejections := make([]models.RowError, len(batch.Rejections))
+	copy(rejections, batch.Rejections)
+	s.rejections[batch.Upload.ID] = rejections
+
+	upload := batch.Upload
+	upload.Status = models.UploadStatusCommitted
+	s.uploads[upload.ID] = upload
+}
+
+func (s *MemoryStorage) RollbackUpload(id string, at time.Time) (models.Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.uploads[id]
+	if !ok {
+		return models.Upload{}, ErrNotFound
+	}
+	if upload.Status == models.UploadStatusRolledBack {
+		return upload, ErrRolledBack
+	}
+
+	records := make([]models.Record, 0, len(s.records))
+	for _, record := range s.records {
+		if record.UploadID != id {
+			records = append(records, record)
+		}
+	}
+	s.records = records
+
+	transactions := make([]models.Transaction, 0, len(s.transactions))
+	for _, tx := range s.transactions {
+		if tx.UploadID != id {
+			transactions = append(transactions, tx)
+		}
+	}
+	s.transactions = transactions
+
+	upload.Status = models.UploadStatusRolledBack
+	upload.RolledBackAt = &at
+	s.uploads[id] = upload
+	return upload, nil
 }
@@ -0,0 +1,45 @@
+CREATE TABLE records (
+    seq        BIGSERIAL PRIMARY KEY,
+    id         TEXT        NOT NULL,
+    upload_id  TEXT        NOT NULL,
+    sheet      TEXT        NOT NULL DEFAULT '',
+    row_num    INTEGER     NOT NULL DEFAULT 0,
+    source_row INTEGER     NOT NULL DEFAULT 0,
+    source_ref TEXT        NOT NULL DEFAULT '',
+    data       JSONB       NOT NULL,
+    meta       JSONB,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX records_upload_id_idx ON records (upload_id);
+CREATE INDEX records_created_at_idx ON records (created_at);
+
+-- A report row marks an upload whose rejections were stored, even when
+-- nothing was rejected.
+CREATE TABLE rejection_reports (
+    upload_id TEXT PRIMARY KEY
+);
+
+CREATE TABLE rejections (
+    upload_id TEXT    NOT NULL REFERENCES rejection_reports (upload_id) ON DELETE CASCADE,
+    position  INTEGER NOT NULL,
+    error     JSONB   NOT NULL,
+    PRIMARY KEY (upload_id, position)
+);
+
+CREATE TABLE uploads (
+    id         TEXT PRIMARY KEY,
+    upload     JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX uploads_created_at_idx ON uploads (created_at);
+
+CREATE TABLE transactions (
+    seq        BIGSERIAL PRIMARY KEY,
+    upload_id  TEXT        NOT NULL,
+    data       JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX transactions_upload_id_idx ON transactions (upload_id);
@@ -0,0 +1,2 @@
+-- Uploads are listed by status, newest first.
+CREATE INDEX uploads_status_idx ON uploads ((upload->>'status'), created_at DESC);
@@ -0,0 +1,36 @@
+-- Batches of uploads still being parsed wait in the staging tables, each
+-- written on its own, until the upload commits and moves them over in one
+-- short transaction. staged_at lets abandoned batches be cleaned up.
+CREATE TABLE staged_records (
+    seq        BIGSERIAL PRIMARY KEY,
+    id         TEXT        NOT NULL,
+    upload_id  TEXT        NOT NULL,
+    sheet      TEXT        NOT NULL DEFAULT '',
+    row_num    INTEGER     NOT NULL DEFAULT 0,
+    source_row INTEGER     NOT NULL DEFAULT 0,
+    source_ref TEXT        NOT NULL DEFAULT '',
+    data       JSONB       NOT NULL,
+    meta       JSONB,
+    created_at TIMESTAMPTZ NOT NULL,
+    staged_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX staged_records_upload_id_idx ON staged_records (upload_id);
+
+CREATE TABLE staged_transactions (
+    seq        BIGSERIAL PRIMARY KEY,
+    upload_id  TEXT        NOT NULL,
+    data       JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    staged_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX staged_transactions_upload_id_idx ON staged_transactions (upload_id);
+
+CREATE TABLE staged_rejections (
+    upload_id TEXT        NOT NULL,
+    position  INTEGER     NOT NULL,
+    error     JSONB       NOT NULL,
+    staged_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (upload_id, position)
+);
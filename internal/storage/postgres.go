@@ -0,0 +1,587 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock key that keeps replicas starting
+// together from migrating at the same time.
+const migrationLock = 0x786c7378
+
+// queryTimeout bounds each storage call, bulk inserts included.
+const queryTimeout = 2 * time.Minute
+
+// PostgresStorage keeps everything in PostgreSQL. Record data and cell
+// metadata are JSONB columns; rejections, uploads and transactions are
+// stored whole as JSONB next to the columns they are looked up by. Records
+// and transactions are bulk inserted with COPY, into staging tables while an
+// upload is parsed.
+type PostgresStorage struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStorage)(nil)
+
+// NewPostgresStorage connects to the database at url and applies the
+// migrations it has not seen yet.
+func NewPostgresStorage(ctx context.Context, url string) (*PostgresStorage, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+
+	s := &PostgresStorage{pool: pool}
+	if err := s.migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	if err := s.deleteAbandoned(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate applies the embedded migrations in the order of their version
+// prefix, each in its own transaction.
+func (s *PostgresStorage) migrate(ctx context.Context) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate postgres: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return fmt.Errorf("migrate postgres: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("migrate postgres: %w", err)
+	}
+
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		prefix, _, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s: file name must start with its version", base)
+		}
+		sql, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			var applied bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied); err != nil || applied {
+				return err
+			}
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", base, err)
+		}
+	}
+	return nil
+}
+
+// copier bulk inserts, on its own or within a transaction.
+type copier interface {
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error)
+}
+
+func copyRecords(ctx context.Context, db copier, table string, records []models.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	_, err := db.CopyFrom(ctx, pgx.Identifier{table},
+		[]string{"id", "upload_id", "sheet", "row_num", "source_row", "source_ref", "data", "meta", "created_at"},
+		pgx.CopyFromSlice(len(records), func(i int) ([]any, error) {
+			r := records[i]
+			data, err := json.Marshal(r.Data)
+			if err != nil {
+				return nil, fmt.Errorf("record %s: %w", r.ID, err)
+			}
+			var meta []byte
+			if r.Meta != nil {
+				if meta, err = json.Marshal(r.Meta); err != nil {
+					return nil, fmt.Errorf("record %s: %w", r.ID, err)
+				}
+			}
+			return []any{r.ID, r.UploadID, r.Sheet, r.Row, r.SourceRow, r.SourceRef, data, meta, r.CreatedAt}, nil
+		}))
+	return err
+}
+
+const selectRecords = `SELECT id, upload_id, sheet, row_num, source_row, source_ref, data, meta, created_at FROM records`
+
+func (s *PostgresStorage) Store(records []models.Record) error {
+	return storeRecords(s, records)
+}
+
+func (s *PostgresStorage) List(limit, offset int) ([]models.Record, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	total, err := s.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+	records, err := s.queryRecords(ctx, selectRecords+` ORDER BY seq LIMIT $1 OFFSET $2`, limit, offset)
+	return records, total, err
+}
+
+func (s *PostgresStorage) Count() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var count int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM records`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count records: %w", err)
+	}
+	return count, nil
+}
+
+func (s *PostgresStorage) GetByUploadID(uploadID string) ([]models.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	return s.queryRecords(ctx, selectRecords+` WHERE upload_id = $1 ORDER BY seq`, uploadID)
+}
+
+func (s *PostgresStorage) CountByUploadID(uploadID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var count int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM records WHERE upload_id = $1`, uploadID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count records: %w", err)
+	}
+	return count, nil
+}
+
+func (s *PostgresStorage) queryRecords(ctx context.Context, sql string, args ...any) ([]models.Record, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list records: %w", err)
+	}
+	records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Record, error) {
+		var r models.Record
+		var data, meta []byte
+		if err := row.Scan(&r.ID, &r.UploadID, &r.Sheet, &r.Row, &r.SourceRow, &r.SourceRef, &data, &meta, &r.CreatedAt); err != nil {
+			return r, err
+		}
+		r.CreatedAt = r.CreatedAt.UTC()
+		if err := json.Unmarshal(data, &r.Data); err != nil {
+			return r, err
+		}
+		if meta != nil {
+			if err := json.Unmarshal(meta, &r.Meta); err != nil {
+				return r, err
+			}
+		}
+		return r, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list records: %w", err)
+	}
+	return records, nil
+}
+
+// startRejectionReport replaces the rejection report of an upload with an
+// empty one.
+func startRejectionReport(ctx context.Context, tx pgx.Tx, uploadID string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM rejection_reports WHERE upload_id = $1`, uploadID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO rejection_reports (upload_id) VALUES ($1)`, uploadID)
+	return err
+}
+
+// copyRejections appends rejections to those of an upload, numbering them
+// from position on.
+func copyRejections(ctx context.Context, db copier, table, uploadID string, position int, rejections []models.RowError) error {
+	if len(rejections) == 0 {
+		return nil
+	}
+	_, err := db.CopyFrom(ctx, pgx.Identifier{table}, []string{"upload_id", "position", "error"},
+		pgx.CopyFromSlice(len(rejections), func(i int) ([]any, error) {
+			data, err := json.Marshal(rejections[i])
+			return []any{uploadID, position + i, data}, err
+		}))
+	return err
+}
+
+func (s *PostgresStorage) ListRejections(uploadID string, limit, offset int) ([]models.RowError, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var total int
+	err := s.pool.QueryRow(ctx, `SELECT (SELECT count(*) FROM rejections WHERE upload_id = $1)
+		FROM rejection_reports WHERE upload_id = $1`, uploadID).Scan(&total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("list rejections: %w", err)
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT error FROM rejections WHERE upload_id = $1
+		ORDER BY position LIMIT $2 OFFSET $3`, uploadID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list rejections: %w", err)
+	}
+	rejections, err := collectJSON[models.RowError](rows)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list rejections: %w", err)
+	}
+	return rejections, total, nil
+}
+
+func writeUpload(ctx context.Context, tx pgx.Tx, upload models.Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO uploads (id, upload, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET upload = EXCLUDED.upload, created_at = EXCLUDED.created_at`,
+		upload.ID, data, upload.CreatedAt)
+	return err
+}
+
+func (s *PostgresStorage) GetUpload(id string) (models.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var upload models.Upload
+	var data []byte
+	err := s.pool.QueryRow(ctx, `SELECT upload FROM uploads WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return upload, ErrNotFound
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &upload)
+	}
+	if err != nil {
+		return upload, fmt.Errorf("get upload: %w", err)
+	}
+	return upload, nil
+}
+
+func (s *PostgresStorage) ListUploads(filter UploadFilter, limit, offset int) ([]models.Upload, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	where := `WHERE ($1 = '' OR upload->>'status' = $1)
+		AND ($2::timestamptz IS NULL OR created_at >= $2)
+		AND ($3::timestamptz IS NULL OR created_at < $3)`
+	args := []any{filter.Status, nullTime(filter.From), nullTime(filter.To)}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM uploads `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("list uploads: %w", err)
+	}
+	rows, err := s.pool.Query(ctx, `SELECT upload FROM uploads `+where+`
+		ORDER BY created_at DESC, id LIMIT $4 OFFSET $5`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list uploads: %w", err)
+	}
+	uploads, err := collectJSON[models.Upload](rows)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list uploads: %w", err)
+	}
+	return uploads, total, nil
+}
+
+// nullTime passes the zero time as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func copyTransactions(ctx context.Context, db copier, table string, transactions []models.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	_, err := db.CopyFrom(ctx, pgx.Identifier{table}, []string{"upload_id", "data", "created_at"},
+		pgx.CopyFromSlice(len(transactions), func(i int) ([]any, error) {
+			transaction := transactions[i]
+			data, err := json.Marshal(transaction)
+			return []any{transaction.UploadID, data, transaction.CreatedAt}, err
+		}))
+	return err
+}
+
+// ListTransactions pages through the stored transactions, of one upload
+// when uploadID is set.
+func (s *PostgresStorage) ListTransactions(uploadID string, limit, offset int) ([]models.Transaction, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	filter := `WHERE $1 = '' OR upload_id = $1`
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM transactions `+filter, uploadID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("list transactions: %w", err)
+	}
+	rows, err := s.pool.Query(ctx, `SELECT data FROM transactions `+filter+` ORDER BY seq LIMIT $2 OFFSET $3`, uploadID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list transactions: %w", err)
+	}
+	transactions, err := collectJSON[models.Transaction](rows)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list transactions: %w", err)
+	}
+	return transactions, total, nil
+}
+
+// BeginUpload returns a writer that stages each batch on its own, holding
+// no connection between them, and moves the staged batches over with the
+// rest of the upload in one transaction at Commit. Abort deletes them, and
+// batches a crash left behind are deleted when the store next opens.
+func (s *PostgresStorage) BeginUpload(ctx context.Context, uploadID string) (UploadWriter, error) {
+	return &postgresUploadWriter{pool: s.pool, ctx: ctx, uploadID: uploadID}, nil
+}
+
+type postgresUploadWriter struct {
+	pool     *pgxpool.Pool
+	ctx      context.Context
+	uploadID string
+
+	// staged is set once a batch may have been staged, and rejections
+	// counts the staged rejections.
+	staged     bool
+	rejections int
+	done       bool
+}
+
+func (w *postgresUploadWriter) WriteRecords(records []models.Record) error {
+	return w.stage(func(ctx context.Context) error {
+		return copyRecords(ctx, w.pool, "staged_records", records)
+	})
+}
+
+func (w *postgresUploadWriter) WriteTransactions(transactions []models.Transaction) error {
+	return w.stage(func(ctx context.Context) error {
+		return copyTransactions(ctx, w.pool, "staged_transactions", transactions)
+	})
+}
+
+func (w *postgresUploadWriter) WriteRejections(rejections []models.RowError) error {
+	err := w.stage(func(ctx context.Context) error {
+		return copyRejections(ctx, w.pool, "staged_rejections", w.uploadID, w.rejections, rejections)
+	})
+	if err == nil {
+		w.rejections += len(rejections)
+	}
+	return err
+}
+
+// stage writes a batch to the staging tables.
+func (w *postgresUploadWriter) stage(write func(context.Context) error) error {
+	if w.done {
+		return ErrWriterClosed
+	}
+	ctx, cancel := context.WithTimeout(w.ctx, queryTimeout)
+	defer cancel()
+
+	w.staged = true
+	if err := write(ctx); err != nil {
+		return fmt.Errorf("stage upload: %w", err)
+	}
+	return nil
+}
+
+func (w *postgresUploadWriter) Commit(batch UploadBatch) error {
+	if w.done {
+		return ErrWriterClosed
+	}
+	ctx, cancel := context.WithTimeout(w.ctx, queryTimeout)
+	defer cancel()
+
+	upload := batch.Upload
+	upload.Status = models.UploadStatusCommitted
+	err := pgx.BeginFunc(ctx, w.pool, func(tx pgx.Tx) error {
+		if err := startRejectionReport(ctx, tx, w.uploadID); err != nil {
+			return err
+		}
+		if w.staged {
+			if err := moveStaged(ctx, tx, w.uploadID); err != nil {
+				return err
+			}
+		}
+		if err := copyRecords(ctx, tx, "records", batch.Records); err != nil {
+			return err
+		}
+		if err := copyTransactions(ctx, tx, "transactions", batch.Transactions); err != nil {
+			return err
+		}
+		if err := copyRejections(ctx, tx, "rejections", w.uploadID, w.rejections, batch.Rejections); err != nil {
+			return err
+		}
+		return writeUpload(ctx, tx, upload)
+	})
+	if err != nil {
+		return fmt.Errorf("commit upload: %w", err)
+	}
+	w.done = true
+	w.staged = false
+	return nil
+}
+
+// Abort deletes the staged batches, even once the writer's context is done.
+func (w *postgresUploadWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	if !w.staged {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(w.ctx), queryTimeout)
+	defer cancel()
+
+	for _, table := range stagingTables {
+		if _, err := w.pool.Exec(ctx, `DELETE FROM `+table+` WHERE upload_id = $1`, w.uploadID); err != nil {
+			return fmt.Errorf("abort upload: %w", err)
+		}
+	}
+	return nil
+}
+
+// stagingTables hold the batches of uploads that have not committed yet.
+var stagingTables = []string{"staged_records", "staged_transactions", "staged_rejections"}
+
+// abandonedAfter is how long staged batches are kept before they are taken
+// for the leftovers of a crash. No upload is parsed for that long.
+const abandonedAfter = 24 * time.Hour
+
+// moveStaged moves the staged batches of an upload to the tables readers
+// see, in the order they were staged.
+func moveStaged(ctx context.Context, tx pgx.Tx, uploadID string) error {
+	moves := []string{
+		`INSERT INTO records (id, upload_id, sheet, row_num, source_row, source_ref, data, meta, created_at)
+			SELECT id, upload_id, sheet, row_num, source_row, source_ref, data, meta, created_at
+			FROM staged_records WHERE upload_id = $1 ORDER BY seq`,
+		`INSERT INTO transactions (upload_id, data, created_at)
+			SELECT upload_id, data, created_at FROM staged_transactions WHERE upload_id = $1 ORDER BY seq`,
+		`INSERT INTO rejections (upload_id, position, error)
+			SELECT upload_id, position, error FROM staged_rejections WHERE upload_id = $1`,
+	}
+	for _, move := range moves {
+		if _, err := tx.Exec(ctx, move, uploadID); err != nil {
+			return err
+		}
+	}
+	for _, table := range stagingTables {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE upload_id = $1`, uploadID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAbandoned deletes staged batches older than abandonedAfter.
+func (s *PostgresStorage) deleteAbandoned(ctx context.Context) error {
+	for _, table := range stagingTables {
+		if _, err := s.pool.Exec(ctx, `DELETE FROM `+table+` WHERE staged_at < now() - $1::interval`, abandonedAfter); err != nil {
+			return fmt.Errorf("delete abandoned uploads: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) RollbackUpload(id string, at time.Time) (models.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var upload models.Upload
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var data []byte
+		// The row lock makes a concurrent rollback wait and then see this one.
+		if err := tx.QueryRow(ctx, `SELECT upload FROM uploads WHERE id = $1 FOR UPDATE`, id).Scan(&data); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if err := json.Unmarshal(data, &upload); err != nil {
+			return err
+		}
+		if upload.Status == models.UploadStatusRolledBack {
+			return ErrRolledBack
+		}
+
+		for _, table := range []string{"records", "transactions"} {
+			if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE upload_id = $1`, id); err != nil {
+				return err
+			}
+		}
+		upload.Status = models.UploadStatusRolledBack
+		upload.RolledBackAt = &at
+		return writeUpload(ctx, tx, upload)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return models.Upload{}, err
+	}
+	if errors.Is(err, ErrRolledBack) {
+		return upload, err
+	}
+	if err != nil {
+		return models.Upload{}, fmt.Errorf("roll back upload: %w", err)
+	}
+	return upload, nil
+}
+
+// Close waits for the queries in flight and closes the connections.
+func (s *PostgresStorage) Close() error {
+	s.pool.Close()
+	return nil
+}
+
+// collectJSON decodes rows of a single JSONB column.
+func collectJSON[T any](rows pgx.Rows) ([]T, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (T, error) {
+		var v T
+		var data []byte
+		if err := row.Scan(&data); err != nil {
+			return v, err
+		}
+		return v, json.Unmarshal(data, &v)
+	})
+}
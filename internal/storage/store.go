@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joelovien/go-xlsx-api/internal/config"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+)
+
+// Backend names accepted by Open.
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendPostgres = "postgres"
+)
+
+// ErrUnknownBackend is returned by Open for backends it does not know.
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+// ErrRolledBack is returned when rolling back an upload a second time.
+var ErrRolledBack = errors.New("upload already rolled back")
+
+// ErrWriterClosed is returned by an UploadWriter used after Commit or Abort.
+var ErrWriterClosed = errors.New("upload writer already closed")
+
+// UploadFilter narrows a listing of uploads; zero fields match every
+// upload. From and To bound the creation time, From included and To
+// excluded.
+type UploadFilter struct {
+	Status string
+	From   time.Time
+	To     time.Time
+}
+
+// Match reports whether the filter lets an upload through.
+func (f UploadFilter) Match(upload models.Upload) bool {
+	if f.Status != "" && upload.Status != f.Status {
+		return false
+	}
+	if !f.From.IsZero() && upload.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !upload.CreatedAt.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// UploadBatch is everything an upload stores.
+type UploadBatch struct {
+	Upload       models.Upload
+	Records      []models.Record
+	Transactions []models.Transaction
+	Rejections   []models.RowError
+}
+
+// followedBy returns the batch with the records, transactions and
+// rejections of next appended, under the upload of next.
+func (b UploadBatch) followedBy(next UploadBatch) UploadBatch {
+	return UploadBatch{
+		Upload:       next.Upload,
+		Records:      append(b.Records, next.Records...),
+		Transactions: append(b.Transactions, next.Transactions...),
+		Rejections:   append(b.Rejections, next.Rejections...),
+	}
+}
+
+// Store is what the API keeps of its uploads. Implementations must be safe
+// for concurrent use. Lookups of a single upload return ErrNotFound when it
+// is unknown.
+type Store interface {
+	// Store appends parsed records, committing those of each upload they
+	// belong to as one upload.
+	Store(records []models.Record) error
+	// List pages through all records in the order they were stored, and
+	// returns the total number of records.
+	List(limit, offset int) ([]models.Record, int, error)
+	Count() (int, error)
+	GetByUploadID(uploadID string) ([]models.Record, error)
+	// CountByUploadID returns how many records of an upload are stored,
+	// without reading them.
+	CountByUploadID(uploadID string) (int, error)
+
+	// ListRejections pages through the rejected rows of an upload. Every
+	// committed upload has a report, empty when nothing was rejected.
+	ListRejections(uploadID string, limit, offset int) ([]models.RowError, int, error)
+
+	GetUpload(id string) (models.Upload, error)
+	// ListUploads pages through the uploads the filter lets through, newest
+	// first, and returns how many there are.
+	ListUploads(filter UploadFilter, limit, offset int) ([]models.Upload, int, error)
+
+	// ListTransactions pages through the transactions of one upload, or of
+	// all of them when uploadID is empty.
+	ListTransactions(uploadID string, limit, offset int) ([]models.Transaction, int, error)
+
+	// BeginUpload starts writing an upload whose records arrive in batches
+	// while it is parsed. The writer works under ctx, usually that of the
+	// request, except that Abort still cleans up once ctx is done.
+	BeginUpload(ctx context.Context, uploadID string) (UploadWriter, error)
+	// RollbackUpload removes the records and transactions of an upload at
+	// once, marks it rolled back at the given time and returns it. The
+	// rejection report is kept with the upload. Rolling back twice fails
+	// with ErrRolledBack.
+	RollbackUpload(id string, at time.Time) (models.Upload, error)
+
+	// Close releases the backend. The store must not be used afterwards.
+	Close() error
+}
+
+var _ Store = (*MemoryStorage)(nil)
+
+// UploadWriter writes one upload. Records, transactions and rejections are
+// written in batches as they are parsed, so that no more than a batch of
+// them has to be held in memory; readers see none of them until Commit.
+// Abort discards what was written and is a no-op after Commit, so it can be
+// deferred. A writer is not safe for concurrent use.
+type UploadWriter interface {
+	WriteRecords(records []models.Record) error
+	WriteTransactions(transactions []models.Transaction) error
+	WriteRejections(rejections []models.RowError) error
+	// Commit stores the upload, marked committed, with the records,
+	// transactions and rejections written so far followed by those of the
+	// batch. Readers see all of them or none.
+	Commit(batch UploadBatch) error
+	Abort() error
+}
+
+// CommitUpload stores a whole upload through a single writer.
+func CommitUpload(ctx context.Context, s Store, batch UploadBatch) error {
+	writer, err := s.BeginUpload(ctx, batch.Upload.ID)
+	if err != nil {
+		return err
+	}
+	defer writer.Abort()
+	return writer.Commit(batch)
+}
+
+// storeRecords commits records as uploads of their own, one per upload they
+// belong to, in the order the uploads first appear.
+func storeRecords(s Store, records []models.Record) error {
+	var order []string
+	byUpload := make(map[string][]models.Record)
+	for _, record := range records {
+		if _, ok := byUpload[record.UploadID]; !ok {
+			order = append(order, record.UploadID)
+		}
+		byUpload[record.UploadID] = append(byUpload[record.UploadID], record)
+	}
+	for _, id := range order {
+		batch := UploadBatch{
+			Upload:  models.Upload{ID: id, RowsAccepted: len(byUpload[id]), CreatedAt: time.Now().UTC()},
+			Records: byUpload[id],
+		}
+		if err := CommitUpload(context.Background(), s, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open creates the storage backend selected by the configuration.
+func Open(cfg *config.Config) (Store, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(cfg.StorageBackend)); backend {
+	case "", BackendMemory:
+		return NewMemoryStorage(), nil
+	case BackendFile:
+		store, err := NewFileStorage(cfg.StoragePath)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case BackendPostgres:
+		if cfg.DatabaseURL == "" {
+			return nil, errors.New("postgres storage needs DATABASE_URL")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		defer cancel()
+		store, err := NewPostgresStorage(ctx, cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, backend)
+	}
+}
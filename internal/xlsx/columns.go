@@ -0,0 +1,278 @@
+package xlsx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// DuplicatePolicy decides what happens when several columns end up with the
+// same header.
+type DuplicatePolicy int
+
+const (
+	// DuplicatesSuffix renames repeats to "Amount_2", "Amount_3", ...
+	DuplicatesSuffix DuplicatePolicy = iota
+	// DuplicatesReject refuses the upload.
+	DuplicatesReject
+	// DuplicatesArray stores the values of all repeats as one array.
+	DuplicatesArray
+)
+
+// BlankPolicy decides what happens to columns without a header.
+type BlankPolicy int
+
+const (
+	// BlankDrop leaves columns without a header out of records.
+	BlankDrop BlankPolicy = iota
+	// BlankKeep names them after their column letter, e.g. "column_F".
+	BlankKeep
+)
+
+// Header conflict resolutions reported on a sheet summary.
+const (
+	ResolutionSuffixed = "suffixed"
+	ResolutionArray    = "array"
+)
+
+var (
+	// ErrDuplicateHeaders is returned under DuplicatesReject when a sheet
+	// has repeated headers.
+	ErrDuplicateHeaders = errors.New("duplicate headers")
+
+	ErrInvalidDuplicatePolicy = errors.New("duplicateHeaders must be one of suffix, reject, array")
+	ErrInvalidBlankPolicy     = errors.New("blankHeaders must be one of drop, keep")
+)
+
+// ParseDuplicatePolicy parses the value of the "duplicateHeaders" upload
+// parameter.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "suffix":
+		return DuplicatesSuffix, nil
+	case "reject":
+		return DuplicatesReject, nil
+	case "array":
+		return DuplicatesArray, nil
+	default:
+		return DuplicatesSuffix, ErrInvalidDuplicatePolicy
+	}
+}
+
+// ParseBlankPolicy parses the value of the "blankHeaders" upload parameter.
+func ParseBlankPolicy(value string) (BlankPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "drop":
+		return BlankDrop, nil
+	case "keep":
+		return BlankKeep, nil
+	default:
+		return BlankDrop, ErrInvalidBlankPolicy
+	}
+}
+
+// columnSet holds the record key of every column of a sheet.
+type columnSet struct {
+	// names has one entry per header cell; "" drops the column.
+	names []string
+	// arrays lists the names whose values are collected into an array.
+	arrays map[string]bool
+	// extra names columns to the right of the header row, or is nil when
+	// they are dropped.
+	extra func(col int) string
+	// index maps each name to its first column.
+	index map[string]int
+}
+
+// width is the number of cells a row needs to be handed to parseRow.
+func (cs *columnSet) width(row []cell) int {
+	if cs.extra != nil && len(row) > len(cs.names) {
+		return len(row)
+	}
+	return len(cs.names)
+}
+
+// blankColumnName is the name given to a column without a header.
+func blankColumnName(col int) string {
+	name, _ := excelize.ColumnNumberToName(col + 1)
+	return "column_" + name
+}
+
+// buildColumns names the columns of a sheet from its header row, applying
+// the blank header policy, the hidden cell policy, the mapping profile and
+// the duplicate policy in that order.
+func buildColumns(headers []string, headerRow int, hidden map[int]bool, opts Options) (*columnSet, []models.HeaderConflict, error) {
+	names := make([]string, len(headers))
+	copy(names, headers)
+
+	if opts.BlankHeaders == BlankKeep {
+		for i, name := range names {
+			if name == "" {
+				names[i] = blankColumnName(i)
+			}
+		}
+	}
+
+	exclude := opts.Hidden == HiddenExclude
+	if exclude {
+		for col := range hidden {
+			if col < len(names) {
+				names[col] = ""
+			}
+		}
+	}
+
+	if opts.Profile != nil {
+		names = opts.Profile.Apply(names)
+	}
+
+	// Columns to the right of the header row are named as they turn up, by
+	// extraName. Those whose name clashes with another column's are named
+	// here instead, along with the blank columns before them, so that the
+	// duplicate policy sees them like any other column.
+	extraName := func(col int) string {
+		if exclude && hidden[col] {
+			return ""
+		}
+		name := blankColumnName(col)
+		if opts.Profile != nil {
+			name = opts.Profile.Apply([]string{name})[0]
+		}
+		return name
+	}
+	if opts.BlankHeaders == BlankKeep {
+		last := lastClashingColumn(names, opts, extraName)
+		for col := len(names); col <= last; col++ {
+			names = append(names, extraName(col))
+		}
+	}
+
+	cs := &columnSet{names: names, index: make(map[string]int, len(names))}
+
+	positions := make(map[string][]int)
+	var order []string
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		if _, ok := positions[name]; !ok {
+			order = append(order, name)
+		}
+		positions[name] = append(positions[name], i)
+	}
+
+	var conflicts []models.HeaderConflict
+	for _, name := range order {
+		cols := positions[name]
+		if len(cols) < 2 {
+			continue
+		}
+
+		conflict := models.HeaderConflict{Header: name}
+		for _, col := range cols {
+			ref, _ := excelize.CoordinatesToCellName(col+1, headerRow)
+			conflict.Cells = append(conflict.Cells, ref)
+		}
+
+		switch opts.DuplicateHeaders {
+		case DuplicatesReject:
+			return nil, nil, fmt.Errorf("%w: %s appears in %s", ErrDuplicateHeaders, name, strings.Join(conflict.Cells, ", "))
+		case DuplicatesArray:
+			if cs.arrays == nil {
+				cs.arrays = make(map[string]bool)
+			}
+			cs.arrays[name] = true
+			conflict.Resolution = ResolutionArray
+			conflict.Names = []string{name}
+		default:
+			conflict.Resolution = ResolutionSuffixed
+			conflict.Names = []string{name}
+			for n, col := range cols[1:] {
+				suffixed := uniqueName(name, n+2, positions)
+				positions[suffixed] = []int{col}
+				names[col] = suffixed
+				conflict.Names = append(conflict.Names, suffixed)
+			}
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	for i, name := range names {
+		if _, ok := cs.index[name]; !ok && name != "" {
+			cs.index[name] = i
+		}
+	}
+
+	if opts.BlankHeaders == BlankKeep {
+		cs.extra = extraName
+	}
+
+	return cs, conflicts, nil
+}
+
+// lastClashingColumn returns the last column right of the header row whose
+// generated name is taken by a header, or by another such column, or -1.
+// Only names that read as a generated name, or profile aliases that do,
+// can lead to a clash, so those are the columns checked.
+func lastClashingColumn(names []string, opts Options, extraName func(col int) string) int {
+	candidates := make(map[int]bool)
+	for _, name := range names {
+		if col, ok := generatedColumn(name); ok {
+			candidates[col] = true
+		}
+	}
+	if opts.Profile != nil {
+		for _, field := range opts.Profile.Fields {
+			for _, alias := range append([]string{field.Name}, field.Aliases...) {
+				if col, ok := generatedColumn(alias); ok {
+					candidates[col] = true
+				}
+			}
+		}
+	}
+
+	taken := make(map[string]int)
+	for _, name := range names {
+		taken[name]++
+	}
+	for col := range candidates {
+		if col >= len(names) {
+			taken[extraName(col)]++
+		}
+	}
+
+	last := -1
+	for col := range candidates {
+		if name := extraName(col); col >= len(names) && col > last && name != "" && taken[name] > 1 {
+			last = col
+		}
+	}
+	return last
+}
+
+// generatedColumn reads the column of a name shaped like those given to
+// columns without a header, in any letter case.
+func generatedColumn(name string) (int, bool) {
+	if len(name) <= len("column_") || !strings.EqualFold(name[:len("column_")], "column_") {
+		return 0, false
+	}
+	col, err := excelize.ColumnNameToNumber(name[len("column_"):])
+	if err != nil {
+		return 0, false
+	}
+	return col - 1, true
+}
+
+// uniqueName returns name_n, counting n up past any name already taken.
+func uniqueName(name string, n int, taken map[string][]int) string {
+	for {
+		candidate := fmt.Sprintf("%s_%d", name, n)
+		if _, ok := taken[candidate]; !ok {
+			return candidate
+		}
+		n++
+	}
+}
@@ -0,0 +1,82 @@
+package xlsx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	// ErrEncrypted is returned for password-protected files when no password
+	// was supplied, or when the file uses encryption that cannot be read.
+	ErrEncrypted = errors.New("file is encrypted")
+
+	// ErrWrongPassword is returned when the supplied password does not
+	// decrypt the file.
+	ErrWrongPassword = errors.New("wrong password")
+)
+
+// Encrypted .xlsx files are not zip packages but compound files holding the
+// encryption parameters and the encrypted package as two streams.
+const (
+	encryptionInfoStream   = "EncryptionInfo"
+	encryptedPackageStream = "EncryptedPackage"
+)
+
+// isEncryptedPackage reports whether source is a compound file wrapping an
+// encrypted OOXML package, as opposed to a legacy .xls workbook.
+func isEncryptedPackage(source io.ReaderAt) bool {
+	header := make([]byte, len(cfbSignature))
+	if n, _ := source.ReadAt(header, 0); n < len(header) || !bytes.Equal(header, cfbSignature) {
+		return false
+	}
+
+	doc, err := mscfb.New(source)
+	if err != nil {
+		return false
+	}
+	for _, file := range doc.File {
+		if file.Name == encryptedPackageStream {
+			return true
+		}
+	}
+	return false
+}
+
+// decryptPackage decrypts an encrypted OOXML package with excelize. The
+// decrypted package is held in memory, as excelize only decrypts whole
+// buffers.
+func decryptPackage(source io.ReaderAt, size int64, password string) (*bytes.Reader, error) {
+	if password == "" {
+		return nil, fmt.Errorf("%w: a password is required", ErrEncrypted)
+	}
+
+	raw := make([]byte, size)
+	if _, err := source.ReadAt(raw, 0); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+
+	pkg, err := excelize.Decrypt(raw, &excelize.Options{Password: password})
+	switch {
+	case errors.Is(err, excelize.ErrUnsupportedEncryptMechanism), errors.Is(err, excelize.ErrUnknownEncryptMechanism):
+		return nil, fmt.Errorf("%w: %v", ErrEncrypted, err)
+	case errors.Is(err, excelize.ErrPasswordLengthInvalid):
+		return nil, ErrWrongPassword
+	case err != nil:
+		return nil, fmt.Errorf("failed to decrypt file: %w", err)
+	case len(pkg) == 0:
+		// Extensible encryption is reported as an empty package.
+		return nil, fmt.Errorf("%w: %v", ErrEncrypted, excelize.ErrUnsupportedEncryptMechanism)
+	}
+
+	// A wrong password yields garbage rather than an error, so the result
+	// is checked for the zip signature of a package.
+	if !bytes.HasPrefix(pkg, zipSignature) {
+		return nil, ErrWrongPassword
+	}
+	return bytes.NewReader(pkg), nil
+}
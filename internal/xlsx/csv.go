@@ -0,0 +1,285 @@
+package xlsx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	// csvSheetName is the name of the single sheet of a delimited file.
+	csvSheetName = "Sheet1"
+
+	// csvSniffBytes is how much of a file is inspected to detect its
+	// encoding and delimiter.
+	csvSniffBytes = 64 * 1024
+
+	// csvSniffLines is the number of lines compared when detecting the
+	// delimiter.
+	csvSniffLines = 20
+)
+
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// csvWorkbook presents a CSV or TSV file as a workbook with one sheet. Its
+// cells are typed from their text by csvCell.
+type csvWorkbook struct {
+	source    io.ReaderAt
+	size      int64
+	encoding  encoding.Encoding
+	delimiter rune
+}
+
+func openCSVWorkbook(source io.ReaderAt, size int64, tabs bool) (*csvWorkbook, error) {
+	sample := make([]byte, csvSniffBytes)
+	n, err := source.ReadAt(sample, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	sample = sample[:n]
+
+	wb := &csvWorkbook{
+		source:    source,
+		size:      size,
+		encoding:  detectEncoding(sample),
+		delimiter: '\t',
+	}
+
+	text, _, err := transform.Bytes(wb.encoding.NewDecoder(), sample)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode file: %w", err)
+	}
+	if !looksLikeText(text) {
+		return nil, fmt.Errorf("%w: the file is neither a workbook nor text", ErrUnsupportedFormat)
+	}
+	if !tabs {
+		wb.delimiter = sniffDelimiter(text, int64(n) < size)
+	}
+
+	return wb, nil
+}
+
+// csvMaxControlShare is the share of control characters, in percent, above
+// which a file is taken for binary rather than text.
+const csvMaxControlShare = 1
+
+// looksLikeText reports whether decoded text is free of what binary files
+// such as PDFs and images are full of: NUL characters, other control
+// characters and bytes the encoding cannot decode. Stray control characters
+// do turn up in exports, so a few are tolerated.
+func looksLikeText(text []byte) bool {
+	controls, runes := 0, 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRune(text)
+		text = text[size:]
+		runes++
+
+		switch {
+		case r == 0:
+			return false
+		case r == '\t', r == '\n', r == '\r', r == '\f':
+		case r < 0x20, r == 0x7F, r == utf8.RuneError:
+			controls++
+		}
+	}
+	return controls*100 <= runes*csvMaxControlShare
+}
+
+// detectEncoding picks the text encoding of a file from its byte order mark
+// or, failing that, from its content: UTF-16 without a mark is recognised by
+// its zero bytes, valid UTF-8 is taken as such and anything else is read as
+// Windows-1252, the usual export encoding of spreadsheet tools.
+func detectEncoding(sample []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8BOM
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case half > 0 && oddZeros > half/2 && evenZeros == 0:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case half > 0 && evenZeros > half/2 && oddZeros == 0:
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+
+	// The sample may end in the middle of a multi-byte sequence.
+	valid := sample
+	for i := 0; i < utf8.UTFMax && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+	if utf8.Valid(valid) {
+		return unicode.UTF8
+	}
+	return charmap.Windows1252
+}
+
+// sniffDelimiter returns the candidate delimiter that splits the most lines
+// into the same number of fields. truncated drops the last line of the
+// sample, which may have been cut short.
+func sniffDelimiter(sample []byte, truncated bool) rune {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(sample))
+	scanner.Buffer(make([]byte, 0, len(sample)+1), len(sample)+1)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if truncated && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > csvSniffLines {
+		lines = lines[:csvSniffLines]
+	}
+
+	best, bestScore := ',', 0
+	for _, delimiter := range csvDelimiters {
+		frequency := make(map[int]int)
+		for _, line := range lines {
+			if n := countDelimiters(line, delimiter); n > 0 {
+				frequency[n]++
+			}
+		}
+
+		// Prefer the field count shared by most lines, then the larger
+		// count, so that a preamble line or two does not mislead.
+		for count, lines := range frequency {
+			if score := lines*1000 + count; score > bestScore {
+				best, bestScore = delimiter, score
+			}
+		}
+	}
+	return best
+}
+
+// countDelimiters counts the delimiters of a line outside quoted fields.
+func countDelimiters(line string, delimiter rune) int {
+	count, quoted := 0, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delimiter && !quoted:
+			count++
+		}
+	}
+	return count
+}
+
+func (wb *csvWorkbook) sheetNames() []string {
+	return []string{csvSheetName}
+}
+
+func (wb *csvWorkbook) openSheet(name string, mode ValueMode) (rowIterator, error) {
+	if name != csvSheetName {
+		return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+	}
+
+	text := transform.NewReader(io.NewSectionReader(wb.source, 0, wb.size), wb.encoding.NewDecoder())
+
+	reader := csv.NewReader(text)
+	reader.Comma = wb.delimiter
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	return &csvRows{reader: reader}, nil
+}
+
+func (wb *csvWorkbook) mergedCells(name string) ([]mergeRange, error)   { return nil, nil }
+func (wb *csvWorkbook) sheetHidden(name string) bool                    { return false }
+func (wb *csvWorkbook) hiddenColumns(name string) (map[int]bool, error) { return nil, nil }
+func (wb *csvWorkbook) uses1904Dates() bool                             { return false }
+func (wb *csvWorkbook) Close() error                                    { return nil }
+
+// csvRows yields the records of a delimited file as rows. Blank lines are
+// skipped by encoding/csv, so rows are numbered by record rather than by
+// line.
+type csvRows struct {
+	reader *csv.Reader
+	row    sheetRow
+	err    error
+}
+
+func (r *csvRows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		r.err = err
+		return false
+	}
+
+	r.row = sheetRow{number: r.row.number + 1, cells: make([]cell, len(record))}
+	for i, field := range record {
+		r.row.cells[i] = csvCell(field)
+	}
+	return true
+}
+
+// csvNumberPattern matches the plain numbers spreadsheet tools write to
+// delimited files. Leading zeros, as in account numbers and postal codes,
+// and thousands or decimal commas keep a value text.
+var csvNumberPattern = regexp.MustCompile(`^[+-]?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?$`)
+
+// csvCell types a field the way the workbook readers type their cells:
+// plain numbers, TRUE and FALSE, and ISO-8601 dates and timestamps without
+// a zone. Anything else is text.
+func csvCell(field string) cell {
+	c := cell{text: field, raw: field, kind: excelize.CellTypeInlineString}
+	value := strings.TrimSpace(field)
+
+	switch {
+	case csvNumberPattern.MatchString(value):
+		c.kind, c.raw = excelize.CellTypeNumber, value
+	case strings.EqualFold(value, "TRUE"), strings.EqualFold(value, "FALSE"):
+		c.kind, c.raw = excelize.CellTypeBool, value
+	case len(value) == len("2006-01-02"):
+		if _, err := time.Parse("2006-01-02", value); err == nil {
+			c.kind, c.raw, c.numFmt.dateKind = excelize.CellTypeDate, value, dateOnly
+		}
+	case len(value) == len("2006-01-02T15:04:05") && (value[10] == 'T' || value[10] == ' '):
+		value = value[:10] + "T" + value[11:]
+		if _, err := time.Parse("2006-01-02T15:04:05", value); err == nil {
+			c.kind, c.raw, c.numFmt.dateKind = excelize.CellTypeDate, value, dateTime
+		}
+	}
+	return c
+}
+
+func (r *csvRows) Row() sheetRow { return r.row }
+func (r *csvRows) Err() error    { return r.err }
+func (r *csvRows) Close() error  { return nil }
@@ -0,0 +1,131 @@
+package xlsx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format identifies the file format of an upload.
+type Format int
+
+const (
+	// FormatXLSX is the zero value, so Parse keeps reading workbooks only.
+	FormatXLSX Format = iota
+	FormatCSV
+	FormatTSV
+	FormatODS
+	FormatXLS
+	// FormatAuto detects the format from the content of the upload.
+	FormatAuto
+)
+
+// ErrUnsupportedFormat is returned for uploads that no reader understands.
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+var zipSignature = []byte("PK\x03\x04")
+
+// FormatFromFilename maps a file extension onto a format. It reports false
+// for extensions that are not accepted.
+func FormatFromFilename(name string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xlsx":
+		return FormatXLSX, true
+	case ".csv":
+		return FormatCSV, true
+	case ".tsv", ".tab":
+		return FormatTSV, true
+	case ".ods":
+		return FormatODS, true
+	case ".xls":
+		return FormatXLS, true
+	default:
+		return FormatAuto, false
+	}
+}
+
+// workbook is a source of worksheets. Each file format provides one, so
+// that everything from header detection onwards is shared.
+type workbook interface {
+	sheetNames() []string
+	openSheet(name string, mode ValueMode) (rowIterator, error)
+	// mergedCells lists the merged ranges of a sheet.
+	mergedCells(name string) ([]mergeRange, error)
+	// sheetHidden reports whether a sheet is hidden from view, and
+	// hiddenColumns which of its 0-based columns are.
+	sheetHidden(name string) bool
+	hiddenColumns(name string) (map[int]bool, error)
+	// uses1904Dates reports whether serial dates count from 1904.
+	uses1904Dates() bool
+	Close() error
+}
+
+// openWorkbook opens an upload in the given format, detecting it first when
+// format is FormatAuto. Encrypted .xlsx packages are decrypted with password
+// before they are opened.
+func openWorkbook(source io.ReaderAt, size int64, format Format, password string) (workbook, error) {
+	if isEncryptedPackage(source) {
+		pkg, err := decryptPackage(source, size, password)
+		if err != nil {
+			return nil, err
+		}
+		source, size, format = pkg, pkg.Size(), FormatXLSX
+	}
+
+	if format == FormatAuto {
+		format = sniffFormat(source)
+	}
+
+	switch format {
+	case FormatXLSX:
+		wb, err := openXLSXWorkbook(source, size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xlsx file: %w", err)
+		}
+		return wb, nil
+	case FormatODS:
+		wb, err := openODSWorkbook(source, size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ods file: %w", err)
+		}
+		return wb, nil
+	case FormatXLS:
+		wb, err := openXLSWorkbook(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xls file: %w", err)
+		}
+		return wb, nil
+	case FormatCSV, FormatTSV:
+		wb, err := openCSVWorkbook(source, size, format == FormatTSV)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open csv file: %w", err)
+		}
+		return wb, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// sniffFormat tells zip packages and OLE compound files from delimited text
+// by their signature. OpenDocument packages store an uncompressed mimetype file as their first
+// entry, which makes them recognisable from the first bytes alone. Anything
+// else is left to the CSV reader, which rejects content that is not text.
+func sniffFormat(source io.ReaderAt) Format {
+	header := make([]byte, 38+len(odsMimeType))
+	n, _ := source.ReadAt(header, 0)
+	header = header[:n]
+
+	if bytes.HasPrefix(header, cfbSignature) {
+		return FormatXLS
+	}
+	if !bytes.HasPrefix(header, zipSignature) {
+		return FormatCSV
+	}
+	if bytes.Contains(header, []byte("mimetype"+odsMimeType)) {
+		return FormatODS
+	}
+	return FormatXLSX
+}
@@ -0,0 +1,258 @@
+package xlsx
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// FormulaMode selects what formula cells contribute to a record.
+type FormulaMode int
+
+const (
+	// FormulasCached uses the value cached in the file by the application
+	// that saved it. Workbooks saved without calculation have none.
+	FormulasCached FormulaMode = iota
+	// FormulasText returns the formula itself, e.g. "=SUM(B2:B9)".
+	FormulasText
+	// FormulasRecalc computes formulas with excelize and reports cells
+	// whose cached value disagrees with the result.
+	FormulasRecalc
+)
+
+// ErrInvalidFormulaMode is returned for unknown formula modes.
+var ErrInvalidFormulaMode = errors.New("formulas must be one of cached, formula, recalc")
+
+// ParseFormulaMode parses the value of the "formulas" upload parameter.
+func ParseFormulaMode(value string) (FormulaMode, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "cached":
+		return FormulasCached, nil
+	case "formula":
+		return FormulasText, nil
+	case "recalc":
+		return FormulasRecalc, nil
+	default:
+		return FormulasCached, ErrInvalidFormulaMode
+	}
+}
+
+// formulaWorkbook is implemented by workbooks that can read formulas rather
+// than their cached values.
+type formulaWorkbook interface {
+	formulaRows(name string, rows rowIterator, opts Options) (*formulaRows, error)
+}
+
+func (wb *xlsxWorkbook) formulaRows(name string, rows rowIterator, opts Options) (*formulaRows, error) {
+	sheet, ok := wb.sheet(name)
+	if !ok {
+		return nil, ErrSheetNotFound
+	}
+
+	part, err := wb.openPart(sheet.path)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := scanFormulaCells(part)
+	part.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := wb.excelize()
+	if err != nil {
+		return nil, err
+	}
+	return &formulaRows{src: rows, file: f, sheet: name, opts: opts, cells: cells}, nil
+}
+
+// scanFormulaCells locates the cells of a worksheet part that hold a
+// formula, as 0-based columns by row number.
+func scanFormulaCells(part io.Reader) (map[int][]int, error) {
+	cells := make(map[int][]int)
+	row, col := 0, 0
+	inCell := false
+	decoder := xml.NewDecoder(part)
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return cells, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "row":
+				row++
+				col = 0
+				if n, err := strconv.Atoi(xmlAttr(el, "r")); err == nil {
+					row = n
+				}
+			case "c":
+				col++
+				if c, r, err := excelize.CellNameToCoordinates(xmlAttr(el, "r")); err == nil {
+					col, row = c, r
+				}
+				inCell = true
+			case "f":
+				// Formulas also appear in extensions after the cell data;
+				// only those inside a cell count.
+				if inCell {
+					cells[row] = append(cells[row], col-1)
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "c" {
+				inCell = false
+			}
+		}
+	}
+}
+
+// maxReportedMismatches bounds the formula mismatches listed for a sheet;
+// every mismatch is still counted.
+const maxReportedMismatches = 100
+
+// formulaRows replaces the cached value of formula cells with the formula
+// text or the recalculated value. excelize loads the whole worksheet to do
+// so, which is why this only happens when asked for.
+type formulaRows struct {
+	src   rowIterator
+	file  *excelize.File
+	sheet string
+	opts  Options
+	cells map[int][]int
+	row   sheetRow
+
+	mismatches    []models.FormulaMismatch
+	mismatchCount int
+}
+
+func (r *formulaRows) Next() bool {
+	if !r.src.Next() {
+		return false
+	}
+
+	row := r.src.Row()
+	cols := r.cells[row.number]
+	if len(cols) == 0 {
+		r.row = row
+		return true
+	}
+
+	cells := append([]cell(nil), row.cells...)
+	for _, col := range cols {
+		for len(cells) <= col {
+			cells = append(cells, cell{})
+		}
+		cells[col] = r.evaluate(row.number, col, cells[col])
+	}
+	r.row = sheetRow{number: row.number, hidden: row.hidden, cells: cells}
+	return true
+}
+
+func (r *formulaRows) evaluate(row, col int, cached cell) cell {
+	name, err := excelize.CoordinatesToCellName(col+1, row)
+	if err != nil {
+		return cached
+	}
+
+	if r.opts.Formulas == FormulasText {
+		formula, err := r.file.GetCellFormula(r.sheet, name)
+		if err != nil || formula == "" {
+			return cached
+		}
+		text := "=" + formula
+		return cell{text: text, raw: text, kind: excelize.CellTypeInlineString, meta: cached.meta}
+	}
+
+	// Typed values compare and convert raw results; display strings use
+	// the cell's number format on both sides.
+	var calcOpts []excelize.Options
+	cachedValue := cached.text
+	if r.opts.Values == ValuesTyped {
+		calcOpts = append(calcOpts, excelize.Options{RawCellValue: true})
+		cachedValue = cached.raw
+		if cached.kind == excelize.CellTypeBool {
+			cachedValue = boolText(cachedValue)
+		}
+	}
+
+	value, err := r.file.CalcCellValue(r.sheet, name, calcOpts...)
+	if err != nil && value == "" && strings.HasPrefix(err.Error(), "#") {
+		// Formula errors such as #DIV/0! come back as the error.
+		value = err.Error()
+	}
+	isError := strings.HasPrefix(value, "#")
+	if err != nil && !isError {
+		r.report(models.FormulaMismatch{Cell: name, Cached: cachedValue, Error: err.Error()})
+		return cached
+	}
+
+	if strings.TrimSpace(cachedValue) != "" && !sameValue(cachedValue, value) {
+		r.report(models.FormulaMismatch{Cell: name, Cached: cachedValue, Computed: value})
+	}
+
+	computed := cell{text: value, raw: value, kind: excelize.CellTypeInlineString, numFmt: cached.numFmt, meta: cached.meta}
+	switch {
+	case isError:
+		computed.kind = excelize.CellTypeError
+	case strings.EqualFold(value, "TRUE") || strings.EqualFold(value, "FALSE"):
+		computed.kind = excelize.CellTypeBool
+	default:
+		if _, ok := parseNumber(value); ok {
+			computed.kind = excelize.CellTypeNumber
+		}
+	}
+	return computed
+}
+
+// report counts a mismatch and lists it while fewer than
+// maxReportedMismatches are.
+func (r *formulaRows) report(mismatch models.FormulaMismatch) {
+	r.mismatchCount++
+	if len(r.mismatches) >= maxReportedMismatches {
+		return
+	}
+	formula, _ := r.file.GetCellFormula(r.sheet, mismatch.Cell)
+	mismatch.Formula = "=" + formula
+	r.mismatches = append(r.mismatches, mismatch)
+}
+
+func (r *formulaRows) Row() sheetRow { return r.row }
+func (r *formulaRows) Err() error    { return r.src.Err() }
+func (r *formulaRows) Close() error  { return r.src.Close() }
+
+func boolText(raw string) string {
+	switch strings.TrimSpace(raw) {
+	case "1":
+		return "TRUE"
+	case "0":
+		return "FALSE"
+	}
+	return raw
+}
+
+// sameValue compares a cached and a computed value, allowing numbers to
+// differ in the last digits.
+func sameValue(cached, computed string) bool {
+	cached, computed = strings.TrimSpace(cached), strings.TrimSpace(computed)
+	if strings.EqualFold(cached, computed) {
+		return true
+	}
+	a, errA := strconv.ParseFloat(cached, 64)
+	b, errB := strconv.ParseFloat(computed, 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
@@ -0,0 +1,229 @@
+package xlsx
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// headerScanRows bounds how far into a sheet automatic detection looks
+	// for the header row.
+	headerScanRows = 30
+
+	// headerLookahead is the number of rows following a candidate that are
+	// inspected to decide whether it heads a table.
+	headerLookahead = 3
+
+	// maxRequestedHeaderRows bounds the header height that can be requested.
+	maxRequestedHeaderRows = 10
+)
+
+var dateLikePattern = regexp.MustCompile(`^\d{1,4}[-/.]\d{1,2}[-/.]\d{1,4}([ T]\d{1,2}:\d{2}(:\d{2})?)?$`)
+
+// ParseHeaderRow parses the value of the "headerRow" upload parameter. An
+// empty string or "auto" selects automatic detection and yields 0; anything
+// else must be a 1-based spreadsheet row number no greater than the rows a
+// worksheet can hold.
+func ParseHeaderRow(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "auto") {
+		return 0, nil
+	}
+
+	row, err := strconv.Atoi(value)
+	if err != nil || row < 1 || row > excelize.TotalRows {
+		return 0, ErrInvalidHeaderRow
+	}
+	return row, nil
+}
+
+// ParseHeaderRows parses the value of the "headerRows" upload parameter. An
+// empty string or "auto" yields 0, leaving the height to merged cells.
+func ParseHeaderRows(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "auto") {
+		return 0, nil
+	}
+
+	rows, err := strconv.Atoi(value)
+	if err != nil || rows < 1 || rows > maxRequestedHeaderRows {
+		return 0, ErrInvalidHeaderRows
+	}
+	return rows, nil
+}
+
+// detectHeaderRow returns the index of the row that most likely holds the
+// column headers. Candidates are scored on how many distinct text cells they
+// contain and on whether the rows below them look like data; ties go to the
+// earliest row.
+func detectHeaderRow(rows [][]string) int {
+	limit := len(rows) - 1
+	if limit > headerScanRows {
+		limit = headerScanRows
+	}
+
+	width := 0
+	for i := 0; i <= limit && i < len(rows); i++ {
+		if n := countNonEmpty(rows[i]); n > width {
+			width = n
+		}
+	}
+
+	best, bestScore := 0, 0.0
+	for i := 0; i < limit; i++ {
+		if score := scoreHeaderCandidate(rows, i, width); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+func scoreHeaderCandidate(rows [][]string, index, width int) float64 {
+	row := rows[index]
+
+	nonEmpty, text := 0, 0
+	distinct := make(map[string]bool, len(row))
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		nonEmpty++
+		distinct[strings.ToLower(cell)] = true
+		if !looksTyped(cell) {
+			text++
+		}
+	}
+	if text == 0 {
+		return 0
+	}
+
+	uniqueness := float64(len(distinct)) / float64(nonEmpty)
+	score := float64(text)*uniqueness - float64(nonEmpty-text)
+	if score <= 0 {
+		return 0
+	}
+
+	// A lone cell in an otherwise wide sheet is a title, not a header.
+	if nonEmpty == 1 && width > 1 {
+		score *= 0.5
+	}
+
+	supported, typed := 0, false
+	for j := index + 1; j < len(rows) && j <= index+headerLookahead; j++ {
+		filled := 0
+		for col, cell := range rows[j] {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+			if col < len(row) && strings.TrimSpace(row[col]) != "" {
+				filled++
+			}
+			if looksTyped(cell) {
+				typed = true
+			}
+		}
+		if filled > 0 && filled*2 >= nonEmpty {
+			supported++
+		}
+	}
+
+	score *= 0.5 + 0.5*float64(supported)/headerLookahead
+	if typed {
+		score *= 1.25
+	}
+	return score
+}
+
+// looksTyped reports whether a cell holds a number or a date rather than
+// free text.
+func looksTyped(cell string) bool {
+	cleaned := strings.NewReplacer(",", "", " ", "").Replace(cell)
+	cleaned = strings.TrimSuffix(strings.TrimPrefix(cleaned, "("), ")")
+	if _, ok := parseNumber(cleaned); ok {
+		return true
+	}
+	return dateLikePattern.MatchString(cell)
+}
+
+func countNonEmpty(row []string) int {
+	n := 0
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// sheetHead is the top of a sheet as buffered to find the header. Row
+// indexes are 0-based sheet indexes: rows holds the rows from base on, and
+// anchors the top-left cells of merged ranges that start above base and
+// reach down into rows.
+type sheetHead struct {
+	base    int
+	rows    []sheetRow
+	anchors map[int]sheetRow
+}
+
+// readHead buffers the first scan rows of a sheet. The first skip of them
+// are not kept but handed to pass as they stream by, except for the merge
+// anchors the header may borrow its text from.
+func readHead(rows rowIterator, scan, skip int, merges []mergeRange, pass func(sheetRow)) *sheetHead {
+	head := &sheetHead{base: skip}
+
+	var reaching map[int][]mergeRange
+	for _, m := range merges {
+		if m.firstRow <= skip && m.lastRow > skip {
+			if reaching == nil {
+				reaching = make(map[int][]mergeRange)
+			}
+			reaching[m.firstRow] = append(reaching[m.firstRow], m)
+		}
+	}
+
+	read := 0
+	for ; read < scan && rows.Next(); read++ {
+		row := rows.Row()
+		if read >= skip {
+			head.rows = append(head.rows, row)
+			continue
+		}
+
+		pass(row)
+		var cells []cell
+		for _, m := range reaching[row.number] {
+			if m.firstCol >= len(row.cells) {
+				continue
+			}
+			for len(cells) <= m.firstCol {
+				cells = append(cells, cell{})
+			}
+			cells[m.firstCol] = row.cells[m.firstCol]
+		}
+		if cells != nil {
+			if head.anchors == nil {
+				head.anchors = make(map[int]sheetRow)
+			}
+			head.anchors[row.number-1] = sheetRow{number: row.number, cells: cells}
+		}
+	}
+	head.base = min(skip, read)
+	return head
+}
+
+// len returns the number of sheet rows read, kept or not.
+func (h *sheetHead) len() int { return h.base + len(h.rows) }
+
+// row returns the row at a sheet index, or the merge anchors kept of it if
+// it was skipped.
+func (h *sheetHead) row(index int) sheetRow {
+	if index >= h.base && index < h.len() {
+		return h.rows[index-h.base]
+	}
+	return h.anchors[index]
+}
@@ -0,0 +1,45 @@
+package xlsx
+
+import (
+	"io"
+	"os"
+)
+
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// spool returns random access to an upload. Readers that already provide it,
+// such as multipart files, are used in place; anything else is copied to a
+// temporary file rather than into memory. The returned cleanup function must
+// always be called.
+func spool(reader io.Reader) (io.ReaderAt, int64, func(), error) {
+	switch r := reader.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil {
+			return nil, 0, func() {}, err
+		}
+		return r, info.Size(), func() {}, nil
+	case sizedReaderAt:
+		return r, r.Size(), func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "xlsx-upload-*")
+	if err != nil {
+		return nil, 0, func() {}, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, reader)
+	if err != nil {
+		cleanup()
+		return nil, 0, func() {}, err
+	}
+
+	return tmp, size, cleanup, nil
+}
@@ -0,0 +1,260 @@
+package xlsx
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// MergePolicy decides what data rows see in the cells covered by a merged
+// range.
+type MergePolicy int
+
+const (
+	// MergedKeep leaves covered cells blank, as spreadsheets store them.
+	MergedKeep MergePolicy = iota
+	// MergedFill copies the value of the range's top-left cell into every
+	// cell of the range.
+	MergedFill
+)
+
+// ErrInvalidMergePolicy is returned for unknown merged cell policies.
+var ErrInvalidMergePolicy = errors.New("mergedCells must be one of keep, fill")
+
+// ParseMergePolicy parses the value of the "mergedCells" upload parameter.
+func ParseMergePolicy(value string) (MergePolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "keep":
+		return MergedKeep, nil
+	case "fill":
+		return MergedFill, nil
+	default:
+		return MergedKeep, ErrInvalidMergePolicy
+	}
+}
+
+const (
+	// maxHeaderRows bounds how many rows a header made of merged cells may
+	// span.
+	maxHeaderRows = 4
+
+	// headerSeparator joins the levels of a hierarchical header.
+	headerSeparator = "."
+)
+
+// mergeRange is a merged block of cells. Rows are 1-based sheet row numbers
+// and columns 0-based indexes, matching sheetRow.
+type mergeRange struct {
+	firstRow, lastRow int
+	firstCol, lastCol int
+}
+
+func (m mergeRange) contains(row, col int) bool {
+	return row >= m.firstRow && row <= m.lastRow && col >= m.firstCol && col <= m.lastCol
+}
+
+func (m mergeRange) wide() bool { return m.lastCol > m.firstCol }
+
+// parseMergeRef parses an A1-style range such as "B1:C1".
+func parseMergeRef(ref string) (mergeRange, bool) {
+	first, last, ok := strings.Cut(ref, ":")
+	if !ok {
+		return mergeRange{}, false
+	}
+	firstCol, firstRow, err := excelize.CellNameToCoordinates(first)
+	if err != nil {
+		return mergeRange{}, false
+	}
+	lastCol, lastRow, err := excelize.CellNameToCoordinates(last)
+	if err != nil {
+		return mergeRange{}, false
+	}
+	m := mergeRange{firstRow: firstRow, lastRow: lastRow, firstCol: firstCol - 1, lastCol: lastCol - 1}
+	if m.lastRow < m.firstRow || m.lastCol < m.firstCol || (m.lastRow == m.firstRow && m.lastCol == m.firstCol) {
+		return mergeRange{}, false
+	}
+	return m, true
+}
+
+// headerBlock returns the sheet indexes of the first and last header row,
+// given the header row that was detected or requested. With rows set the
+// header is that many rows high; otherwise merged cells decide: ranges
+// reaching across the header rows are included whole, and a row grouping
+// the columns below it, such as "Amount" merged over "Debit" and "Credit",
+// pulls in the row of sub-headers.
+func headerBlock(head *sheetHead, index, rows int, merges []mergeRange) (int, int) {
+	if rows > 0 {
+		return index, index + rows - 1
+	}
+
+	top, bottom := index, index
+	for changed := true; changed; {
+		changed = false
+
+		for _, m := range merges {
+			if m.firstRow > bottom+1 || m.lastRow < top+1 {
+				continue
+			}
+			first, last := min(top, m.firstRow-1), max(bottom, m.lastRow-1)
+			if (first != top || last != bottom) && last-first+1 <= maxHeaderRows && first >= head.base && last < head.len() {
+				top, bottom, changed = first, last, true
+			}
+		}
+
+		if bottom+1 < head.len() && bottom-top+1 < maxHeaderRows && groupsRow(head, bottom, merges) {
+			bottom, changed = bottom+1, true
+		}
+		if top > head.base && bottom-top+1 < maxHeaderRows && groupsRow(head, top-1, merges) {
+			top, changed = top-1, true
+		}
+	}
+	return top, bottom
+}
+
+// groupsRow reports whether the row at index holds a merged range spanning
+// several columns whose cells in the next row are all text, i.e. a parent
+// header above its sub-headers. A row with a single cell is taken for a
+// title rather than part of the header.
+func groupsRow(head *sheetHead, index int, merges []mergeRange) bool {
+	if countFilled(head.row(index).cells) < 2 {
+		return false
+	}
+
+	below := head.row(index + 1).cells
+	for _, m := range merges {
+		if m.firstRow != index+1 || !m.wide() {
+			continue
+		}
+		text := true
+		for col := m.firstCol; col <= m.lastCol; col++ {
+			if col >= len(below) || below[col].isEmpty() || looksTyped(strings.TrimSpace(below[col].text)) {
+				text = false
+				break
+			}
+		}
+		if text {
+			return true
+		}
+	}
+	return false
+}
+
+func countFilled(cells []cell) int {
+	n := 0
+	for _, c := range cells {
+		if !c.isEmpty() {
+			n++
+		}
+	}
+	return n
+}
+
+// composeHeaders flattens the header rows top to bottom into one name
+// per column. Merged cells lend their text to every column they cover, and
+// the levels of each column are joined with a dot, skipping blanks and
+// repeats, so that "Amount" above "Debit" becomes "Amount.Debit".
+func composeHeaders(head *sheetHead, top, bottom int, merges []mergeRange) []string {
+	width := 0
+	for r := top; r <= bottom; r++ {
+		if n := len(head.row(r).cells); n > width {
+			width = n
+		}
+	}
+	for _, m := range merges {
+		if m.firstRow <= bottom+1 && m.lastRow >= top+1 && m.lastCol+1 > width && m.lastCol < excelize.MaxColumns {
+			width = m.lastCol + 1
+		}
+	}
+
+	text := func(r, col int) string {
+		for _, m := range merges {
+			if m.contains(r+1, col) {
+				r, col = m.firstRow-1, m.firstCol
+				break
+			}
+		}
+		cells := head.row(r).cells
+		if col >= len(cells) {
+			return ""
+		}
+		return strings.TrimSpace(cells[col].text)
+	}
+
+	headers := make([]string, width)
+	for col := range headers {
+		var parts []string
+		for r := top; r <= bottom; r++ {
+			part := text(r, col)
+			if part == "" || (len(parts) > 0 && parts[len(parts)-1] == part) {
+				continue
+			}
+			parts = append(parts, part)
+		}
+		headers[col] = strings.Join(parts, headerSeparator)
+	}
+	return headers
+}
+
+// mergeFiller copies the top-left value of merged ranges into the rest of
+// the range as rows stream past. It must see every row in order, including
+// those above the data, so that it picks up values of ranges reaching down
+// into the data.
+type mergeFiller struct {
+	merges []mergeRange
+	next   int
+	active []filledMerge
+}
+
+type filledMerge struct {
+	mergeRange
+	value cell
+}
+
+func newMergeFiller(merges []mergeRange) *mergeFiller {
+	sorted := append([]mergeRange(nil), merges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].firstRow < sorted[j].firstRow })
+	return &mergeFiller{merges: sorted}
+}
+
+func (f *mergeFiller) fill(row sheetRow) sheetRow {
+	active := f.active[:0]
+	for _, m := range f.active {
+		if m.lastRow >= row.number {
+			active = append(active, m)
+		}
+	}
+	f.active = active
+
+	for f.next < len(f.merges) && f.merges[f.next].firstRow <= row.number {
+		m := f.merges[f.next]
+		f.next++
+		if m.firstRow < row.number || m.firstCol >= len(row.cells) || m.lastCol >= excelize.MaxColumns {
+			continue
+		}
+		f.active = append(f.active, filledMerge{mergeRange: m, value: row.cells[m.firstCol]})
+	}
+
+	var cells []cell
+	for _, m := range f.active {
+		if m.value.isEmpty() {
+			continue
+		}
+		if cells == nil {
+			cells = append([]cell(nil), row.cells...)
+		}
+		for len(cells) <= m.lastCol {
+			cells = append(cells, cell{})
+		}
+		for col := m.firstCol; col <= m.lastCol; col++ {
+			if row.number != m.firstRow || col != m.firstCol {
+				cells[col] = m.value
+			}
+		}
+	}
+	if cells == nil {
+		return row
+	}
+	return sheetRow{number: row.number, hidden: row.hidden, cells: cells}
+}
@@ -0,0 +1,264 @@
+package xlsx
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrInvalidMetaFlag is returned for "meta" upload parameters that are not
+// booleans.
+var ErrInvalidMetaFlag = errors.New("meta must be true or false")
+
+// ParseMetaFlag parses the value of the "meta" upload parameter.
+func ParseMetaFlag(value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false, nil
+	}
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, ErrInvalidMetaFlag
+	}
+	return on, nil
+}
+
+// cellMetadata returns what a record reports about a cell besides its
+// value, and whether there is anything to report.
+func cellMetadata(c cell) (models.CellMeta, bool) {
+	var meta models.CellMeta
+	if c.meta != nil {
+		meta = *c.meta
+	}
+	if code := c.numFmt.code; code != "" && !strings.EqualFold(code, "General") && !c.isEmpty() {
+		meta.NumberFormat = code
+	}
+	ok := meta.Hyperlink != "" || meta.Comment != nil || meta.NumberFormat != "" || len(meta.RichText) > 0
+	return meta, ok
+}
+
+// metaWorkbook is implemented by workbooks that keep cell metadata apart
+// from the cell values, and attach it to the rows as they are read.
+type metaWorkbook interface {
+	cellMeta(name string, rows rowIterator, mode ValueMode) (rowIterator, error)
+}
+
+const (
+	relTypeHyperlink = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	relTypeComments  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+)
+
+type xmlComments struct {
+	Authors  []string `xml:"authors>author"`
+	Comments []struct {
+		Ref      string        `xml:"ref,attr"`
+		AuthorID int           `xml:"authorId,attr"`
+		Text     xmlStringItem `xml:"text"`
+	} `xml:"commentList>comment"`
+}
+
+// cellMeta attaches the hyperlinks and comments of an .xlsx worksheet,
+// which live outside the cell data, to its rows. Display strings come from
+// excelize, so with ValuesString the worksheet is also streamed alongside
+// the rows for the number formats and rich text of its cells.
+func (wb *xlsxWorkbook) cellMeta(name string, rows rowIterator, mode ValueMode) (rowIterator, error) {
+	sheet, ok := wb.sheet(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+	}
+
+	notes, err := wb.sheetNotes(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &metaRows{src: rows, notes: notes}
+	if mode == ValuesString {
+		part, err := wb.openPart(sheet.path)
+		if err != nil {
+			return nil, err
+		}
+		meta.styles = newSheetStream(part, wb)
+	}
+	return meta, nil
+}
+
+// sheetNotes collects the hyperlinks and comments of a worksheet by row.
+func (wb *xlsxWorkbook) sheetNotes(sheet xlsxSheet) (map[int][]cellNote, error) {
+	dir := path.Dir(sheet.path)
+	// A worksheet without relationships has neither external links nor
+	// comments.
+	var rels xmlRelationships
+	_ = wb.decodePart(path.Join(dir, "_rels", path.Base(sheet.path)+".rels"), &rels)
+
+	links := make(map[string]string)
+	var commentsPath string
+	for _, rel := range rels.Relationships {
+		switch rel.Type {
+		case relTypeHyperlink:
+			links[rel.ID] = rel.Target
+		case relTypeComments:
+			commentsPath = resolvePartPath(dir, rel.Target)
+		}
+	}
+
+	notes := make(map[int][]cellNote)
+	add := func(ref string, apply func(*models.CellMeta)) {
+		first, last, ok := strings.Cut(ref, ":")
+		if !ok {
+			last = first
+		}
+		col, row, err := excelize.CellNameToCoordinates(first)
+		if err != nil {
+			return
+		}
+		lastCol, lastRow, err := excelize.CellNameToCoordinates(last)
+		if err != nil {
+			lastCol, lastRow = col, row
+		}
+		addNote(notes, row, col-1, lastRow, lastCol-1, apply)
+	}
+
+	layout, err := wb.layout(sheet.name)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range layout.hyperlinks {
+		target := links[link.id]
+		if link.location != "" {
+			target += "#" + link.location
+		}
+		if target != "" {
+			add(link.ref, func(meta *models.CellMeta) { meta.Hyperlink = target })
+		}
+	}
+
+	if commentsPath != "" {
+		var comments xmlComments
+		if err := wb.decodePart(commentsPath, &comments); err != nil {
+			return nil, fmt.Errorf("failed to read comments: %w", err)
+		}
+		for _, c := range comments.Comments {
+			var author string
+			if c.AuthorID >= 0 && c.AuthorID < len(comments.Authors) {
+				author = comments.Authors[c.AuthorID]
+			}
+			comment := newCellComment(author, c.Text.String())
+			add(c.Ref, func(meta *models.CellMeta) { meta.Comment = comment })
+		}
+	}
+
+	return notes, nil
+}
+
+// newCellComment builds the comment of a cell. Excel starts the text with
+// the author's name in bold, which is dropped.
+func newCellComment(author, text string) *models.CellComment {
+	if author != "" {
+		if rest, ok := strings.CutPrefix(text, author+":"); ok {
+			text = strings.TrimLeft(rest, " \r\n")
+		}
+	}
+	return &models.CellComment{Author: author, Text: text}
+}
+
+// maxNoteCells bounds the cells a single hyperlink range is copied to.
+const maxNoteCells = 1024
+
+// addNote notes every cell of a range, given by 1-based rows and 0-based
+// columns. Ranges that are reversed or larger than maxNoteCells only note
+// their first cell.
+func addNote(notes map[int][]cellNote, row, col, lastRow, lastCol int, apply func(*models.CellMeta)) {
+	if lastCol < col || lastRow < row || (lastRow-row+1)*(lastCol-col+1) > maxNoteCells {
+		lastCol, lastRow = col, row
+	}
+	for r := row; r <= lastRow; r++ {
+		for c := col; c <= lastCol; c++ {
+			notes[r] = append(notes[r], cellNote{col: c, apply: apply})
+		}
+	}
+}
+
+// cellNote sets part of the metadata of the cell in column col.
+type cellNote struct {
+	col   int
+	apply func(*models.CellMeta)
+}
+
+// metaRows adds the notes of a worksheet to its rows, and the number formats
+// and rich text of a second, typed stream over the same worksheet when the
+// rows themselves only carry display strings.
+type metaRows struct {
+	src    rowIterator
+	notes  map[int][]cellNote
+	styles rowIterator
+	styled sheetRow
+	row    sheetRow
+}
+
+func (r *metaRows) Next() bool {
+	if !r.src.Next() {
+		return false
+	}
+
+	row := r.src.Row()
+	var styled []cell
+	if r.styles != nil {
+		for r.styled.number < row.number && r.styles.Next() {
+			r.styled = r.styles.Row()
+		}
+		if r.styled.number == row.number {
+			styled = r.styled.cells
+		}
+	}
+	notes := r.notes[row.number]
+	if len(styled) == 0 && len(notes) == 0 {
+		r.row = row
+		return true
+	}
+
+	cells := append([]cell(nil), row.cells...)
+	for col, c := range styled {
+		if col >= len(cells) {
+			break
+		}
+		cells[col].numFmt, cells[col].meta = c.numFmt, c.meta
+	}
+	for _, note := range notes {
+		for len(cells) <= note.col {
+			cells = append(cells, cell{})
+		}
+		var meta models.CellMeta
+		if cells[note.col].meta != nil {
+			meta = *cells[note.col].meta
+		}
+		note.apply(&meta)
+		cells[note.col].meta = &meta
+	}
+	r.row = sheetRow{number: row.number, hidden: row.hidden, cells: cells}
+	return true
+}
+
+func (r *metaRows) Row() sheetRow { return r.row }
+
+func (r *metaRows) Err() error {
+	if err := r.src.Err(); err != nil {
+		return err
+	}
+	if r.styles != nil {
+		return r.styles.Err()
+	}
+	return nil
+}
+
+func (r *metaRows) Close() error {
+	if r.styles != nil {
+		r.styles.Close()
+	}
+	return r.src.Close()
+}
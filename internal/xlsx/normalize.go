@@ -0,0 +1,102 @@
+package xlsx
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/ledger"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+)
+
+// Preamble keys of the balances a statement is reconciled against.
+const (
+	preambleOpeningBalance = "openingBalance"
+	preambleClosingBalance = "closingBalance"
+)
+
+// NormalizeMode selects whether records are also mapped onto a canonical
+// model.
+type NormalizeMode int
+
+const (
+	// NormalizeNone keeps records as keyed maps only.
+	NormalizeNone NormalizeMode = iota
+	// NormalizeTransactions maps every record onto a bank transaction and
+	// rejects the rows that cannot be.
+	NormalizeTransactions
+)
+
+// ErrInvalidNormalizeMode is returned for unknown normalization modes.
+var ErrInvalidNormalizeMode = errors.New("normalize must be one of none, transactions")
+
+// ParseNormalizeMode parses the value of the "normalize" upload parameter.
+func ParseNormalizeMode(value string) (NormalizeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "none":
+		return NormalizeNone, nil
+	case "transactions":
+		return NormalizeTransactions, nil
+	default:
+		return NormalizeNone, ErrInvalidNormalizeMode
+	}
+}
+
+// ReconcilePolicy decides what a statement whose running balance breaks
+// does to the upload.
+type ReconcilePolicy int
+
+const (
+	// ReconcileReport reports breaks in the sheet summaries.
+	ReconcileReport ReconcilePolicy = iota
+	// ReconcileReject refuses uploads with a sheet that does not reconcile.
+	ReconcileReject
+)
+
+// ErrInvalidReconcilePolicy is returned for unknown reconciliation policies.
+var ErrInvalidReconcilePolicy = errors.New("reconcile must be one of report, reject")
+
+// ParseReconcilePolicy parses the value of the "reconcile" upload parameter.
+func ParseReconcilePolicy(value string) (ReconcilePolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "report":
+		return ReconcileReport, nil
+	case "reject":
+		return ReconcileReject, nil
+	default:
+		return ReconcileReport, ErrInvalidReconcilePolicy
+	}
+}
+
+// reconcileSheet finishes the reconciliation of the transactions a sheet
+// added, against the closing balance of its preamble when it is stated, and
+// returns it with the positions of the transactions where the balance
+// breaks.
+func reconcileSheet(r *ledger.Reconciler, metadata map[string]string, opts Options) (*models.Reconciliation, []int, error) {
+	rec, breaks := r.Finish(statementBalance(metadata, preambleClosingBalance, opts.Locale))
+	if opts.Reconcile == ReconcileReject {
+		if err := ledger.CheckReconciled(rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &rec, breaks, nil
+}
+
+// statementBalance returns the balance stated in the preamble under key, if
+// any.
+func statementBalance(metadata map[string]string, key string, locale ledger.Locale) *float64 {
+	amount, ok, err := ledger.ParseAmount(metadata[key], locale)
+	if err != nil || !ok {
+		return nil
+	}
+	return &amount
+}
+
+// worseReconciliation orders reconciliation statuses for the upload as a
+// whole: broken over reconciled over unchecked.
+func worseReconciliation(a, b string) string {
+	rank := map[string]int{"": 0, ledger.StatusUnchecked: 1, ledger.StatusReconciled: 2, ledger.StatusBroken: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
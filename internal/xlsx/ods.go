@@ -0,0 +1,537 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	odsContentPart  = "content.xml"
+	odsManifestPart = "META-INF/manifest.xml"
+	odsMimeType     = "application/vnd.oasis.opendocument.spreadsheet"
+
+	// odsMaxColumns caps repeated cells, matching the column limit of
+	// spreadsheet applications.
+	odsMaxColumns = excelize.MaxColumns
+)
+
+// odsWorkbook reads an OpenDocument spreadsheet. All sheets live in a single
+// content part, which is decoded as a stream once per sheet.
+type odsWorkbook struct {
+	zr     *zip.Reader
+	sheets []string
+	hidden map[string]bool
+}
+
+func openODSWorkbook(source io.ReaderAt, size int64) (*odsWorkbook, error) {
+	zr, err := zip.NewReader(source, size)
+	if err != nil {
+		return nil, err
+	}
+
+	wb := &odsWorkbook{zr: zr}
+	if wb.encrypted() {
+		return nil, fmt.Errorf("%w: decrypting .ods files is not supported", ErrEncrypted)
+	}
+
+	part, err := wb.openContent()
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	// Tables are hidden through their automatic style, which precedes the
+	// body of the document.
+	hiddenStyles := make(map[string]bool)
+	decoder := xml.NewDecoder(part)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return wb, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read content part: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "style":
+			if xmlAttr(start, "family") != "table" {
+				continue
+			}
+			var style struct {
+				Properties struct {
+					Display string `xml:"display,attr"`
+				} `xml:"table-properties"`
+			}
+			if err := decoder.DecodeElement(&style, &start); err != nil {
+				return nil, fmt.Errorf("failed to read content part: %w", err)
+			}
+			if style.Properties.Display == "false" {
+				hiddenStyles[xmlAttr(start, "name")] = true
+			}
+		case "table":
+			name := xmlAttr(start, "name")
+			wb.sheets = append(wb.sheets, name)
+			if hiddenStyles[xmlAttr(start, "style-name")] {
+				if wb.hidden == nil {
+					wb.hidden = make(map[string]bool)
+				}
+				wb.hidden[name] = true
+			}
+			if err := decoder.Skip(); err != nil {
+				return nil, fmt.Errorf("failed to read content part: %w", err)
+			}
+		}
+	}
+}
+
+func (wb *odsWorkbook) openContent() (io.ReadCloser, error) {
+	for _, file := range wb.zr.File {
+		if file.Name == odsContentPart {
+			return file.Open()
+		}
+	}
+	return nil, fmt.Errorf("part %s not found", odsContentPart)
+}
+
+// encrypted reports whether the manifest lists encryption parameters for
+// the content part, which is then not readable as XML.
+func (wb *odsWorkbook) encrypted() bool {
+	for _, file := range wb.zr.File {
+		if file.Name != odsManifestPart {
+			continue
+		}
+		part, err := file.Open()
+		if err != nil {
+			return false
+		}
+		defer part.Close()
+
+		decoder := xml.NewDecoder(part)
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return false
+			}
+			if start, ok := token.(xml.StartElement); ok && start.Name.Local == "encryption-data" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (wb *odsWorkbook) sheetNames() []string {
+	return wb.sheets
+}
+
+func (wb *odsWorkbook) openSheet(name string, mode ValueMode) (rowIterator, error) {
+	part, decoder, err := wb.findTable(name)
+	if err != nil {
+		return nil, err
+	}
+	return &odsRows{part: part, decoder: decoder}, nil
+}
+
+// findTable positions a decoder of the content part just inside the named
+// table.
+func (wb *odsWorkbook) findTable(name string) (io.ReadCloser, *xml.Decoder, error) {
+	found := false
+	for _, sheet := range wb.sheets {
+		if sheet == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+	}
+
+	part, err := wb.openContent()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder := xml.NewDecoder(part)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			part.Close()
+			return nil, nil, fmt.Errorf("failed to find sheet %s: %w", name, err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "table" {
+			continue
+		}
+		if xmlAttr(start, "name") == name {
+			return part, decoder, nil
+		}
+		if err := decoder.Skip(); err != nil {
+			part.Close()
+			return nil, nil, err
+		}
+	}
+}
+
+func (wb *odsWorkbook) sheetHidden(name string) bool { return wb.hidden[name] }
+
+// hiddenColumns reads the column declarations at the top of a table, which
+// may be grouped, up to its first row.
+func (wb *odsWorkbook) hiddenColumns(name string) (map[int]bool, error) {
+	part, decoder, err := wb.findTable(name)
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	hidden := make(map[int]bool)
+	col := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "table-column":
+				repeated := xmlRepeat(el, "number-columns-repeated")
+				if odsHidden(el) {
+					for i := 0; i < repeated && col+i < odsMaxColumns; i++ {
+						hidden[col+i] = true
+					}
+				}
+				col += repeated
+			case "table-row", "table-rows", "table-header-rows", "table-row-group":
+				return hidden, nil
+			}
+		case xml.EndElement:
+			if el.Name.Local == "table" {
+				return hidden, nil
+			}
+		}
+	}
+}
+
+// mergedCells reads the sheet once to collect its spanned cells, which
+// OpenDocument declares on the cells themselves.
+func (wb *odsWorkbook) mergedCells(name string) ([]mergeRange, error) {
+	it, err := wb.openSheet(name, ValuesTyped)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	rows := it.(*odsRows)
+	for rows.Next() {
+	}
+	return rows.merges, rows.Err()
+}
+
+func (wb *odsWorkbook) uses1904Dates() bool { return false }
+func (wb *odsWorkbook) Close() error        { return nil }
+
+// odsRows decodes the rows of one table. Repeated rows are expanded lazily,
+// and repeated empty rows and cells, which applications write to pad a sheet
+// to its full size, are never materialised.
+type odsRows struct {
+	part    io.ReadCloser
+	decoder *xml.Decoder
+	row     sheetRow
+	repeat  int
+	err     error
+	done    bool
+
+	// merges collects the spanned cells read so far.
+	merges []mergeRange
+}
+
+func (r *odsRows) Next() bool {
+	if r.done || r.err != nil {
+		return false
+	}
+
+	if r.repeat > 0 {
+		r.repeat--
+		r.row = sheetRow{number: r.row.number + 1, hidden: r.row.hidden, cells: r.row.cells}
+		return true
+	}
+
+	next := r.row.number + 1
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			r.err = err
+			return false
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "table-row":
+				repeated := xmlRepeat(el, "number-rows-repeated")
+				cells, err := r.readRow(next)
+				if err != nil {
+					r.err = err
+					return false
+				}
+				if isEmptyCells(cells) {
+					next += repeated
+					continue
+				}
+				r.row = sheetRow{number: next, hidden: odsHidden(el), cells: cells}
+				r.repeat = repeated - 1
+				return true
+			case "table-column", "table-columns", "table-header-columns", "shapes", "named-expressions":
+				if err := r.decoder.Skip(); err != nil {
+					r.err = err
+					return false
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "table" {
+				r.done = true
+				return false
+			}
+		}
+	}
+}
+
+// readRow reads the cells of a row up to its end element.
+func (r *odsRows) readRow(number int) ([]cell, error) {
+	var cells []cell
+	pendingEmpty := 0
+
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if el.Name.Local != "table-cell" && el.Name.Local != "covered-table-cell" {
+				if err := r.decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			c, err := r.readCell(el)
+			if err != nil {
+				return nil, err
+			}
+
+			repeated := xmlRepeat(el, "number-columns-repeated")
+			if cols, rows := xmlRepeat(el, "number-columns-spanned"), xmlRepeat(el, "number-rows-spanned"); cols > 1 || rows > 1 {
+				col := len(cells) + pendingEmpty
+				r.merges = append(r.merges, mergeRange{firstRow: number, lastRow: number + rows - 1, firstCol: col, lastCol: col + cols - 1})
+			}
+			if c.isEmpty() {
+				pendingEmpty += repeated
+				continue
+			}
+			for ; pendingEmpty > 0 && len(cells) < odsMaxColumns; pendingEmpty-- {
+				cells = append(cells, cell{})
+			}
+			for i := 0; i < repeated && len(cells) < odsMaxColumns; i++ {
+				cells = append(cells, c)
+			}
+			pendingEmpty = 0
+		case xml.EndElement:
+			if el.Name.Local == "table-row" {
+				return cells, nil
+			}
+		}
+	}
+}
+
+// readCell decodes a cell's value attributes and its paragraphs of text.
+// Annotations are read apart so that comments do not leak into the value;
+// they and the first link in the text become the cell's metadata.
+func (r *odsRows) readCell(start xml.StartElement) (cell, error) {
+	var text strings.Builder
+	paragraphs := 0
+	var meta models.CellMeta
+
+	for depth := 1; depth > 0; {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return cell{}, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "annotation":
+				comment, err := r.readAnnotation()
+				if err != nil {
+					return cell{}, err
+				}
+				meta.Comment = comment
+				continue
+			case "a":
+				if meta.Hyperlink == "" {
+					meta.Hyperlink = xmlAttr(el, "href")
+				}
+			case "p":
+				if paragraphs > 0 {
+					text.WriteByte('\n')
+				}
+				paragraphs++
+			case "s":
+				count := 1
+				if n, err := strconv.Atoi(xmlAttr(el, "c")); err == nil && n > 0 {
+					count = n
+				}
+				text.WriteString(strings.Repeat(" ", count))
+			case "tab":
+				text.WriteByte('\t')
+			case "line-break":
+				text.WriteByte('\n')
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth > 1 {
+				text.Write(el)
+			}
+		}
+	}
+
+	c := odsCellValue(start, text.String())
+	if meta.Hyperlink != "" || meta.Comment != nil {
+		c.meta = &meta
+	}
+	return c, nil
+}
+
+// readAnnotation decodes an office:annotation up to its end element.
+func (r *odsRows) readAnnotation() (*models.CellComment, error) {
+	var comment models.CellComment
+	var text strings.Builder
+	var inCreator bool
+	paragraphs := 0
+
+	for depth := 1; depth > 0; {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "creator":
+				inCreator = true
+			case "date":
+				if err := r.decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			case "p":
+				if paragraphs > 0 {
+					text.WriteByte('\n')
+				}
+				paragraphs++
+			case "line-break":
+				text.WriteByte('\n')
+			}
+			depth++
+		case xml.EndElement:
+			inCreator = false
+			depth--
+		case xml.CharData:
+			if inCreator {
+				comment.Author += string(el)
+			} else if paragraphs > 0 {
+				text.Write(el)
+			}
+		}
+	}
+
+	comment.Text = text.String()
+	return &comment, nil
+}
+
+var odsDurationPattern = regexp.MustCompile(`^-?PT?(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?$`)
+
+// odsCellValue maps the office:value-type of a cell onto the cell kinds used
+// by the xlsx reader, so that typing behaves the same for both formats.
+func odsCellValue(start xml.StartElement, text string) cell {
+	c := cell{text: text, raw: text, kind: excelize.CellTypeInlineString}
+
+	switch xmlAttr(start, "value-type") {
+	case "float", "percentage", "currency":
+		c.kind = excelize.CellTypeNumber
+		c.raw = xmlAttr(start, "value")
+	case "boolean":
+		c.kind = excelize.CellTypeBool
+		c.raw = xmlAttr(start, "boolean-value")
+	case "date":
+		c.kind = excelize.CellTypeDate
+		c.raw = xmlAttr(start, "date-value")
+		c.numFmt.dateKind = dateTime
+		if len(c.raw) == len("2006-01-02") {
+			c.numFmt.dateKind = dateOnly
+		}
+	case "time":
+		if m := odsDurationPattern.FindStringSubmatch(xmlAttr(start, "time-value")); m != nil {
+			hours, _ := strconv.Atoi(m[1])
+			minutes, _ := strconv.Atoi(m[2])
+			seconds, _ := strconv.ParseFloat(m[3], 64)
+			c.raw = fmt.Sprintf("%02d:%02d:%02d", hours, minutes, int(seconds))
+		}
+	case "":
+		if text == "" {
+			return cell{}
+		}
+	}
+	return c
+}
+
+func (r *odsRows) Row() sheetRow { return r.row }
+func (r *odsRows) Err() error    { return r.err }
+func (r *odsRows) Close() error  { return r.part.Close() }
+
+// xmlAttr returns the value of the first attribute with the given local
+// name, whatever its namespace.
+func xmlAttr(start xml.StartElement, local string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// odsHidden reports whether a row or column is collapsed or filtered out.
+func odsHidden(start xml.StartElement) bool {
+	switch xmlAttr(start, "visibility") {
+	case "collapse", "filter":
+		return true
+	}
+	return false
+}
+
+func xmlRepeat(start xml.StartElement, local string) int {
+	if n, err := strconv.Atoi(xmlAttr(start, local)); err == nil && n > 1 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,156 @@
+package xlsx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/ledger"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+)
+
+// Options controls how a workbook is parsed. The zero value reproduces the
+// behaviour of Parse.
+type Options struct {
+	// Format of the upload. FormatAuto detects it from the content.
+	// The zero value is FormatXLSX.
+	Format Format
+
+	// Password opens encrypted workbooks. It is ignored for files that are
+	// not encrypted.
+	Password string
+
+	Sheets SheetSelector
+
+	// HeaderRow is the 1-based spreadsheet row holding the column headers.
+	// Zero selects automatic detection.
+	HeaderRow int
+
+	// HeaderRows is the number of rows, starting at the header row, whose
+	// names are joined into hierarchical headers such as "Amount.Debit".
+	// Zero lets merged cells decide.
+	HeaderRows int
+
+	// MergedCells decides whether data rows see the value of a merged range
+	// in every cell it covers.
+	MergedCells MergePolicy
+
+	// Values selects between typed JSON values and display strings.
+	Values ValueMode
+
+	// Formulas selects between cached values, formula text and
+	// recalculation for formula cells. Only .xlsx files carry formulas.
+	Formulas FormulaMode
+
+	// Hidden decides whether hidden rows, columns and sheets are read,
+	// left out or tagged.
+	Hidden HiddenPolicy
+
+	// Meta attaches the hyperlinks, comments, number formats and rich text
+	// of cells to their records.
+	Meta bool
+
+	// DuplicateHeaders and BlankHeaders decide how repeated and missing
+	// header names are turned into record keys.
+	DuplicateHeaders DuplicatePolicy
+	BlankHeaders     BlankPolicy
+
+	// Profile, when set, renames headers to canonical field names. It is
+	// applied before the schema, which therefore refers to mapped names.
+	Profile *mapping.Profile
+
+	// Schema, when set, validates every data row. Rows that fail it are
+	// rejected rather than turned into records.
+	Schema *schema.Schema
+
+	// Normalize maps records onto bank transactions, reading amounts and
+	// dates written as text according to Locale. It runs after the schema.
+	Normalize NormalizeMode
+	Locale    ledger.Locale
+
+	// Reconcile decides whether normalized statements whose running balance
+	// breaks are reported or rejected.
+	Reconcile ReconcilePolicy
+
+	// RecordSink, when set, is handed the accepted records in sheet order,
+	// a thousand at a time, instead of them piling up in the result, so that
+	// memory stays flat however long the sheets are. Records handed over
+	// belong to the sink.
+	RecordSink func([]models.Record) error
+
+	// TransactionSink and RejectionSink do the same for transactions and
+	// rejected rows. A sheet's transactions are only handed over once it is
+	// reconciled, and are kept in a temporary file until then.
+	TransactionSink func([]models.Transaction) error
+	RejectionSink   func([]models.RowError) error
+}
+
+// SheetSelector picks the worksheets to parse. The zero value selects the
+// first sheet of the workbook.
+type SheetSelector struct {
+	All  bool
+	Refs []string
+}
+
+// ParseSheetSelector parses the value of the "sheets" upload parameter: an
+// empty string, "all" (or "*"), or a comma separated list of sheet names and
+// zero-based sheet indexes.
+func ParseSheetSelector(value string) (SheetSelector, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return SheetSelector{}, nil
+	}
+	if strings.EqualFold(value, "all") || value == "*" {
+		return SheetSelector{All: true}, nil
+	}
+
+	var selector SheetSelector
+	for _, ref := range strings.Split(value, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			return SheetSelector{}, fmt.Errorf("empty sheet reference in %q", value)
+		}
+		selector.Refs = append(selector.Refs, ref)
+	}
+	return selector, nil
+}
+
+// resolve maps the selector onto the sheet names of a workbook, preserving
+// the requested order and dropping duplicates. A reference that matches a
+// sheet name exactly wins over its interpretation as an index.
+func (s SheetSelector) resolve(sheets []string) ([]string, error) {
+	if s.All {
+		return sheets, nil
+	}
+	if len(s.Refs) == 0 {
+		return sheets[:1], nil
+	}
+
+	resolved := make([]string, 0, len(s.Refs))
+	seen := make(map[string]bool, len(s.Refs))
+	for _, ref := range s.Refs {
+		name, ok := lookupSheet(sheets, ref)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, ref)
+		}
+		if !seen[name] {
+			seen[name] = true
+			resolved = append(resolved, name)
+		}
+	}
+	return resolved, nil
+}
+
+func lookupSheet(sheets []string, ref string) (string, bool) {
+	for _, name := range sheets {
+		if name == ref {
+			return name, true
+		}
+	}
+	if index, err := strconv.Atoi(ref); err == nil && index >= 0 && index < len(sheets) {
+		return sheets[index], true
+	}
+	return "", false
+}
@@ -2,14 +2,17 @@ package xlsx
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/joelovien/go-xlsx-api/internal/ledger"
 	"github.com/joelovien/go-xlsx-api/internal/models"
-	"github.com/xuri/excelize/v2"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
 )
 
 type Parser struct {
@@ -22,63 +25,281 @@ func NewParser(workerPoolSize int) *Parser {
 	}
 }
 
+// ErrSheetNotFound is returned when a requested sheet does not exist in the
+// workbook.
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// ErrInvalidHeaderRow is returned for header row numbers that are not
+// positive integers or lie beyond the last row a worksheet can hold.
+var ErrInvalidHeaderRow = errors.New("header row must be a number from 1 to 1048576")
+
+// ErrInvalidHeaderRows is returned for header heights that are not positive
+// or exceed the rows scanned for the header.
+var ErrInvalidHeaderRows = errors.New("header rows must be a number from 1 to 10")
+
+// ErrSchemaMismatch is returned when a sheet lacks columns that the schema
+// requires.
+var ErrSchemaMismatch = errors.New("sheet does not match schema")
+
+// ErrNoSheets is returned for files without any worksheet.
+var ErrNoSheets = errors.New("file has no sheets")
+
+// ErrNoData is returned when no selected sheet has data below its header.
+var ErrNoData = errors.New("file has no data")
+
+// ErrInvalidHeaders is returned when no usable header row can be found.
+var ErrInvalidHeaders = errors.New("invalid header row")
+
+// ErrRecordSink is returned when a sink of the options fails.
+var ErrRecordSink = errors.New("failed to hand over records")
+
+// recordBatchSize is the most records, transactions or rejections handed
+// to a sink at once.
+const recordBatchSize = 1000
+
+// errEmptySheet marks a sheet without any rows, which is skipped when all
+// sheets are parsed.
+var errEmptySheet = fmt.Errorf("%w: sheet is empty", ErrNoData)
+
 type ParseResult struct {
-	UploadID     string
+	UploadID string
+	// Records, Transactions and Errors hold the accepted records, their
+	// transactions and the rejected rows, unless they were handed to the
+	// sinks of the options.
 	Records      []models.Record
+	Sheets       []models.SheetSummary
+	Metadata     map[string]string
+	Transactions []models.Transaction
 	RowsAccepted int
 	RowsRejected int
-	Errors       []string
+	Errors       []models.RowError
+
+	// Reconciliation is the worst reconciliation status of the sheets.
+	Reconciliation string
 }
 
 func (p *Parser) Parse(ctx context.Context, reader io.Reader, uploadID string) (*ParseResult, error) {
-	f, err := excelize.OpenReader(reader)
+	return p.ParseWithOptions(ctx, reader, uploadID, Options{})
+}
+
+func (p *Parser) ParseWithOptions(ctx context.Context, reader io.Reader, uploadID string, opts Options) (*ParseResult, error) {
+	source, size, cleanup, err := spool(reader)
+	defer cleanup()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open xlsx file: %w", err)
+		return nil, fmt.Errorf("failed to read upload: %w", err)
 	}
-	defer f.Close()
 
-	sheets := f.GetSheetList()
+	wb, err := openWorkbook(source, size, opts.Format, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+	defer wb.Close()
+
+	sheets := wb.sheetNames()
 	if len(sheets) == 0 {
-		return nil, fmt.Errorf("xlsx file has no sheets")
+		return nil, ErrNoSheets
 	}
 
-	sheetName := sheets[0]
+	// Hidden sheets are only skipped when the selection does not name them.
+	candidates := sheets
+	if opts.Hidden == HiddenExclude && len(opts.Sheets.Refs) == 0 {
+		if candidates = visibleSheets(wb, sheets); len(candidates) == 0 {
+			return nil, fmt.Errorf("%w: every sheet is hidden", ErrNoSheets)
+		}
+	}
 
-	rows, err := f.GetRows(sheetName, excelize.Options{})
+	selected, err := opts.Sheets.resolve(candidates)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read rows from sheet %s: %w", sheetName, err)
+		return nil, err
+	}
+
+	values := valueConverter{mode: opts.Values, date1904: wb.uses1904Dates()}
+
+	// A single validator spans every sheet so that uniqueness holds across
+	// the whole file.
+	var validator *schema.Validator
+	if opts.Schema != nil {
+		validator = schema.NewValidator(opts.Schema)
+	}
+
+	result := &ParseResult{
+		UploadID: uploadID,
+		Records:  make([]models.Record, 0),
+		Sheets:   make([]models.SheetSummary, 0, len(selected)),
+		Errors:   make([]models.RowError, 0),
+	}
+
+	for _, sheetName := range selected {
+		merges, err := wb.mergedCells(sheetName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read merged cells from sheet %s: %w", sheetName, err)
+		}
+		layout := sheetLayout{merges: merges}
+		if opts.Hidden != HiddenInclude {
+			layout.hidden = wb.sheetHidden(sheetName)
+			if layout.hiddenColumns, err = wb.hiddenColumns(sheetName); err != nil {
+				return nil, fmt.Errorf("failed to read hidden columns from sheet %s: %w", sheetName, err)
+			}
+		}
+
+		rows, err := wb.openSheet(sheetName, opts.Values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rows from sheet %s: %w", sheetName, err)
+		}
+
+		if opts.Meta {
+			if mw, ok := wb.(metaWorkbook); ok {
+				annotated, err := mw.cellMeta(sheetName, rows, opts.Values)
+				if err != nil {
+					rows.Close()
+					return nil, fmt.Errorf("failed to read cell metadata from sheet %s: %w", sheetName, err)
+				}
+				rows = annotated
+			}
+		}
+
+		var formulas *formulaRows
+		if opts.Formulas != FormulasCached {
+			fw, ok := wb.(formulaWorkbook)
+			if !ok {
+				rows.Close()
+				return nil, fmt.Errorf("%w: formulas can only be read from .xlsx files", ErrUnsupportedFormat)
+			}
+			if formulas, err = fw.formulaRows(sheetName, rows, opts); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read formulas from sheet %s: %w", sheetName, err)
+			}
+			rows = formulas
+		}
+
+		err = p.parseSheet(ctx, sheetName, newCompactRows(rows), layout, opts, values, validator, result)
+		rows.Close()
+		if err == nil && formulas != nil {
+			summary := &result.Sheets[len(result.Sheets)-1]
+			summary.FormulaMismatches, summary.FormulaMismatchCount = formulas.mismatches, formulas.mismatchCount
+		}
+
+		// Workbooks often carry blank sheets; only complain about them when
+		// the caller asked for the sheet explicitly.
+		if errors.Is(err, errEmptySheet) && opts.Sheets.All {
+			continue
+		}
+		if err != nil {
+			if len(selected) > 1 {
+				return nil, fmt.Errorf("sheet %s: %w", sheetName, err)
+			}
+			return nil, err
+		}
 	}
 
-	if len(rows) == 0 {
-		return nil, fmt.Errorf("xlsx file has no data")
+	if len(result.Sheets) == 0 {
+		return nil, ErrNoData
+	}
+	if err := result.flush(opts); err != nil {
+		return nil, err
 	}
 
-	if len(rows) < 2 {
-		return nil, fmt.Errorf("xlsx file must have at least header row and one data row")
+	return result, nil
+}
+
+// flush hands what was collected so far to the sinks of the options, and
+// lets go of what they took.
+func (pr *ParseResult) flush(opts Options) error {
+	if err := flush(&pr.Records, opts.RecordSink); err != nil {
+		return err
 	}
+	if err := flush(&pr.Transactions, opts.TransactionSink); err != nil {
+		return err
+	}
+	return flush(&pr.Errors, opts.RejectionSink)
+}
 
-	
-	headerRowIndex := 0
-	dataStartIndex := 1
+// flush hands items to the sink, if there is one, and lets go of them.
+func flush[T any](items *[]T, sink func([]T) error) error {
+	if sink == nil || len(*items) == 0 {
+		return nil
+	}
+	if err := sink(*items); err != nil {
+		return fmt.Errorf("%w: %w", ErrRecordSink, err)
+	}
+	*items = make([]T, 0)
+	return nil
+}
 
-	if len(rows[0]) == 1 && len(rows) > 8 {
-		headerRowIndex = 7
-		dataStartIndex = 8
+// sheetLayout is what a sheet declares about its cells apart from their
+// values.
+type sheetLayout struct {
+	merges        []mergeRange
+	hidden        bool
+	hiddenColumns map[int]bool
+}
+
+func (p *Parser) parseSheet(ctx context.Context, sheetName string, rows rowIterator, layout sheetLayout, opts Options, values valueConverter, validator *schema.Validator, result *ParseResult) error {
+	merges := layout.merges
+
+	// A failing record sink stops the workers and the reader.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var filler *mergeFiller
+	if opts.MergedCells == MergedFill && len(merges) > 0 {
+		filler = newMergeFiller(merges)
+	}
+	preamble := &preambleReader{values: values, profile: opts.Profile}
+
+	// Only the top of the sheet is buffered, which is enough to locate the
+	// header rows. Everything below them is streamed through the worker pool.
+	// Above an explicit header row only the rows it may be merged with are
+	// kept; those further up are read for the preamble and merge values as
+	// they stream past.
+	headerRows := max(opts.HeaderRows, maxHeaderRows)
+	scan, skip := headerScanRows+headerLookahead+headerRows, 0
+	if opts.HeaderRow > 0 {
+		scan, skip = opts.HeaderRow+headerRows, max(opts.HeaderRow-maxHeaderRows, 0)
+	}
+
+	head := readHead(rows, scan, skip, merges, func(row sheetRow) {
+		preamble.read(row)
+		if filler != nil {
+			filler.fill(row)
+		}
+	})
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows from sheet %s: %w", sheetName, err)
+	}
+
+	if head.len() == 0 {
+		return errEmptySheet
 	}
 
-	if headerRowIndex >= len(rows) {
-		return nil, fmt.Errorf("xlsx file does not have enough rows")
+	if head.len() < 2 {
+		return fmt.Errorf("%w: file must have at least a header row and one data row", ErrInvalidHeaders)
 	}
 
-	if len(rows[headerRowIndex]) == 0 {
-		return nil, fmt.Errorf("xlsx file has no headers")
+	var headerRowIndex int
+	if opts.HeaderRow > 0 {
+		headerRowIndex = opts.HeaderRow - 1
+	} else {
+		headerRowIndex = detectHeaderRow(textRows(head.rows))
 	}
 
-	headers := make([]string, len(rows[headerRowIndex]))
-	for i, cell := range rows[headerRowIndex] {
-		headers[i] = strings.TrimSpace(cell)
+	if headerRowIndex >= head.len() {
+		return fmt.Errorf("%w: file does not have enough rows for header row %d", ErrInvalidHeaders, headerRowIndex+1)
 	}
 
+	headerRowIndex, lastHeaderIndex := headerBlock(head, headerRowIndex, opts.HeaderRows, merges)
+	dataStartIndex := lastHeaderIndex + 1
+
+	if dataStartIndex >= head.len() {
+		return fmt.Errorf("%w: no data rows after header row %d", ErrNoData, lastHeaderIndex+1)
+	}
+
+	if len(head.row(headerRowIndex).cells) == 0 {
+		return fmt.Errorf("%w: row %d is empty", ErrInvalidHeaders, headerRowIndex+1)
+	}
+
+	headers := composeHeaders(head, headerRowIndex, lastHeaderIndex, merges)
+
 	hasHeader := false
 	for _, header := range headers {
 		if header != "" {
@@ -88,83 +309,311 @@ func (p *Parser) Parse(ctx context.Context, reader io.Reader, uploadID string) (
 	}
 
 	if !hasHeader {
-		return nil, fmt.Errorf("xlsx file has no valid headers")
+		return fmt.Errorf("%w: row %d has no header names", ErrInvalidHeaders, headerRowIndex+1)
 	}
 
-	dataRows := rows[dataStartIndex:]
-	result := &ParseResult{
-		UploadID: uploadID,
-		Records:  make([]models.Record, 0, len(dataRows)),
-		Errors:   make([]string, 0),
+	columns, conflicts, err := buildColumns(headers, headerRowIndex+1, layout.hiddenColumns, opts)
+	if err != nil {
+		return err
+	}
+
+	if validator != nil {
+		if missing := validator.Schema().MissingColumns(columns.names); len(missing) > 0 {
+			return fmt.Errorf("%w: missing required columns %s", ErrSchemaMismatch, strings.Join(missing, ", "))
+		}
+	}
+
+	summary := models.SheetSummary{Name: sheetName, HeaderRow: headerRowIndex + 1, HeaderConflicts: conflicts}
+	if lastHeaderIndex > headerRowIndex {
+		summary.HeaderRows = lastHeaderIndex - headerRowIndex + 1
+	}
+	for _, row := range head.rows[:headerRowIndex-head.base] {
+		preamble.read(row)
+	}
+	summary.Metadata = preamble.metadata
+
+	// Transactions are reconciled as they are collected. Their balance
+	// breaks are only known at the end of the sheet, so those bound for a
+	// sink wait in a temporary file until then.
+	var normalizer *ledger.Normalizer
+	var reconciler *ledger.Reconciler
+	var spill *transactionSpill
+	if opts.Normalize == NormalizeTransactions {
+		if normalizer, err = ledger.NewNormalizer(columns.names, summary.Metadata[ledger.FieldCurrency], opts.Locale); err != nil {
+			return err
+		}
+		summary.TransactionColumns = normalizer.Columns()
+		reconciler = ledger.NewReconciler(statementBalance(summary.Metadata, preambleOpeningBalance, opts.Locale))
+		if opts.TransactionSink != nil {
+			if spill, err = newTransactionSpill(); err != nil {
+				return err
+			}
+			defer spill.close()
+		}
+	}
+	if opts.Hidden != HiddenInclude {
+		summary.Hidden = layout.hidden
+		summary.HiddenColumns = hiddenColumnNames(headers, columns, layout.hiddenColumns, opts.Hidden)
+	}
+
+	if filler != nil {
+		for _, row := range head.rows[:dataStartIndex-head.base] {
+			filler.fill(row)
+		}
 	}
 
 	type rowJob struct {
 		index int
-		row   []string
+		row   sheetRow
 	}
 
-	jobs := make(chan rowJob, len(dataRows))
-	results := make(chan models.ParsedRow, len(dataRows))
+	// Channels are sized to the pool rather than the sheet so that memory
+	// stays flat regardless of how many rows are streamed through them.
+	jobs := make(chan rowJob, p.workerPoolSize)
+	results := make(chan models.ParsedRow, p.workerPoolSize)
 
 	// Start worker pool
+	var wg sync.WaitGroup
 	for w := 0; w < p.workerPoolSize; w++ {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			for job := range jobs {
 				select {
 				case <-ctx.Done():
 					return
 				default:
-					parsed := p.parseRow(headers, job.row, job.index)
-					results <- parsed
+				}
+
+				parsed := p.parseRow(columns, job.row, job.index, values, opts.Meta)
+				if parsed.Valid && validator != nil {
+					if violations := validator.Schema().Validate(parsed.Data); len(violations) > 0 {
+						parsed.Valid = false
+						parsed.Errors = violationErrors(job.row.number, columns.index, violations)
+					}
+				}
+				if parsed.Valid && normalizer != nil {
+					tx, violations := normalizer.Normalize(parsed.Data)
+					if len(violations) > 0 {
+						parsed.Valid = false
+						parsed.Errors = violationErrors(job.row.number, columns.index, violations)
+					} else {
+						parsed.Transaction = &tx
+					}
+				}
+				parsed.Index = job.index
+				parsed.RowNumber = job.row.number
+				parsed.Hidden = opts.Hidden == HiddenTag && job.row.hidden
+				select {
+				case results <- parsed:
+				case <-ctx.Done():
+					return
 				}
 			}
 		}()
 	}
 
-	for i, row := range dataRows {
-		normalizedRow := make([]string, len(headers))
-		copy(normalizedRow, row)
+	// Rows left out as hidden are counted by the reader and only read once
+	// it is done.
+	skippedHidden := 0
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+
+		index := 0
+		send := func(row sheetRow) bool {
+			if filler != nil {
+				row = filler.fill(row)
+			}
+			if row.hidden && opts.Hidden == HiddenExclude {
+				skippedHidden++
+				return true
+			}
+			normalizedRow := make([]cell, columns.width(row.cells))
+			copy(normalizedRow, row.cells)
+
+			select {
+			case <-ctx.Done():
+				return false
+			case jobs <- rowJob{index: index, row: sheetRow{number: row.number, hidden: row.hidden, cells: normalizedRow}}:
+				index++
+				return true
+			}
+		}
+
+		for _, row := range head.rows[dataStartIndex-head.base:] {
+			if !send(row) {
+				readErr <- ctx.Err()
+				return
+			}
+		}
+		for rows.Next() {
+			if !send(rows.Row()) {
+				readErr <- ctx.Err()
+				return
+			}
+		}
+		readErr <- rows.Err()
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// A failure to hand over or buffer what was collected stops the sheet.
+	var collectErr error
+	stop := func(err error) {
+		if err != nil && collectErr == nil {
+			collectErr = err
+			cancel()
+		}
+	}
+	collect := func(parsed models.ParsedRow) {
+		if parsed.Hidden {
+			summary.HiddenRows++
+		}
+
+		// Uniqueness depends on earlier rows, so it is checked here, in
+		// sheet order, rather than in the workers.
+		if parsed.Valid && validator != nil {
+			if violations := validator.CheckUnique(parsed.Data); len(violations) > 0 {
+				parsed.Valid = false
+				parsed.Errors = violationErrors(parsed.RowNumber, columns.index, violations)
+			}
+		}
+
+		if parsed.Valid {
+			record := models.Record{
+				ID:        uuid.New().String(),
+				UploadID:  result.UploadID,
+				Sheet:     sheetName,
+				Row:       parsed.Index + 1,
+				SourceRow: parsed.RowNumber,
+				SourceRef: fmt.Sprintf("A%d", parsed.RowNumber),
+				Data:      parsed.Data,
+				Meta:      parsed.Meta,
+				Hidden:    parsed.Hidden,
+				CreatedAt: result.CreatedAt(),
+			}
+			result.Records = append(result.Records, record)
+			if len(result.Records) >= recordBatchSize && collectErr == nil {
+				stop(flush(&result.Records, opts.RecordSink))
+			}
+			if parsed.Transaction != nil {
+				tx := *parsed.Transaction
+				tx.ID = uuid.New().String()
+				tx.UploadID = result.UploadID
+				tx.RecordID = record.ID
+				tx.Sheet = sheetName
+				tx.Row = record.Row
+				tx.SourceRow = record.SourceRow
+				tx.CreatedAt = record.CreatedAt
+				reconciler.Add(tx)
+				if spill != nil {
+					if collectErr == nil {
+						stop(spill.write(tx))
+					}
+				} else {
+					result.Transactions = append(result.Transactions, tx)
+				}
+			}
+			summary.RowsAccepted++
+		} else {
+			summary.RowsRejected++
+			for _, rowErr := range parsed.Errors {
+				rowErr.Sheet = sheetName
+				result.Errors = append(result.Errors, rowErr)
+			}
+			if len(result.Errors) >= recordBatchSize && collectErr == nil {
+				stop(flush(&result.Errors, opts.RejectionSink))
+			}
+		}
+	}
 
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case jobs <- rowJob{index: i, row: normalizedRow}:
+	firstTransaction := len(result.Transactions)
+
+	// Workers finish out of order; results are held back until every
+	// earlier row has been collected so records keep the order of the sheet.
+	pending := make(map[int]models.ParsedRow)
+	next := 0
+	for parsed := range results {
+		pending[parsed.Index] = parsed
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			collect(ready)
 		}
 	}
-	close(jobs)
 
-	for i := 0; i < len(dataRows); i++ {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case parsed := <-results:
-			if parsed.Valid {
-				record := models.Record{
-					ID:        uuid.New().String(),
-					UploadID:  uploadID,
-					Data:      parsed.Data,
-					CreatedAt: result.CreatedAt(),
+	// Wait for the reader so the sheet is never closed underneath it.
+	streamErr := <-readErr
+	summary.HiddenRows += skippedHidden
+	if collectErr != nil {
+		return collectErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if streamErr != nil {
+		return fmt.Errorf("failed to read rows from sheet %s: %w", sheetName, streamErr)
+	}
+
+	if normalizer != nil {
+		rec, breaks, err := reconcileSheet(reconciler, summary.Metadata, opts)
+		if err != nil {
+			return err
+		}
+		summary.Reconciliation = rec
+		result.Reconciliation = worseReconciliation(result.Reconciliation, rec.Status)
+
+		if spill == nil {
+			for _, i := range breaks {
+				result.Transactions[firstTransaction+i].BalanceBreak = true
+			}
+		} else {
+			err := spill.each(func(i int, tx models.Transaction) error {
+				if len(breaks) > 0 && breaks[0] == i {
+					tx.BalanceBreak = true
+					breaks = breaks[1:]
 				}
-				result.Records = append(result.Records, record)
-				result.RowsAccepted++
-			} else {
-				result.RowsRejected++
-				if parsed.Error != "" {
-					result.Errors = append(result.Errors, parsed.Error)
+				result.Transactions = append(result.Transactions, tx)
+				if len(result.Transactions) < recordBatchSize {
+					return nil
 				}
+				return flush(&result.Transactions, opts.TransactionSink)
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
 
-	return result, nil
+	result.Sheets = append(result.Sheets, summary)
+	for key, value := range summary.Metadata {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]string)
+		}
+		if _, ok := result.Metadata[key]; !ok {
+			result.Metadata[key] = value
+		}
+	}
+	result.RowsAccepted += summary.RowsAccepted
+	result.RowsRejected += summary.RowsRejected
+
+	return nil
 }
 
-func (p *Parser) parseRow(headers []string, row []string, transactionIndex int) models.ParsedRow {
+func (p *Parser) parseRow(columns *columnSet, row sheetRow, transactionIndex int, values valueConverter, withMeta bool) models.ParsedRow {
 	// Skip completely empty rows
-	if p.isEmptyRow(row) {
+	if p.isEmptyRow(row.cells) {
 		return models.ParsedRow{
-			Valid: false,
-			Error: "empty row",
+			Valid:  false,
+			Errors: []models.RowError{newRowError(row.number, -1, "", CodeEmptyRow, "empty row", nil)},
 		}
 	}
 
@@ -172,35 +621,79 @@ func (p *Parser) parseRow(headers []string, row []string, transactionIndex int)
 
 	data["Transaction Index"] = transactionIndex + 1
 
-	for i, header := range headers {
-		var value interface{}
-		if i < len(row) {
-			cellValue := strings.TrimSpace(row[i])
-			if cellValue != "" {
-				value = cellValue
+	var meta map[string]models.CellMeta
+	addMeta := func(key string, i int) {
+		if !withMeta || i >= len(row.cells) {
+			return
+		}
+		if m, ok := cellMetadata(row.cells[i]); ok {
+			if meta == nil {
+				meta = make(map[string]models.CellMeta)
 			}
+			meta[key] = m
+		}
+	}
+
+	for i, name := range columns.names {
+		var value interface{}
+		if i < len(row.cells) {
+			value = values.convert(row.cells[i])
+		}
+		// Skip dropped and blank columns
+		if name == "" {
+			continue
 		}
-		// Skip empty header names
-		if strings.TrimSpace(header) != "" {
-			data[header] = value
+		if columns.arrays[name] {
+			list, _ := data[name].([]interface{})
+			addMeta(fmt.Sprintf("%s[%d]", name, len(list)), i)
+			data[name] = append(list, value)
+			continue
+		}
+		data[name] = value
+		addMeta(name, i)
+	}
+
+	// Cells to the right of the header row only have a name when blank
+	// headers are kept.
+	if columns.extra != nil {
+		for i := len(columns.names); i < len(row.cells); i++ {
+			if row.cells[i].isEmpty() {
+				continue
+			}
+			if name := columns.extra(i); name != "" {
+				data[name] = values.convert(row.cells[i])
+				addMeta(name, i)
+			}
 		}
 	}
 
 	return models.ParsedRow{
 		Data:  data,
+		Meta:  meta,
 		Valid: true,
 	}
 }
 
-func (p *Parser) isEmptyRow(row []string) bool {
-	for _, cell := range row {
-		if strings.TrimSpace(cell) != "" {
+func (p *Parser) isEmptyRow(row []cell) bool {
+	for _, c := range row {
+		if !c.isEmpty() {
 			return false
 		}
 	}
 	return true
 }
 
+func textRows(rows []sheetRow) [][]string {
+	texts := make([][]string, len(rows))
+	for r := range rows {
+		texts[r] = make([]string, len(rows[r].cells))
+		for c, value := range rows[r].cells {
+			texts[r][c] = value.text
+		}
+	}
+	return texts
+}
+
 func (pr *ParseResult) CreatedAt() time.Time {
 	return time.Now()
 }
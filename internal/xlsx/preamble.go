@@ -0,0 +1,80 @@
+package xlsx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+)
+
+// preambleReader collects the labelled values found in the rows above the
+// header, such as "Account Number: 12345678" in one cell, "Currency:" and
+// "EUR" in neighbouring cells, or a row of just a label and a value. Labels
+// are mapped to keys by the profile, or by the default statement labels
+// when there is none; the first value of a key wins. Rows are read one at a
+// time so that they need not be kept.
+type preambleReader struct {
+	values   valueConverter
+	profile  *mapping.Profile
+	metadata map[string]string
+}
+
+func (p *preambleReader) add(label, value string) {
+	label, value = strings.TrimSpace(label), strings.TrimSpace(value)
+	if !isLabel(label) || value == "" {
+		return
+	}
+	key := p.profile.PreambleKey(label)
+	if p.metadata == nil {
+		p.metadata = make(map[string]string)
+	}
+	if _, ok := p.metadata[key]; !ok {
+		p.metadata[key] = value
+	}
+}
+
+func (p *preambleReader) read(row sheetRow) {
+	var cells []string
+	for _, c := range row.cells {
+		switch value := p.values.convert(c).(type) {
+		case nil:
+		case float64:
+			cells = append(cells, strconv.FormatFloat(value, 'f', -1, 64))
+		default:
+			cells = append(cells, strings.TrimSpace(fmt.Sprint(value)))
+		}
+	}
+
+	for i := 0; i < len(cells); i++ {
+		text := cells[i]
+		if label, value, ok := strings.Cut(text, ":"); ok && isLabel(label) && !isTimeOrURL(label, value) && strings.TrimSpace(value) != "" {
+			p.add(label, value)
+			continue
+		}
+		if strings.HasSuffix(text, ":") && i+1 < len(cells) {
+			p.add(strings.TrimSuffix(text, ":"), cells[i+1])
+			i++
+			continue
+		}
+		if len(cells) == 2 && i == 0 && !looksTyped(text) {
+			p.add(text, cells[1])
+			i++
+		}
+	}
+}
+
+// isLabel reports whether text can name a value: it must hold a letter.
+func isLabel(text string) bool {
+	return strings.IndexFunc(text, unicode.IsLetter) >= 0
+}
+
+// isTimeOrURL reports whether the colon between label and value belongs to
+// the text itself, as in "Opened at 10:30" or "https://example.com".
+func isTimeOrURL(label, value string) bool {
+	if strings.HasPrefix(value, "//") {
+		return true
+	}
+	return label != "" && value != "" && unicode.IsDigit(rune(label[len(label)-1])) && unicode.IsDigit(rune(value[0]))
+}
@@ -0,0 +1,43 @@
+package xlsx
+
+import (
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/xuri/excelize/v2"
+)
+
+// Rejection codes reported for rows that are not turned into records.
+const (
+	CodeEmptyRow = "empty_row"
+)
+
+// newRowError builds a rejection for a spreadsheet row. col is the 0-based
+// column of the offending cell, or -1 when the whole row is at fault.
+func newRowError(row, col int, column, code, message string, value interface{}) models.RowError {
+	rowErr := models.RowError{
+		Row:     row,
+		Column:  column,
+		Code:    code,
+		Message: message,
+		Value:   value,
+	}
+	if col >= 0 {
+		rowErr.Cell, _ = excelize.CoordinatesToCellName(col+1, row)
+	}
+	return rowErr
+}
+
+// violationErrors converts schema violations on a row into rejections that
+// point at the offending cells. columns maps header names to their 0-based
+// column.
+func violationErrors(row int, columns map[string]int, violations []schema.Violation) []models.RowError {
+	errs := make([]models.RowError, len(violations))
+	for i, v := range violations {
+		col, ok := columns[v.Column]
+		if !ok {
+			col = -1
+		}
+		errs[i] = newRowError(row, col, v.Column, v.Code, v.Message, v.Value)
+	}
+	return errs
+}
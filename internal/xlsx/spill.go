@@ -0,0 +1,65 @@
+package xlsx
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+)
+
+// transactionSpill keeps the transactions of a sheet in a temporary file
+// until the sheet is reconciled, rather than in memory.
+type transactionSpill struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newTransactionSpill() (*transactionSpill, error) {
+	file, err := os.CreateTemp("", "xlsx-transactions-*")
+	if err != nil {
+		return nil, fmt.Errorf("buffer transactions: %w", err)
+	}
+	buf := bufio.NewWriter(file)
+	return &transactionSpill{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (s *transactionSpill) write(tx models.Transaction) error {
+	if err := s.enc.Encode(tx); err != nil {
+		return fmt.Errorf("buffer transactions: %w", err)
+	}
+	return nil
+}
+
+// each reads the transactions back in the order they were written, with
+// their position, and stops at the first error fn returns.
+func (s *transactionSpill) each(fn func(int, models.Transaction) error) error {
+	if err := s.buf.Flush(); err != nil {
+		return fmt.Errorf("buffer transactions: %w", err)
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("read buffered transactions: %w", err)
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(s.file))
+	for i := 0; ; i++ {
+		var tx models.Transaction
+		if err := dec.Decode(&tx); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read buffered transactions: %w", err)
+		}
+		if err := fn(i, tx); err != nil {
+			return err
+		}
+	}
+}
+
+// close removes the file.
+func (s *transactionSpill) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
@@ -0,0 +1,262 @@
+package xlsx
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetRow is a worksheet row together with its 1-based row number.
+type sheetRow struct {
+	number int
+	hidden bool
+	cells  []cell
+}
+
+// rowIterator walks the rows of a worksheet in order.
+type rowIterator interface {
+	Next() bool
+	Row() sheetRow
+	Err() error
+	Close() error
+}
+
+type xmlCell struct {
+	R  string         `xml:"r,attr"`
+	T  string         `xml:"t,attr"`
+	S  int            `xml:"s,attr"`
+	V  string         `xml:"v"`
+	IS *xmlStringItem `xml:"is"`
+}
+
+// sheetStream decodes a worksheet part row by row.
+type sheetStream struct {
+	part    io.ReadCloser
+	decoder *xml.Decoder
+	wb      *xlsxWorkbook
+	row     sheetRow
+	err     error
+	done    bool
+}
+
+func newSheetStream(part io.ReadCloser, wb *xlsxWorkbook) *sheetStream {
+	return &sheetStream{
+		part:    part,
+		decoder: xml.NewDecoder(part),
+		wb:      wb,
+	}
+}
+
+func (s *sheetStream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+
+	for {
+		token, err := s.decoder.Token()
+		if err == io.EOF {
+			s.done = true
+			return false
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if el.Name.Local == "row" {
+				s.err = s.readRow(el)
+				return s.err == nil
+			}
+		case xml.EndElement:
+			if el.Name.Local == "sheetData" {
+				s.done = true
+				return false
+			}
+		}
+	}
+}
+
+func (s *sheetStream) readRow(start xml.StartElement) error {
+	number := s.row.number + 1
+	hidden := false
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "r":
+			if n, err := strconv.Atoi(attr.Value); err == nil {
+				number = n
+			}
+		case "hidden":
+			hidden, _ = strconv.ParseBool(attr.Value)
+		}
+	}
+	s.row = sheetRow{number: number, hidden: hidden}
+
+	col := 0
+	for {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if el.Name.Local != "c" {
+				if err := s.decoder.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var xc xmlCell
+			if err := s.decoder.DecodeElement(&xc, &el); err != nil {
+				return err
+			}
+
+			col++
+			if xc.R != "" {
+				if c, _, err := excelize.CellNameToCoordinates(xc.R); err == nil {
+					col = c
+				}
+			}
+			for len(s.row.cells) < col-1 {
+				s.row.cells = append(s.row.cells, cell{})
+			}
+			s.row.cells = append(s.row.cells, s.wb.cellValue(xc))
+		case xml.EndElement:
+			if el.Name.Local == "row" {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *sheetStream) Row() sheetRow { return s.row }
+func (s *sheetStream) Err() error    { return s.err }
+func (s *sheetStream) Close() error  { return s.part.Close() }
+
+// cellValue resolves a decoded cell against the shared string table and the
+// number formats of the workbook.
+func (wb *xlsxWorkbook) cellValue(xc xmlCell) cell {
+	c := cell{raw: xc.V}
+	if xc.S > 0 && xc.S < len(wb.formats) {
+		c.numFmt = wb.formats[xc.S]
+	}
+
+	switch xc.T {
+	case "s":
+		c.kind = excelize.CellTypeSharedString
+		c.raw = ""
+		if index, err := strconv.Atoi(strings.TrimSpace(xc.V)); err == nil && index >= 0 && index < len(wb.sst) {
+			c.raw = wb.sst[index]
+			c.meta = wb.rich[index]
+		}
+	case "inlineStr":
+		c.kind = excelize.CellTypeInlineString
+		if xc.IS != nil {
+			c.raw = xc.IS.String()
+			if runs := xc.IS.richText(); runs != nil {
+				c.meta = &models.CellMeta{RichText: runs}
+			}
+		}
+	case "str":
+		c.kind = excelize.CellTypeFormula
+	case "b":
+		c.kind = excelize.CellTypeBool
+	case "e":
+		c.kind = excelize.CellTypeError
+	case "d":
+		c.kind = excelize.CellTypeDate
+	case "n":
+		c.kind = excelize.CellTypeNumber
+	default:
+		c.kind = excelize.CellTypeUnset
+	}
+
+	c.text = c.raw
+	return c
+}
+
+// excelizeRows adapts excelize's Rows iterator, which yields formatted
+// display strings.
+type excelizeRows struct {
+	rows *excelize.Rows
+	row  sheetRow
+	err  error
+}
+
+func (r *excelizeRows) Next() bool {
+	if !r.rows.Next() {
+		r.err = r.rows.Error()
+		return false
+	}
+
+	columns, err := r.rows.Columns()
+	if err != nil {
+		r.err = err
+		return false
+	}
+
+	r.row = sheetRow{number: r.row.number + 1, hidden: r.rows.GetRowOpts().Hidden, cells: make([]cell, len(columns))}
+	for i, text := range columns {
+		r.row.cells[i] = cell{text: text}
+	}
+	return true
+}
+
+func (r *excelizeRows) Row() sheetRow { return r.row }
+func (r *excelizeRows) Err() error    { return r.err }
+func (r *excelizeRows) Close() error  { return r.rows.Close() }
+
+// compactRows reports every row number from 1 onwards, filling gaps left by
+// the source with empty rows, and drops the empty rows at the end of a sheet.
+type compactRows struct {
+	src        rowIterator
+	next       int
+	pending    sheetRow
+	hasPending bool
+	row        sheetRow
+}
+
+func newCompactRows(src rowIterator) *compactRows {
+	return &compactRows{src: src, next: 1}
+}
+
+func (c *compactRows) Next() bool {
+	for !c.hasPending {
+		if !c.src.Next() {
+			return false
+		}
+		if row := c.src.Row(); !isEmptyCells(row.cells) {
+			c.pending, c.hasPending = row, true
+		}
+	}
+
+	if c.next < c.pending.number {
+		c.row = sheetRow{number: c.next}
+		c.next++
+		return true
+	}
+
+	c.row, c.hasPending = c.pending, false
+	c.next = c.row.number + 1
+	return true
+}
+
+func (c *compactRows) Row() sheetRow { return c.row }
+func (c *compactRows) Err() error    { return c.src.Err() }
+func (c *compactRows) Close() error  { return c.src.Close() }
+
+func isEmptyCells(cells []cell) bool {
+	for _, c := range cells {
+		if !c.isEmpty() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,241 @@
+package xlsx
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// ValueMode selects how cell values are represented in records.
+type ValueMode int
+
+const (
+	// ValuesTyped emits JSON numbers, booleans and ISO-8601 dates.
+	ValuesTyped ValueMode = iota
+	// ValuesString keeps every cell as its formatted display string, as
+	// earlier releases did.
+	ValuesString
+)
+
+// ErrInvalidValueMode is returned for unknown "values" upload parameters.
+var ErrInvalidValueMode = errors.New("values must be one of typed, string")
+
+// ParseValueMode parses the value of the "values" upload parameter.
+func ParseValueMode(value string) (ValueMode, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "typed":
+		return ValuesTyped, nil
+	case "string", "raw":
+		return ValuesString, nil
+	default:
+		return ValuesTyped, ErrInvalidValueMode
+	}
+}
+
+// valueConverter turns cells into record values according to a ValueMode.
+type valueConverter struct {
+	mode     ValueMode
+	date1904 bool
+}
+
+func (v valueConverter) convert(c cell) interface{} {
+	if v.mode == ValuesString {
+		if text := strings.TrimSpace(c.text); text != "" {
+			return text
+		}
+		return nil
+	}
+	return typedValue(c, v.date1904)
+}
+
+// cell is a single worksheet cell as read from the workbook.
+type cell struct {
+	text   string
+	raw    string
+	kind   excelize.CellType
+	numFmt numberFormat
+
+	// meta holds the hyperlink, comment and rich text of the few cells
+	// that have any.
+	meta *models.CellMeta
+}
+
+func (c cell) isEmpty() bool {
+	return strings.TrimSpace(c.text) == "" && strings.TrimSpace(c.raw) == ""
+}
+
+// dateKind describes which parts of a timestamp a number format displays.
+type dateKind int
+
+const (
+	notDate dateKind = iota
+	dateOnly
+	timeOnly
+	dateTime
+)
+
+// numberFormat is the number format applied to a cell: its code, reported
+// as cell metadata, and what is needed to type its value.
+type numberFormat struct {
+	code     string
+	dateKind dateKind
+}
+
+var builtInDateFormats = map[int]dateKind{
+	14: dateOnly, 15: dateOnly, 16: dateOnly, 17: dateOnly,
+	18: timeOnly, 19: timeOnly, 20: timeOnly, 21: timeOnly,
+	22: dateTime,
+	27: dateOnly, 28: dateOnly, 29: dateOnly, 30: dateOnly, 31: dateOnly,
+	32: timeOnly, 33: timeOnly, 34: timeOnly, 35: timeOnly, 36: dateOnly,
+	45: timeOnly, 46: timeOnly, 47: timeOnly,
+	50: dateOnly, 51: dateOnly, 52: dateOnly, 53: dateOnly, 54: dateOnly,
+	55: timeOnly, 56: timeOnly, 57: dateOnly, 58: dateOnly,
+}
+
+// builtInFormatCodes are the codes of the number formats that workbooks
+// refer to by ID only, as listed by ECMA-376. ID 0 is General.
+var builtInFormatCodes = map[int]string{
+	1: "0", 2: "0.00", 3: "#,##0", 4: "#,##0.00",
+	9: "0%", 10: "0.00%", 11: "0.00E+00", 12: "# ?/?", 13: "# ??/??",
+	14: "mm-dd-yy", 15: "d-mmm-yy", 16: "d-mmm", 17: "mmm-yy",
+	18: "h:mm AM/PM", 19: "h:mm:ss AM/PM", 20: "h:mm", 21: "h:mm:ss", 22: "m/d/yy h:mm",
+	37: "#,##0 ;(#,##0)", 38: "#,##0 ;[Red](#,##0)", 39: "#,##0.00;(#,##0.00)", 40: "#,##0.00;[Red](#,##0.00)",
+	45: "mm:ss", 46: "[h]:mm:ss", 47: "mmss.0", 48: "##0.0E+0", 49: "@",
+}
+
+// newNumberFormat classifies a built-in number format ID or, when code is
+// not empty, a custom format code.
+func newNumberFormat(id int, code string) numberFormat {
+	if code == "" {
+		return numberFormat{code: builtInFormatCodes[id], dateKind: builtInDateFormats[id]}
+	}
+	return numberFormat{code: code, dateKind: classifyFormatCode(code)}
+}
+
+// classifyFormatCode inspects the first section of a custom number format
+// for date and time tokens, ignoring quoted literals, escaped characters and
+// bracketed colour or locale modifiers.
+func classifyFormatCode(code string) dateKind {
+	var hasDate, hasTime, hasMonthOrMinute bool
+
+	for i := 0; i < len(code); i++ {
+		switch ch := code[i]; ch {
+		case ';':
+			i = len(code)
+		case '"':
+			for i++; i < len(code) && code[i] != '"'; i++ {
+			}
+		case '\\', '_', '*':
+			i++
+		case '[':
+			end := strings.IndexByte(code[i:], ']')
+			if end < 0 {
+				i = len(code)
+				continue
+			}
+			switch strings.ToLower(code[i+1 : i+end]) {
+			case "h", "hh", "m", "mm", "s", "ss":
+				hasTime = true
+			}
+			i += end
+		default:
+			switch ch | 0x20 {
+			case 'y', 'd':
+				hasDate = true
+			case 'h', 's':
+				hasTime = true
+			case 'm':
+				hasMonthOrMinute = true
+			}
+		}
+	}
+
+	if hasMonthOrMinute && !hasTime {
+		hasDate = true
+	}
+
+	switch {
+	case hasDate && hasTime:
+		return dateTime
+	case hasDate:
+		return dateOnly
+	case hasTime:
+		return timeOnly
+	default:
+		return notDate
+	}
+}
+
+// typedValue converts a cell into the JSON value stored on a record.
+func typedValue(c cell, date1904 bool) interface{} {
+	raw := strings.TrimSpace(c.raw)
+	if raw == "" {
+		return nil
+	}
+
+	switch c.kind {
+	case excelize.CellTypeBool:
+		switch strings.ToUpper(raw) {
+		case "1", "TRUE":
+			return true
+		case "0", "FALSE":
+			return false
+		}
+		return raw
+	case excelize.CellTypeDate:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return formatDate(t, c.numFmt.dateKind)
+			}
+		}
+		return raw
+	case excelize.CellTypeUnset, excelize.CellTypeNumber:
+		number, ok := parseNumber(raw)
+		if !ok {
+			return raw
+		}
+		if c.numFmt.dateKind != notDate {
+			if t, err := excelize.ExcelDateToTime(number, date1904); err == nil {
+				return formatDate(t, c.numFmt.dateKind)
+			}
+		}
+		return jsonNumber(number)
+	default:
+		return raw
+	}
+}
+
+// parseNumber reads a finite number. strconv also accepts "NaN" and
+// "Infinity", which JSON cannot represent, so those stay text.
+func parseNumber(raw string) (float64, bool) {
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// jsonNumber keeps integral values as integers so they are not rendered in
+// exponent form by encoding/json.
+func jsonNumber(v float64) interface{} {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return int64(v)
+	}
+	return v
+}
+
+func formatDate(t time.Time, kind dateKind) string {
+	switch kind {
+	case timeOnly:
+		return t.Format("15:04:05")
+	case dateOnly:
+		return t.Format("2006-01-02")
+	default:
+		return t.Format("2006-01-02T15:04:05")
+	}
+}
@@ -0,0 +1,76 @@
+package xlsx
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// HiddenPolicy decides what happens to the rows, columns and sheets that a
+// workbook hides from view.
+type HiddenPolicy int
+
+const (
+	// HiddenInclude reads hidden cells like any other.
+	HiddenInclude HiddenPolicy = iota
+	// HiddenExclude leaves hidden data rows and columns out of records, and
+	// hidden sheets out of the sheets parsed by default.
+	HiddenExclude
+	// HiddenTag reads hidden cells but marks them on records and sheet
+	// summaries.
+	HiddenTag
+)
+
+// ErrInvalidHiddenPolicy is returned for unknown hidden cell policies.
+var ErrInvalidHiddenPolicy = errors.New("hidden must be one of include, exclude, tag")
+
+// ParseHiddenPolicy parses the value of the "hidden" upload parameter.
+func ParseHiddenPolicy(value string) (HiddenPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "include":
+		return HiddenInclude, nil
+	case "exclude":
+		return HiddenExclude, nil
+	case "tag":
+		return HiddenTag, nil
+	default:
+		return HiddenInclude, ErrInvalidHiddenPolicy
+	}
+}
+
+// visibleSheets drops the hidden sheets of a workbook.
+func visibleSheets(wb workbook, sheets []string) []string {
+	visible := make([]string, 0, len(sheets))
+	for _, name := range sheets {
+		if !wb.sheetHidden(name) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// hiddenColumnNames lists the hidden columns of the header for a sheet
+// summary: by record key when they are tagged, and by header, or column
+// letter for blank ones, when they are excluded and have no key.
+func hiddenColumnNames(headers []string, columns *columnSet, hidden map[int]bool, policy HiddenPolicy) []string {
+	cols := make([]int, 0, len(hidden))
+	for col := range hidden {
+		if col < len(headers) {
+			cols = append(cols, col)
+		}
+	}
+	sort.Ints(cols)
+
+	var names []string
+	for _, col := range cols {
+		switch {
+		case policy == HiddenTag && columns.names[col] != "":
+			names = append(names, columns.names[col])
+		case policy == HiddenExclude && headers[col] != "":
+			names = append(names, headers[col])
+		case policy == HiddenExclude:
+			names = append(names, blankColumnName(col))
+		}
+	}
+	return names
+}
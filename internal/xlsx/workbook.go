@@ -0,0 +1,477 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	relTypeOfficeDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+	relTypeWorksheet      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet"
+)
+
+// xlsxWorkbook reads an .xlsx package straight from its zip container.
+// excelize cannot serve typed values with flat memory: opening a File reads
+// the whole package into memory, its Rows iterator yields strings without
+// the type or style of each cell, and its per-cell getters load the whole
+// worksheet. Worksheets are therefore decoded here as a stream, holding only
+// the shared string table and the styles in memory, and what a worksheet
+// keeps outside its cell data is read in one more pass. excelize is opened
+// for what it alone provides: formatted display strings and formulas.
+type xlsxWorkbook struct {
+	source   io.ReaderAt
+	size     int64
+	zr       *zip.Reader
+	sheets   []xlsxSheet
+	sst      []string
+	formats  []numberFormat
+	date1904 bool
+
+	// rich holds the runs of shared strings with formatted text, by index.
+	rich map[int]*models.CellMeta
+
+	layouts map[string]*xlsxLayout
+
+	excel *excelize.File
+}
+
+type xlsxSheet struct {
+	name  string
+	path  string
+	state string
+}
+
+type xmlRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xmlWorkbook struct {
+	WorkbookPr struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name  string `xml:"name,attr"`
+		State string `xml:"state,attr"`
+		RID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xmlStyleSheet struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xmlStringItem struct {
+	T    *string `xml:"t"`
+	Runs []struct {
+		T   string      `xml:"t"`
+		RPr xmlRunProps `xml:"rPr"`
+	} `xml:"r"`
+}
+
+type xmlRunProps struct {
+	B      *xmlValue `xml:"b"`
+	I      *xmlValue `xml:"i"`
+	U      *xmlValue `xml:"u"`
+	Strike *xmlValue `xml:"strike"`
+	Color  *struct {
+		RGB string `xml:"rgb,attr"`
+	} `xml:"color"`
+}
+
+// xmlValue is a run property such as <b/>, which is on unless its val
+// attribute turns it off.
+type xmlValue struct {
+	Val string `xml:"val,attr"`
+}
+
+func (v *xmlValue) on() bool {
+	if v == nil {
+		return false
+	}
+	switch v.Val {
+	case "0", "false", "none":
+		return false
+	}
+	return true
+}
+
+func (si xmlStringItem) String() string {
+	if si.T != nil && len(si.Runs) == 0 {
+		return *si.T
+	}
+	var b strings.Builder
+	if si.T != nil {
+		b.WriteString(*si.T)
+	}
+	for _, run := range si.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+// richText returns the formatted runs of the item, or nil for plain text.
+func (si xmlStringItem) richText() []models.RichTextRun {
+	if len(si.Runs) == 0 {
+		return nil
+	}
+	var runs []models.RichTextRun
+	if si.T != nil && *si.T != "" {
+		runs = append(runs, models.RichTextRun{Text: *si.T})
+	}
+	for _, run := range si.Runs {
+		r := models.RichTextRun{
+			Text:      run.T,
+			Bold:      run.RPr.B.on(),
+			Italic:    run.RPr.I.on(),
+			Underline: run.RPr.U.on(),
+			Strike:    run.RPr.Strike.on(),
+		}
+		if run.RPr.Color != nil {
+			r.Color = run.RPr.Color.RGB
+		}
+		runs = append(runs, r)
+	}
+	return runs
+}
+
+func openXLSXWorkbook(source io.ReaderAt, size int64) (*xlsxWorkbook, error) {
+	zr, err := zip.NewReader(source, size)
+	if err != nil {
+		return nil, err
+	}
+
+	wb := &xlsxWorkbook{source: source, size: size, zr: zr}
+
+	workbookPath := "xl/workbook.xml"
+	var rootRels xmlRelationships
+	if err := wb.decodePart("_rels/.rels", &rootRels); err == nil {
+		for _, rel := range rootRels.Relationships {
+			if rel.Type == relTypeOfficeDocument {
+				workbookPath = resolvePartPath("", rel.Target)
+				break
+			}
+		}
+	}
+
+	var workbook xmlWorkbook
+	if err := wb.decodePart(workbookPath, &workbook); err != nil {
+		return nil, fmt.Errorf("failed to read workbook part: %w", err)
+	}
+	wb.date1904, _ = strconv.ParseBool(workbook.WorkbookPr.Date1904)
+
+	workbookDir := path.Dir(workbookPath)
+	var rels xmlRelationships
+	relsPath := path.Join(workbookDir, "_rels", path.Base(workbookPath)+".rels")
+	if err := wb.decodePart(relsPath, &rels); err != nil {
+		return nil, fmt.Errorf("failed to read workbook relationships: %w", err)
+	}
+
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		targets[rel.ID] = rel.Target
+		switch {
+		case strings.HasSuffix(rel.Type, "/sharedStrings"):
+			if err := wb.readSharedStrings(resolvePartPath(workbookDir, rel.Target)); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(rel.Type, "/styles"):
+			if err := wb.readStyles(resolvePartPath(workbookDir, rel.Target)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, sheet := range workbook.Sheets {
+		target, ok := targets[sheet.RID]
+		if !ok {
+			continue
+		}
+		wb.sheets = append(wb.sheets, xlsxSheet{
+			name:  sheet.Name,
+			path:  resolvePartPath(workbookDir, target),
+			state: sheet.State,
+		})
+	}
+
+	return wb, nil
+}
+
+// resolvePartPath resolves a relationship target against the directory of
+// the part that declared it.
+func resolvePartPath(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Clean(path.Join(dir, target))
+}
+
+func (wb *xlsxWorkbook) openPart(name string) (io.ReadCloser, error) {
+	for _, file := range wb.zr.File {
+		if strings.EqualFold(file.Name, name) {
+			return file.Open()
+		}
+	}
+	return nil, fmt.Errorf("part %s not found", name)
+}
+
+func (wb *xlsxWorkbook) decodePart(name string, v interface{}) error {
+	part, err := wb.openPart(name)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	return xml.NewDecoder(part).Decode(v)
+}
+
+// readSharedStrings decodes the shared string table one item at a time.
+func (wb *xlsxWorkbook) readSharedStrings(name string) error {
+	part, err := wb.openPart(name)
+	if err != nil {
+		return nil
+	}
+	defer part.Close()
+
+	decoder := xml.NewDecoder(part)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read shared strings: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "si" {
+			continue
+		}
+
+		var item xmlStringItem
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return fmt.Errorf("failed to read shared strings: %w", err)
+		}
+		if runs := item.richText(); runs != nil {
+			if wb.rich == nil {
+				wb.rich = make(map[int]*models.CellMeta)
+			}
+			wb.rich[len(wb.sst)] = &models.CellMeta{RichText: runs}
+		}
+		wb.sst = append(wb.sst, item.String())
+	}
+}
+
+func (wb *xlsxWorkbook) readStyles(name string) error {
+	var styles xmlStyleSheet
+	if err := wb.decodePart(name, &styles); err != nil {
+		return fmt.Errorf("failed to read styles: %w", err)
+	}
+
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, numFmt := range styles.NumFmts {
+		custom[numFmt.ID] = numFmt.Code
+	}
+
+	wb.formats = make([]numberFormat, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		wb.formats[i] = newNumberFormat(xf.NumFmtID, custom[xf.NumFmtID])
+	}
+	return nil
+}
+
+func (wb *xlsxWorkbook) sheetNames() []string {
+	names := make([]string, len(wb.sheets))
+	for i, sheet := range wb.sheets {
+		names[i] = sheet.name
+	}
+	return names
+}
+
+func (wb *xlsxWorkbook) sheet(name string) (xlsxSheet, bool) {
+	for _, sheet := range wb.sheets {
+		if sheet.name == name {
+			return sheet, true
+		}
+	}
+	return xlsxSheet{}, false
+}
+
+// openSheet returns a row iterator over the named worksheet. Display strings
+// are produced by excelize's Rows iterator; typed values come from decoding
+// the worksheet part directly.
+func (wb *xlsxWorkbook) openSheet(name string, mode ValueMode) (rowIterator, error) {
+	sheet, ok := wb.sheet(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+	}
+
+	if mode == ValuesString {
+		f, err := wb.excelize()
+		if err != nil {
+			return nil, err
+		}
+		rows, err := f.Rows(name)
+		if err != nil {
+			return nil, err
+		}
+		return &excelizeRows{rows: rows}, nil
+	}
+
+	part, err := wb.openPart(sheet.path)
+	if err != nil {
+		return nil, err
+	}
+	return newSheetStream(part, wb), nil
+}
+
+func (wb *xlsxWorkbook) mergedCells(name string) ([]mergeRange, error) {
+	layout, err := wb.layout(name)
+	if err != nil {
+		return nil, err
+	}
+	return layout.merges, nil
+}
+
+func (wb *xlsxWorkbook) sheetHidden(name string) bool {
+	sheet, _ := wb.sheet(name)
+	return sheet.state == "hidden" || sheet.state == "veryHidden"
+}
+
+func (wb *xlsxWorkbook) hiddenColumns(name string) (map[int]bool, error) {
+	layout, err := wb.layout(name)
+	if err != nil {
+		return nil, err
+	}
+	return layout.hiddenColumns, nil
+}
+
+// xlsxLayout is what a worksheet part holds besides its cell data: the
+// column definitions before it, and the merged ranges and hyperlinks after
+// it.
+type xlsxLayout struct {
+	hiddenColumns map[int]bool
+	merges        []mergeRange
+	hyperlinks    []sheetHyperlink
+}
+
+// sheetHyperlink is a hyperlink element of a worksheet. id names the
+// relationship holding an external target; location is a place within the
+// workbook.
+type sheetHyperlink struct {
+	ref      string
+	id       string
+	location string
+}
+
+// layout reads the layout of a worksheet in a single pass on first use.
+// The decoder only tokenizes the cell data on the way past it, without
+// resolving namespaces, so the pass costs much less than reading the rows.
+func (wb *xlsxWorkbook) layout(name string) (*xlsxLayout, error) {
+	if layout, ok := wb.layouts[name]; ok {
+		return layout, nil
+	}
+	sheet, ok := wb.sheet(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+	}
+
+	part, err := wb.openPart(sheet.path)
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	layout := &xlsxLayout{hiddenColumns: make(map[int]bool)}
+	decoder := xml.NewDecoder(part)
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read worksheet layout: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "col":
+			if on, _ := strconv.ParseBool(xmlAttr(start, "hidden")); !on {
+				continue
+			}
+			first, _ := strconv.Atoi(xmlAttr(start, "min"))
+			last, _ := strconv.Atoi(xmlAttr(start, "max"))
+			for col := max(first, 1); col <= min(last, excelize.MaxColumns); col++ {
+				layout.hiddenColumns[col-1] = true
+			}
+		case "mergeCell":
+			if m, ok := parseMergeRef(xmlAttr(start, "ref")); ok {
+				layout.merges = append(layout.merges, m)
+			}
+		case "hyperlink":
+			layout.hyperlinks = append(layout.hyperlinks, sheetHyperlink{
+				ref:      xmlAttr(start, "ref"),
+				id:       xmlAttr(start, "id"),
+				location: xmlAttr(start, "location"),
+			})
+		}
+	}
+
+	if wb.layouts == nil {
+		wb.layouts = make(map[string]*xlsxLayout)
+	}
+	wb.layouts[name] = layout
+	return layout, nil
+}
+
+// excelize opens the package with excelize on first use. Uploads spooled to
+// disk are reopened by path so that excelize can extract large parts to
+// temporary files instead of reading the whole package into memory.
+func (wb *xlsxWorkbook) excelize() (*excelize.File, error) {
+	if wb.excel != nil {
+		return wb.excel, nil
+	}
+
+	var err error
+	if file, ok := wb.source.(*os.File); ok {
+		wb.excel, err = excelize.OpenFile(file.Name())
+	} else {
+		wb.excel, err = excelize.OpenReader(io.NewSectionReader(wb.source, 0, wb.size))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xlsx file: %w", err)
+	}
+	return wb.excel, nil
+}
+
+func (wb *xlsxWorkbook) uses1904Dates() bool {
+	return wb.date1904
+}
+
+func (wb *xlsxWorkbook) Close() error {
+	if wb.excel != nil {
+		return wb.excel.Close()
+	}
+	return nil
+}
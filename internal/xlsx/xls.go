@@ -0,0 +1,906 @@
+package xlsx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/richardlehane/mscfb"
+	"github.com/xuri/excelize/v2"
+)
+
+// BIFF8 record types read by the .xls reader.
+const (
+	biffFormula    = 0x0006
+	biffEOF        = 0x000A
+	biffNote       = 0x001C
+	biffDateMode   = 0x0022
+	biffFilePass   = 0x002F
+	biffContinue   = 0x003C
+	biffObj        = 0x005D
+	biffColInfo    = 0x007D
+	biffBoundSheet = 0x0085
+	biffMulRK      = 0x00BD
+	biffXF         = 0x00E0
+	biffMergeCells = 0x00E5
+	biffSST        = 0x00FC
+	biffLabelSST   = 0x00FD
+	biffTxO        = 0x01B6
+	biffHLink      = 0x01B8
+	biffDimensions = 0x0200
+	biffNumber     = 0x0203
+	biffLabel      = 0x0204
+	biffBoolErr    = 0x0205
+	biffString     = 0x0207
+	biffRow        = 0x0208
+	biffRK         = 0x027E
+	biffFormat     = 0x041E
+	biffBOF        = 0x0809
+
+	biffVersion8 = 0x0600
+)
+
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// xlsWorkbook reads a BIFF8 workbook stream from an OLE compound file. The
+// globals are read once; each worksheet is decoded as a stream of cell
+// records from its offset in the workbook stream.
+type xlsWorkbook struct {
+	stream   *mscfb.File
+	sheets   []xlsSheet
+	sst      []string
+	formats  []numberFormat
+	date1904 bool
+}
+
+type xlsSheet struct {
+	name   string
+	offset int64
+	state  byte
+}
+
+func openXLSWorkbook(source io.ReaderAt) (*xlsWorkbook, error) {
+	doc, err := mscfb.New(source)
+	if err != nil {
+		return nil, err
+	}
+
+	wb := &xlsWorkbook{}
+	biff5 := false
+	for _, file := range doc.File {
+		switch file.Name {
+		case "Workbook":
+			wb.stream = file
+		case "Book":
+			biff5 = true
+		}
+	}
+	// Files saved for both BIFF5 and BIFF8 readers carry both streams.
+	if wb.stream == nil && biff5 {
+		return nil, fmt.Errorf("BIFF5 workbooks are not supported")
+	}
+	if wb.stream == nil {
+		return nil, fmt.Errorf("workbook stream not found")
+	}
+
+	if err := wb.readGlobals(); err != nil {
+		return nil, err
+	}
+	return wb, nil
+}
+
+// readGlobals reads the workbook globals substream: sheet offsets, the
+// shared string table, number formats and the date system.
+func (wb *xlsWorkbook) readGlobals() error {
+	records, err := wb.records(0)
+	if err != nil {
+		return err
+	}
+
+	typ, body, err := records.next()
+	if err != nil {
+		return err
+	}
+	if typ != biffBOF || len(body) < 2 || binary.LittleEndian.Uint16(body) != biffVersion8 {
+		return fmt.Errorf("not a BIFF8 workbook")
+	}
+
+	customFormats := make(map[int]string)
+	var xfFormats []int
+	var sst [][]byte
+
+	for {
+		typ, body, err := records.next()
+		if err != nil {
+			return err
+		}
+
+		// The shared string table spills into CONTINUE records, whose
+		// boundaries matter when decoding it.
+		if sst != nil {
+			if typ == biffContinue {
+				sst = append(sst, append([]byte(nil), body...))
+				continue
+			}
+			wb.sst = decodeSST(sst)
+			sst = nil
+		}
+
+		switch typ {
+		case biffEOF:
+			wb.formats = make([]numberFormat, len(xfFormats))
+			for i, id := range xfFormats {
+				wb.formats[i] = newNumberFormat(id, customFormats[id])
+			}
+			return nil
+		case biffFilePass:
+			return fmt.Errorf("%w: decrypting .xls files is not supported", ErrEncrypted)
+		case biffDateMode:
+			wb.date1904 = len(body) >= 2 && binary.LittleEndian.Uint16(body) == 1
+		case biffBoundSheet:
+			if len(body) < 8 {
+				continue
+			}
+			// Only worksheets; chart and macro sheets have no cells.
+			if body[5] != 0 {
+				continue
+			}
+			name, _ := readShortString(body[6:])
+			wb.sheets = append(wb.sheets, xlsSheet{
+				name:   name,
+				offset: int64(binary.LittleEndian.Uint32(body)),
+				state:  body[4],
+			})
+		case biffFormat:
+			if len(body) < 4 {
+				continue
+			}
+			code, _ := readUnicodeString(body[2:])
+			customFormats[int(binary.LittleEndian.Uint16(body))] = code
+		case biffXF:
+			if len(body) < 4 {
+				continue
+			}
+			xfFormats = append(xfFormats, int(binary.LittleEndian.Uint16(body[2:])))
+		case biffSST:
+			sst = [][]byte{append([]byte(nil), body...)}
+		}
+	}
+}
+
+// records positions the workbook stream at offset and returns a reader over
+// its records. Only one reader is used at a time.
+func (wb *xlsWorkbook) records(offset int64) (*biffReader, error) {
+	if _, err := wb.stream.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &biffReader{r: bufio.NewReaderSize(wb.stream, 64*1024)}, nil
+}
+
+func (wb *xlsWorkbook) sheetNames() []string {
+	names := make([]string, len(wb.sheets))
+	for i, sheet := range wb.sheets {
+		names[i] = sheet.name
+	}
+	return names
+}
+
+func (wb *xlsWorkbook) openSheet(name string, mode ValueMode) (rowIterator, error) {
+	for _, sheet := range wb.sheets {
+		if sheet.name != name {
+			continue
+		}
+		records, err := wb.records(sheet.offset)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsRows{wb: wb, records: records}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+}
+
+// mergedCells collects the MERGECELLS records of a sheet, which follow its
+// cell records. Records are skipped without being decoded.
+func (wb *xlsWorkbook) mergedCells(name string) ([]mergeRange, error) {
+	for _, sheet := range wb.sheets {
+		if sheet.name != name {
+			continue
+		}
+		records, err := wb.records(sheet.offset)
+		if err != nil {
+			return nil, err
+		}
+
+		var merges []mergeRange
+		for {
+			typ, body, err := records.next()
+			if err == io.EOF || (err == nil && typ == biffEOF) {
+				return merges, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if typ != biffMergeCells || len(body) < 2 {
+				continue
+			}
+			count := int(binary.LittleEndian.Uint16(body))
+			for i, pos := 0, 2; i < count && pos+8 <= len(body); i, pos = i+1, pos+8 {
+				merges = append(merges, mergeRange{
+					firstRow: int(binary.LittleEndian.Uint16(body[pos:])) + 1,
+					lastRow:  int(binary.LittleEndian.Uint16(body[pos+2:])) + 1,
+					firstCol: int(binary.LittleEndian.Uint16(body[pos+4:])),
+					lastCol:  int(binary.LittleEndian.Uint16(body[pos+6:])),
+				})
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+}
+
+func (wb *xlsWorkbook) sheetHidden(name string) bool {
+	for _, sheet := range wb.sheets {
+		if sheet.name == name {
+			return sheet.state != 0
+		}
+	}
+	return false
+}
+
+// hiddenColumns collects the COLINFO records of a sheet, which precede its
+// dimensions and cell records.
+func (wb *xlsWorkbook) hiddenColumns(name string) (map[int]bool, error) {
+	for _, sheet := range wb.sheets {
+		if sheet.name != name {
+			continue
+		}
+		records, err := wb.records(sheet.offset)
+		if err != nil {
+			return nil, err
+		}
+
+		hidden := make(map[int]bool)
+		for {
+			typ, body, err := records.next()
+			if err == io.EOF || (err == nil && (typ == biffEOF || typ == biffDimensions)) {
+				return hidden, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if typ != biffColInfo || len(body) < 10 || binary.LittleEndian.Uint16(body[8:])&0x0001 == 0 {
+				continue
+			}
+			first, last := int(binary.LittleEndian.Uint16(body)), int(binary.LittleEndian.Uint16(body[2:]))
+			for col := first; col <= last && col < excelize.MaxColumns; col++ {
+				hidden[col] = true
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+}
+
+// cellMeta attaches the hyperlinks and comments of a worksheet to its rows.
+// Both follow the cell records, so the sheet is scanned for them first and
+// its rows are then read afresh, the scan having moved the shared stream.
+// Number formats are already set on the cells.
+func (wb *xlsWorkbook) cellMeta(name string, rows rowIterator, mode ValueMode) (rowIterator, error) {
+	notes, err := wb.sheetNotes(name)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if rows, err = wb.openSheet(name, mode); err != nil {
+		return nil, err
+	}
+	return &metaRows{src: rows, notes: notes}, nil
+}
+
+// sheetNotes collects the HLINK and NOTE records of a sheet by row. The text
+// of a comment is kept in the text box of its drawing object: a TXO record
+// following the OBJ record, whose characters are in the CONTINUE records
+// after it. Embedded chart substreams are skipped.
+func (wb *xlsWorkbook) sheetNotes(name string) (map[int][]cellNote, error) {
+	for _, sheet := range wb.sheets {
+		if sheet.name != name {
+			continue
+		}
+		records, err := wb.records(sheet.offset)
+		if err != nil {
+			return nil, err
+		}
+
+		notes := make(map[int][]cellNote)
+		texts := make(map[uint16]string)
+		var object uint16
+		var text strings.Builder
+		pending := 0
+		depth := 0
+		for {
+			typ, body, err := records.next()
+			if err == io.EOF {
+				return notes, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if pending > 0 {
+				if typ == biffContinue && len(body) > 0 {
+					wide := body[0]&0x01 != 0
+					chars, n := decodeChars(body[1:], pending, wide)
+					if wide {
+						n /= 2
+					}
+					text.WriteString(chars)
+					if pending -= n; n > 0 && pending > 0 {
+						continue
+					}
+				}
+				texts[object] = text.String()
+				pending = 0
+			}
+
+			switch typ {
+			case biffBOF:
+				depth++
+				continue
+			case biffEOF:
+				if depth--; depth <= 0 {
+					return notes, nil
+				}
+				continue
+			}
+			if depth > 1 {
+				continue
+			}
+
+			switch typ {
+			case biffObj:
+				// The first subrecord is the common object data: its type,
+				// size, object type and id.
+				if len(body) >= 8 && binary.LittleEndian.Uint16(body) == 0x0015 {
+					object = binary.LittleEndian.Uint16(body[6:])
+				}
+			case biffTxO:
+				if len(body) >= 12 {
+					text.Reset()
+					if pending = int(binary.LittleEndian.Uint16(body[10:])); pending == 0 {
+						texts[object] = ""
+					}
+				}
+			case biffNote:
+				if len(body) < 8 {
+					continue
+				}
+				value, ok := texts[binary.LittleEndian.Uint16(body[6:])]
+				if !ok {
+					continue
+				}
+				author, _ := readUnicodeString(body[8:])
+				comment := newCellComment(author, value)
+				row, col := int(binary.LittleEndian.Uint16(body)), int(binary.LittleEndian.Uint16(body[2:]))
+				addNote(notes, row+1, col, row+1, col, func(meta *models.CellMeta) { meta.Comment = comment })
+			case biffHLink:
+				if len(body) < 8 {
+					continue
+				}
+				target := hlinkTarget(body[8:])
+				if target == "" {
+					continue
+				}
+				row, lastRow := int(binary.LittleEndian.Uint16(body)), int(binary.LittleEndian.Uint16(body[2:]))
+				col, lastCol := int(binary.LittleEndian.Uint16(body[4:])), int(binary.LittleEndian.Uint16(body[6:]))
+				addNote(notes, row+1, col, lastRow+1, lastCol, func(meta *models.CellMeta) { meta.Hyperlink = target })
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, name)
+}
+
+func (wb *xlsWorkbook) uses1904Dates() bool { return wb.date1904 }
+func (wb *xlsWorkbook) Close() error        { return nil }
+
+// numberCell builds a numeric cell. BIFF keeps no display strings, so the
+// text of a cell is its typed value rendered as a string.
+func (wb *xlsWorkbook) numberCell(value float64, xf int) cell {
+	c := cell{raw: strconv.FormatFloat(value, 'f', -1, 64), kind: excelize.CellTypeNumber}
+	if xf >= 0 && xf < len(wb.formats) {
+		c.numFmt = wb.formats[xf]
+	}
+	c.text = fmt.Sprint(typedValue(c, wb.date1904))
+	return c
+}
+
+func (wb *xlsWorkbook) stringCell(value string) cell {
+	return cell{text: value, raw: value, kind: excelize.CellTypeSharedString}
+}
+
+func boolCell(value bool) cell {
+	text := "FALSE"
+	if value {
+		text = "TRUE"
+	}
+	return cell{text: text, raw: text, kind: excelize.CellTypeBool}
+}
+
+// biffErrors maps BIFF error codes to the text Excel displays.
+var biffErrors = map[byte]string{
+	0x00: "#NULL!", 0x07: "#DIV/0!", 0x0F: "#VALUE!", 0x17: "#REF!",
+	0x1D: "#NAME?", 0x24: "#NUM!", 0x2A: "#N/A",
+}
+
+func errorCell(code byte) cell {
+	text := biffErrors[code]
+	return cell{text: text, raw: text, kind: excelize.CellTypeError}
+}
+
+// xlsRows turns the cell records of a worksheet into rows. BIFF stores cells
+// in row-major order, so a row is complete once a cell of a later row, or
+// the end of the sheet, is reached.
+type xlsRows struct {
+	wb      *xlsWorkbook
+	records *biffReader
+
+	row      sheetRow
+	building sheetRow
+	started  bool
+	done     bool
+	err      error
+
+	// A formula returning a string keeps its value in the STRING record
+	// that follows it.
+	formulaRow, formulaCol int
+	awaitingString         bool
+
+	// hidden holds the rows whose ROW record, which precedes their cells,
+	// hides them.
+	hidden map[int]bool
+}
+
+func (r *xlsRows) Next() bool {
+	if r.done || r.err != nil {
+		return false
+	}
+
+	for {
+		typ, body, err := r.records.next()
+		if err == io.EOF || (err == nil && typ == biffEOF) {
+			r.done = true
+			return r.flush()
+		}
+		if err != nil {
+			r.err = err
+			return false
+		}
+
+		if r.awaitingString && typ == biffString {
+			r.awaitingString = false
+			value, _ := readUnicodeString(body)
+			if r.set(r.formulaRow, r.formulaCol, r.wb.stringCell(value)) {
+				return true
+			}
+			continue
+		}
+
+		if r.decode(typ, body) {
+			return true
+		}
+	}
+}
+
+// decode applies a cell record and reports whether it completed a row.
+func (r *xlsRows) decode(typ uint16, body []byte) bool {
+	if typ == biffRow {
+		if len(body) >= 16 && binary.LittleEndian.Uint32(body[12:])&0x0020 != 0 {
+			if r.hidden == nil {
+				r.hidden = make(map[int]bool)
+			}
+			r.hidden[int(binary.LittleEndian.Uint16(body))] = true
+		}
+		return false
+	}
+	if len(body) < 6 {
+		return false
+	}
+	row := int(binary.LittleEndian.Uint16(body))
+	col := int(binary.LittleEndian.Uint16(body[2:]))
+	xf := int(binary.LittleEndian.Uint16(body[4:]))
+
+	switch typ {
+	case biffLabelSST:
+		if len(body) < 10 {
+			return false
+		}
+		index := int(binary.LittleEndian.Uint32(body[6:]))
+		value := ""
+		if index < len(r.wb.sst) {
+			value = r.wb.sst[index]
+		}
+		return r.set(row, col, r.wb.stringCell(value))
+	case biffLabel:
+		value, _ := readUnicodeString(body[6:])
+		return r.set(row, col, r.wb.stringCell(value))
+	case biffNumber:
+		if len(body) < 14 {
+			return false
+		}
+		return r.set(row, col, r.wb.numberCell(math.Float64frombits(binary.LittleEndian.Uint64(body[6:])), xf))
+	case biffRK:
+		if len(body) < 10 {
+			return false
+		}
+		return r.set(row, col, r.wb.numberCell(rkValue(binary.LittleEndian.Uint32(body[6:])), xf))
+	case biffMulRK:
+		// colFirst, then (xf, rk) pairs, then colLast.
+		completed := false
+		for i, pos := 0, 4; pos+6 <= len(body)-2; i, pos = i+1, pos+6 {
+			xf := int(binary.LittleEndian.Uint16(body[pos:]))
+			value := rkValue(binary.LittleEndian.Uint32(body[pos+2:]))
+			if r.set(row, col+i, r.wb.numberCell(value, xf)) {
+				completed = true
+			}
+		}
+		return completed
+	case biffBoolErr:
+		if len(body) < 8 {
+			return false
+		}
+		if body[7] == 1 {
+			return r.set(row, col, errorCell(body[6]))
+		}
+		return r.set(row, col, boolCell(body[6] != 0))
+	case biffFormula:
+		if len(body) < 14 {
+			return false
+		}
+		result := body[6:14]
+		if result[6] != 0xFF || result[7] != 0xFF {
+			return r.set(row, col, r.wb.numberCell(math.Float64frombits(binary.LittleEndian.Uint64(result)), xf))
+		}
+		switch result[0] {
+		case 0:
+			r.formulaRow, r.formulaCol = row, col
+			r.awaitingString = true
+			return false
+		case 1:
+			return r.set(row, col, boolCell(result[2] != 0))
+		case 2:
+			return r.set(row, col, errorCell(result[2]))
+		}
+	}
+	return false
+}
+
+// set places a cell and reports whether doing so completed the previous
+// row, which is then available from Row.
+func (r *xlsRows) set(row, col int, c cell) bool {
+	if c.isEmpty() || col >= excelize.MaxColumns {
+		return false
+	}
+
+	completed := false
+	if r.started && row+1 != r.building.number {
+		r.row = r.building
+		r.building = sheetRow{}
+		completed = true
+	}
+	if !r.started || completed {
+		r.building.number = row + 1
+		r.building.hidden = r.hidden[row]
+		delete(r.hidden, row)
+		r.started = true
+	}
+
+	for len(r.building.cells) < col {
+		r.building.cells = append(r.building.cells, cell{})
+	}
+	if col < len(r.building.cells) {
+		r.building.cells[col] = c
+	} else {
+		r.building.cells = append(r.building.cells, c)
+	}
+	return completed
+}
+
+// flush hands out the last row of the sheet.
+func (r *xlsRows) flush() bool {
+	if !r.started || r.building.number == 0 {
+		return false
+	}
+	r.row = r.building
+	r.building = sheetRow{}
+	return true
+}
+
+func (r *xlsRows) Row() sheetRow { return r.row }
+func (r *xlsRows) Err() error    { return r.err }
+func (r *xlsRows) Close() error  { return nil }
+
+// rkValue decodes an RK number: a 30-bit integer or the high bits of a
+// double, optionally divided by 100.
+func rkValue(rk uint32) float64 {
+	var value float64
+	if rk&0x02 != 0 {
+		value = float64(int32(rk) >> 2)
+	} else {
+		value = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		value /= 100
+	}
+	return value
+}
+
+// biffReader reads the records of a BIFF stream.
+type biffReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (b *biffReader) next() (uint16, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(b.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, nil, err
+	}
+
+	typ := binary.LittleEndian.Uint16(header[:])
+	size := int(binary.LittleEndian.Uint16(header[2:]))
+	if cap(b.buf) < size {
+		b.buf = make([]byte, size)
+	}
+	body := b.buf[:size]
+	if _, err := io.ReadFull(b.r, body); err != nil {
+		return 0, nil, fmt.Errorf("truncated record %#04x: %w", typ, err)
+	}
+	return typ, body, nil
+}
+
+// readShortString decodes a ShortXLUnicodeString: an 8-bit length, a flags
+// byte and the characters.
+func readShortString(b []byte) (string, int) {
+	if len(b) < 2 {
+		return "", len(b)
+	}
+	s, n := decodeChars(b[2:], int(b[0]), b[1]&0x01 != 0)
+	return s, 2 + n
+}
+
+// readUnicodeString decodes an XLUnicodeString: a 16-bit length, a flags
+// byte and the characters.
+func readUnicodeString(b []byte) (string, int) {
+	if len(b) < 3 {
+		return "", len(b)
+	}
+	s, n := decodeChars(b[3:], int(binary.LittleEndian.Uint16(b)), b[2]&0x01 != 0)
+	return s, 3 + n
+}
+
+// decodeChars decodes count characters stored either as UTF-16 or, when not
+// wide, as single bytes holding the low byte of each code unit.
+func decodeChars(b []byte, count int, wide bool) (string, int) {
+	if wide {
+		if count*2 > len(b) {
+			count = len(b) / 2
+		}
+		units := make([]uint16, count)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+		return string(utf16.Decode(units)), count * 2
+	}
+
+	if count > len(b) {
+		count = len(b)
+	}
+	runes := make([]rune, count)
+	for i := range runes {
+		runes[i] = rune(b[i])
+	}
+	return string(runes), count
+}
+
+// Flags of a hyperlink object, telling which of its parts are present.
+const (
+	hlinkHasMoniker     = 0x0001
+	hlinkHasLocation    = 0x0008
+	hlinkHasDisplayName = 0x0010
+	hlinkHasFrameName   = 0x0080
+	hlinkMonikerString  = 0x0100
+)
+
+// CLSIDs of the monikers a hyperlink target is read from, in their
+// serialized byte order.
+var (
+	urlMonikerID  = []byte{0xE0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B}
+	fileMonikerID = []byte{0x03, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+)
+
+// hlinkTarget decodes the target of a hyperlink object, which follows the
+// cell range of an HLINK record: a CLSID, a version, flags and the parts the
+// flags announce. A location inside the workbook is appended after a "#",
+// as in .xlsx files. Targets of other moniker types are left out.
+func hlinkTarget(b []byte) string {
+	h := &hlinkReader{b: b}
+	h.take(20) // hlinkClsid, streamVersion
+	flags := h.u32()
+	if flags&hlinkHasDisplayName != 0 {
+		h.string()
+	}
+	if flags&hlinkHasFrameName != 0 {
+		h.string()
+	}
+
+	var target string
+	switch {
+	case flags&hlinkHasMoniker == 0:
+	case flags&hlinkMonikerString != 0:
+		target = h.string()
+	default:
+		switch id := h.take(16); {
+		case bytes.Equal(id, urlMonikerID):
+			target = h.utf16(uint64(h.u32()))
+		case bytes.Equal(id, fileMonikerID):
+			up := strings.Repeat("../", int(h.u16()))
+			ansi := h.take(uint64(h.u32()))
+			h.take(24) // endServer, versionNumber, reserved
+			if h.u32() > 0 {
+				size := uint64(h.u32())
+				h.take(2) // usKeyValue
+				target = up + h.utf16(size)
+			} else if i := bytes.IndexByte(ansi, 0); i >= 0 {
+				target = up + string(ansi[:i])
+			} else {
+				target = up + string(ansi)
+			}
+		default:
+			return ""
+		}
+	}
+	if flags&hlinkHasLocation != 0 {
+		target += "#" + h.string()
+	}
+	if h.bad {
+		return ""
+	}
+	return target
+}
+
+// hlinkReader reads the parts of a hyperlink object, whose lengths come from
+// the file and are checked against what is left of the record.
+type hlinkReader struct {
+	b   []byte
+	bad bool
+}
+
+func (h *hlinkReader) take(n uint64) []byte {
+	if h.bad || n > uint64(len(h.b)) {
+		h.bad = true
+		return nil
+	}
+	part := h.b[:n]
+	h.b = h.b[n:]
+	return part
+}
+
+func (h *hlinkReader) u16() uint16 {
+	if b := h.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (h *hlinkReader) u32() uint32 {
+	if b := h.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// utf16 reads size bytes of UTF-16 text, which ends at the first NUL.
+func (h *hlinkReader) utf16(size uint64) string {
+	b := h.take(size)
+	s, _ := decodeChars(b, len(b)/2, true)
+	s, _, _ = strings.Cut(s, "\x00")
+	return s
+}
+
+// string reads a HyperlinkString: a count of UTF-16 characters, including
+// the terminating NUL, and the characters.
+func (h *hlinkReader) string() string {
+	return h.utf16(uint64(h.u32()) * 2)
+}
+
+// decodeSST decodes the shared string table from the SST record and its
+// CONTINUE records. A string whose characters run over a record boundary
+// restarts with a fresh flags byte telling whether the rest is wide.
+func decodeSST(segments [][]byte) []string {
+	s := &sstReader{segments: segments}
+	s.skip(4) // cstTotal
+	unique := int(s.u32())
+
+	// The count comes from the file; every string takes at least three
+	// bytes, which bounds how many the segments can really hold.
+	size := 0
+	for _, segment := range segments {
+		size += len(segment)
+	}
+	table := make([]string, 0, min(unique, size/3))
+	for i := 0; i < unique && !s.exhausted(); i++ {
+		count := int(s.u16())
+		flags := s.byte()
+
+		runs, extSize := 0, 0
+		if flags&0x08 != 0 {
+			runs = int(s.u16())
+		}
+		if flags&0x04 != 0 {
+			extSize = int(s.u32())
+		}
+
+		table = append(table, s.chars(count, flags&0x01 != 0))
+		s.skip(runs*4 + extSize)
+	}
+	return table
+}
+
+type sstReader struct {
+	segments [][]byte
+	seg, pos int
+}
+
+func (s *sstReader) exhausted() bool {
+	for s.seg < len(s.segments) && s.pos >= len(s.segments[s.seg]) {
+		s.seg++
+		s.pos = 0
+	}
+	return s.seg >= len(s.segments)
+}
+
+func (s *sstReader) byte() byte {
+	if s.exhausted() {
+		return 0
+	}
+	b := s.segments[s.seg][s.pos]
+	s.pos++
+	return b
+}
+
+func (s *sstReader) u16() uint16 {
+	return uint16(s.byte()) | uint16(s.byte())<<8
+}
+
+func (s *sstReader) u32() uint32 {
+	return uint32(s.u16()) | uint32(s.u16())<<16
+}
+
+func (s *sstReader) skip(n int) {
+	for ; n > 0 && !s.exhausted(); n-- {
+		s.pos++
+	}
+}
+
+func (s *sstReader) chars(count int, wide bool) string {
+	units := make([]uint16, 0, count)
+	for len(units) < count && !s.exhausted() {
+		if s.pos == 0 && s.seg > 0 && len(units) > 0 {
+			wide = s.byte()&0x01 != 0
+			continue
+		}
+		if wide {
+			units = append(units, s.u16())
+		} else {
+			units = append(units, uint16(s.byte()))
+		}
+	}
+	return string(utf16.Decode(units))
+}
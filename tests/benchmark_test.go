@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/xuri/excelize/v2"
+)
+
+const benchmarkRows = 50000
+
+// largeWorkbook writes a statement-like workbook into the benchmark's
+// temporary directory, which is removed when the benchmark finishes.
+func largeWorkbook(b *testing.B) string {
+	b.Helper()
+
+	path := filepath.Join(b.TempDir(), "large.xlsx")
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sw, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		b.Fatalf("Failed to build benchmark workbook: %v", err)
+	}
+
+	header := []interface{}{"Date", "Description", "Reference", "Debit", "Credit", "Balance"}
+	if err := sw.SetRow("A1", header); err != nil {
+		b.Fatalf("Failed to build benchmark workbook: %v", err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < benchmarkRows; i++ {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		row := []interface{}{
+			start.AddDate(0, 0, i%365).Format("2006-01-02"),
+			fmt.Sprintf("Card payment %d", i),
+			fmt.Sprintf("REF%08d", i),
+			float64(i%500) + 0.25,
+			nil,
+			float64(100000 - i),
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			b.Fatalf("Failed to build benchmark workbook: %v", err)
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		b.Fatalf("Failed to build benchmark workbook: %v", err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		b.Fatalf("Failed to build benchmark workbook: %v", err)
+	}
+	return path
+}
+
+// peakHeap runs fn while sampling the heap and returns the highest in-use
+// heap observed above the starting point.
+func peakHeap(fn func()) uint64 {
+	runtime.GC()
+	var base runtime.MemStats
+	runtime.ReadMemStats(&base)
+
+	var peak uint64
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			if m.HeapInuse > peak {
+				peak = m.HeapInuse
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	fn()
+	close(done)
+	wg.Wait()
+
+	if peak < base.HeapInuse {
+		return 0
+	}
+	return peak - base.HeapInuse
+}
+
+func BenchmarkParser_LargeWorkbook(b *testing.B) {
+	path := largeWorkbook(b)
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatalf("Failed to stat workbook: %v", err)
+	}
+
+	// The sink modes hand records on in batches, as uploads do, and show
+	// the memory the parser itself needs.
+	modes := []struct {
+		name   string
+		values xlsx.ValueMode
+		sink   bool
+	}{
+		{name: "typed", values: xlsx.ValuesTyped},
+		{name: "string", values: xlsx.ValuesString},
+		{name: "typed-sink", values: xlsx.ValuesTyped, sink: true},
+		{name: "string-sink", values: xlsx.ValuesString, sink: true},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			parser := xlsx.NewParser(10)
+			var peak uint64
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				file, err := os.Open(path)
+				if err != nil {
+					b.Fatalf("Failed to open workbook: %v", err)
+				}
+
+				opts := xlsx.Options{Values: mode.values}
+				sunk := 0
+				if mode.sink {
+					opts.RecordSink = func(records []models.Record) error {
+						sunk += len(records)
+						return nil
+					}
+				}
+
+				var result *xlsx.ParseResult
+				used := peakHeap(func() {
+					result, err = parser.ParseWithOptions(context.Background(), file, "bench", opts)
+				})
+				file.Close()
+				if err != nil {
+					b.Fatalf("ParseWithOptions() error = %v", err)
+				}
+				if result.RowsAccepted != benchmarkRows {
+					b.Fatalf("RowsAccepted = %d, want %d", result.RowsAccepted, benchmarkRows)
+				}
+				if mode.sink && sunk != benchmarkRows {
+					b.Fatalf("RecordSink got %d records, want %d", sunk, benchmarkRows)
+				}
+				if used > peak {
+					peak = used
+				}
+			}
+
+			b.ReportMetric(float64(info.Size())/(1<<20), "file-MB")
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+			b.ReportMetric(float64(peak)/benchmarkRows, "peak-heap-B/row")
+		})
+	}
+}
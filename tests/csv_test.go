@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func utf16LE(t *testing.T, s string) []byte {
+	t.Helper()
+
+	encoded, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("Failed to encode UTF-16: %v", err)
+	}
+	return encoded
+}
+
+func TestParser_ParseWithOptions_CSV(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		content  []byte
+		format   xlsx.Format
+		wantRows []map[string]interface{}
+	}{
+		{
+			name:    "comma with quoted fields",
+			content: []byte("Name,Memo,Amount\r\n\"Doe, John\",\"line one\nline two\",10\r\nJane,\"say \"\"hi\"\"\",20\r\n"),
+			format:  xlsx.FormatCSV,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "Doe, John", "Memo": "line one\nline two", "Amount": int64(10)},
+				{"Transaction Index": 2, "Name": "Jane", "Memo": `say "hi"`, "Amount": int64(20)},
+			},
+		},
+		{
+			name:    "semicolon with UTF-8 BOM",
+			content: append([]byte{0xEF, 0xBB, 0xBF}, []byte("Datum;Betrag\n02.01.2024;1,50\n")...),
+			format:  xlsx.FormatCSV,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Datum": "02.01.2024", "Betrag": "1,50"},
+			},
+		},
+		{
+			name:    "tab separated",
+			content: []byte("Name\tCity, State\nJohn\tAustin, TX\n"),
+			format:  xlsx.FormatTSV,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "John", "City, State": "Austin, TX"},
+			},
+		},
+		{
+			name:    "UTF-16 with BOM",
+			content: utf16LE(t, "Name\tCafé\nJohn\tLatte\n"),
+			format:  xlsx.FormatCSV,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "John", "Café": "Latte"},
+			},
+		},
+		{
+			name:    "Windows-1252",
+			content: []byte("Name,Place\nJos\xe9,Z\xfcrich\n"),
+			format:  xlsx.FormatCSV,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "José", "Place": "Zürich"},
+			},
+		},
+		{
+			name:    "typed values",
+			content: []byte("Account,Amount,Rate,Cleared,Booked,Posted\n00123,-1.5,2.5e-3,TRUE,2024-01-02,2024-01-02 10:30:00\n+7,1 000,12%,false,02/01/2024,2024-01-02T25:00:00\n"),
+			format:  xlsx.FormatCSV,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Account": "00123", "Amount": -1.5, "Rate": 0.0025, "Cleared": true, "Booked": "2024-01-02", "Posted": "2024-01-02T10:30:00"},
+				{"Transaction Index": 2, "Account": int64(7), "Amount": "1 000", "Rate": "12%", "Cleared": false, "Booked": "02/01/2024", "Posted": "2024-01-02T25:00:00"},
+			},
+		},
+		{
+			name:    "preamble and detected format",
+			content: []byte("Account statement\nAccount;DE12 3456\n\nDate;Description;Amount\n2024-01-02;Coffee;-3\n2024-01-03;Salary;2000\n"),
+			format:  xlsx.FormatAuto,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Description": "Coffee", "Amount": int64(-3)},
+				{"Transaction Index": 2, "Date": "2024-01-03", "Description": "Salary", "Amount": int64(2000)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", xlsx.Options{Format: tt.format})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d: %+v", len(result.Records), len(tt.wantRows), result.Records)
+			}
+			for i, want := range tt.wantRows {
+				if got := result.Records[i].Data; !reflect.DeepEqual(got, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_CSVBinary(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), bytes.Repeat([]byte{0x00, 0x10, 0xff, 0x08}, 256)...)
+
+	for _, format := range []xlsx.Format{xlsx.FormatCSV, xlsx.FormatTSV, xlsx.FormatAuto} {
+		_, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), bytes.NewReader(png), "upload-1", xlsx.Options{Format: format})
+		if !errors.Is(err, xlsx.ErrUnsupportedFormat) {
+			t.Errorf("ParseWithOptions(%v) error = %v, want ErrUnsupportedFormat", format, err)
+		}
+	}
+}
+
+func TestParser_ParseWithOptions_DetectsXLSX(t *testing.T) {
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{{"Name"}, {"John"}}})
+
+	result, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), workbook, "upload-1", xlsx.Options{Format: xlsx.FormatAuto})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+	}
+	if len(result.Records) != 1 || result.Records[0].Data["Name"] != "John" {
+		t.Errorf("Records = %+v, want one record for John", result.Records)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     xlsx.Format
+		wantOK   bool
+	}{
+		{filename: "statement.xlsx", want: xlsx.FormatXLSX, wantOK: true},
+		{filename: "statement.CSV", want: xlsx.FormatCSV, wantOK: true},
+		{filename: "statement.txt", wantOK: false},
+		{filename: "statement.tsv", want: xlsx.FormatTSV, wantOK: true},
+		{filename: "statement.xls", want: xlsx.FormatXLS, wantOK: true},
+		{filename: "statement.pdf", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := xlsx.FormatFromFilename(tt.filename)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("FormatFromFilename(%q) = %v, %v, want %v, %v", tt.filename, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/rs/zerolog"
+	"github.com/xuri/excelize/v2"
+)
+
+func buildEncryptedWorkbook(t *testing.T, password string) []byte {
+	t.Helper()
+
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{{"Name", "Age"}, {"John", 30}}})
+	encrypted, err := excelize.Encrypt(workbook.Bytes(), &excelize.Options{Password: password})
+	if err != nil {
+		t.Fatalf("Failed to encrypt workbook: %v", err)
+	}
+	return encrypted
+}
+
+// buildEncryptedODS writes an OpenDocument package whose manifest declares
+// an encrypted content part.
+func buildEncryptedODS(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"mimetype", "application/vnd.oasis.opendocument.spreadsheet"},
+		{"META-INF/manifest.xml", `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0">
+  <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml">
+    <manifest:encryption-data manifest:checksum-type="SHA1/1K" manifest:checksum="AAAA"/>
+  </manifest:file-entry>
+</manifest:manifest>`},
+		{"content.xml", "\x8f\x01encrypted"},
+	}
+	for _, p := range parts {
+		w, err := zw.Create(p.name)
+		if err != nil {
+			t.Fatalf("Failed to create part %s: %v", p.name, err)
+		}
+		w.Write([]byte(p.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write ods file: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParser_ParseWithOptions_Encrypted(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+	encrypted := buildEncryptedWorkbook(t, "s3cret")
+
+	tests := []struct {
+		name    string
+		content []byte
+		opts    xlsx.Options
+		wantErr error
+	}{
+		{name: "correct password", content: encrypted, opts: xlsx.Options{Password: "s3cret"}},
+		{name: "detected format", content: encrypted, opts: xlsx.Options{Format: xlsx.FormatAuto, Password: "s3cret"}},
+		{name: "no password", content: encrypted, wantErr: xlsx.ErrEncrypted},
+		{name: "wrong password", content: encrypted, opts: xlsx.Options{Password: "guess"}, wantErr: xlsx.ErrWrongPassword},
+		{name: "encrypted xls", content: buildXLSWorkbook(t, true), opts: xlsx.Options{Format: xlsx.FormatXLS, Password: "s3cret"}, wantErr: xlsx.ErrEncrypted},
+		{name: "encrypted ods", content: buildEncryptedODS(t), opts: xlsx.Options{Format: xlsx.FormatODS}, wantErr: xlsx.ErrEncrypted},
+		{name: "password on plain file", content: buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{{"Name"}, {"John"}}}).Bytes(), opts: xlsx.Options{Password: "unused"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseWithOptions() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Records) != 1 || result.Records[0].Data["Name"] != "John" {
+				t.Errorf("Records = %+v, want one record for John", result.Records)
+			}
+		})
+	}
+}
+
+func TestUploadHandler_Encrypted(t *testing.T) {
+	logger := zerolog.Nop()
+	uploadHandler := handlers.NewUploadHandler(storage.NewMemoryStorage(), xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	encrypted := buildEncryptedWorkbook(t, "s3cret")
+
+	tests := []struct {
+		name           string
+		fields         map[string]string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "correct password", fields: map[string]string{"password": "s3cret"}, expectedStatus: http.StatusOK},
+		{name: "no password", expectedStatus: http.StatusBadRequest, expectedCode: "encrypted_file"},
+		{name: "wrong password", fields: map[string]string{"password": "guess"}, expectedStatus: http.StatusBadRequest, expectedCode: "wrong_password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			uploadHandler.Handle(w, newUploadRequest(t, "data.xlsx", encrypted, tt.fields))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedCode == "" {
+				return
+			}
+
+			var response models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != tt.expectedCode {
+				t.Errorf("Error code = %q, want %q", response.Code, tt.expectedCode)
+			}
+		})
+	}
+}
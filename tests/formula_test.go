@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/rs/zerolog"
+	"github.com/xuri/excelize/v2"
+)
+
+// buildFormulaWorkbook writes a sheet of formulas: a stale cached text
+// result in C2, cells saved without calculation, a formula error and a
+// shared formula in the Check column.
+func buildFormulaWorkbook(t *testing.T) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	rows := [][]interface{}{
+		{"Item", "Amount", "Total", "Check"},
+		{"Coffee", 10, 30, nil},
+		{"Tea", 4, nil, nil},
+		{"Cake", 6, nil, nil},
+	}
+	for r, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, r+1)
+		row := row
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+
+	formulas := []struct {
+		cell, formula string
+		opts          []excelize.FormulaOpts
+	}{
+		{cell: "C2", formula: "B2*2"},
+		{cell: "C3", formula: "B3*2"},
+		{cell: "D2", formula: "B2>5", opts: []excelize.FormulaOpts{{Type: stringPtr(excelize.STCellFormulaTypeShared), Ref: stringPtr("D2:D4")}}},
+		{cell: "C4", formula: "1/0"},
+	}
+	for _, fm := range formulas {
+		if err := f.SetCellFormula("Sheet1", fm.cell, fm.formula, fm.opts...); err != nil {
+			t.Fatalf("Failed to set formula: %v", err)
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func stringPtr(s string) *string { return &s }
+
+func TestParser_ParseWithOptions_Formulas(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+	content := buildFormulaWorkbook(t)
+
+	tests := []struct {
+		name           string
+		opts           xlsx.Options
+		wantRows       []map[string]interface{}
+		wantMismatches []models.FormulaMismatch
+	}{
+		{
+			name: "cached",
+			opts: xlsx.Options{},
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Item": "Coffee", "Amount": int64(10), "Total": "30", "Check": nil},
+				{"Transaction Index": 2, "Item": "Tea", "Amount": int64(4), "Total": nil, "Check": nil},
+				{"Transaction Index": 3, "Item": "Cake", "Amount": int64(6), "Total": nil, "Check": nil},
+			},
+		},
+		{
+			name: "formula text",
+			opts: xlsx.Options{Formulas: xlsx.FormulasText},
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Item": "Coffee", "Amount": int64(10), "Total": "=B2*2", "Check": "=B2>5"},
+				{"Transaction Index": 2, "Item": "Tea", "Amount": int64(4), "Total": "=B3*2", "Check": "=B3>5"},
+				{"Transaction Index": 3, "Item": "Cake", "Amount": int64(6), "Total": "=1/0", "Check": "=B4>5"},
+			},
+		},
+		{
+			name: "recalculated",
+			opts: xlsx.Options{Formulas: xlsx.FormulasRecalc},
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Item": "Coffee", "Amount": int64(10), "Total": int64(20), "Check": true},
+				{"Transaction Index": 2, "Item": "Tea", "Amount": int64(4), "Total": int64(8), "Check": false},
+				{"Transaction Index": 3, "Item": "Cake", "Amount": int64(6), "Total": "#DIV/0!", "Check": true},
+			},
+			wantMismatches: []models.FormulaMismatch{
+				{Cell: "C2", Formula: "=B2*2", Cached: "30", Computed: "20"},
+			},
+		},
+		{
+			name: "recalculated display strings",
+			opts: xlsx.Options{Formulas: xlsx.FormulasRecalc, Values: xlsx.ValuesString},
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Item": "Coffee", "Amount": "10", "Total": "20", "Check": "TRUE"},
+				{"Transaction Index": 2, "Item": "Tea", "Amount": "4", "Total": "8", "Check": "FALSE"},
+				{"Transaction Index": 3, "Item": "Cake", "Amount": "6", "Total": "#DIV/0!", "Check": "TRUE"},
+			},
+			wantMismatches: []models.FormulaMismatch{
+				{Cell: "C2", Formula: "=B2*2", Cached: "30", Computed: "20"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(content), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantRows))
+			}
+			for i, want := range tt.wantRows {
+				if got := result.Records[i].Data; !reflect.DeepEqual(got, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, got, want)
+				}
+			}
+			if got := result.Sheets[0].FormulaMismatches; !reflect.DeepEqual(got, tt.wantMismatches) {
+				t.Errorf("FormulaMismatches = %+v, want %+v", got, tt.wantMismatches)
+			}
+			if got := result.Sheets[0].FormulaMismatchCount; got != len(tt.wantMismatches) {
+				t.Errorf("FormulaMismatchCount = %d, want %d", got, len(tt.wantMismatches))
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_FormulaMismatchLimit(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const stale = 150
+	mustNoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Item", "Amount", "Total"}))
+	for r := 2; r <= stale+1; r++ {
+		cell, _ := excelize.CoordinatesToCellName(1, r)
+		mustNoError(t, f.SetSheetRow("Sheet1", cell, &[]interface{}{"Coffee", 1, 99}))
+		total, _ := excelize.CoordinatesToCellName(3, r)
+		mustNoError(t, f.SetCellFormula("Sheet1", total, "B"+total[1:]+"*2"))
+	}
+	buf, err := f.WriteToBuffer()
+	mustNoError(t, err)
+
+	result, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), bytes.NewReader(buf.Bytes()), "upload-1", xlsx.Options{Formulas: xlsx.FormulasRecalc})
+	mustNoError(t, err)
+	sheet := result.Sheets[0]
+	if len(sheet.FormulaMismatches) != 100 || sheet.FormulaMismatchCount != stale {
+		t.Errorf("Got %d mismatches listed and %d counted, want 100 and %d", len(sheet.FormulaMismatches), sheet.FormulaMismatchCount, stale)
+	}
+	if first := sheet.FormulaMismatches[0]; first.Cell != "C2" || first.Formula != "=B2*2" {
+		t.Errorf("First mismatch = %+v, want C2 =B2*2", first)
+	}
+}
+
+func TestParser_ParseWithOptions_FormulasUnsupportedFormat(t *testing.T) {
+	_, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), bytes.NewReader([]byte("Name\nJohn\n")), "upload-1", xlsx.Options{
+		Format:   xlsx.FormatCSV,
+		Formulas: xlsx.FormulasRecalc,
+	})
+	if !errors.Is(err, xlsx.ErrUnsupportedFormat) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestUploadHandler_Formulas(t *testing.T) {
+	logger := zerolog.Nop()
+	uploadHandler := handlers.NewUploadHandler(storage.NewMemoryStorage(), xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	workbook := buildFormulaWorkbook(t)
+
+	tests := []struct {
+		name           string
+		filename       string
+		content        []byte
+		fields         map[string]string
+		expectedStatus int
+	}{
+		{name: "recalc xlsx", filename: "data.xlsx", content: workbook, fields: map[string]string{"formulas": "recalc"}, expectedStatus: http.StatusOK},
+		{name: "unknown mode", filename: "data.xlsx", content: workbook, fields: map[string]string{"formulas": "evaluate"}, expectedStatus: http.StatusBadRequest},
+		{name: "formulas on csv", filename: "data.csv", content: []byte("Name\nJohn\n"), fields: map[string]string{"formulas": "formula"}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			uploadHandler.Handle(w, newUploadRequest(t, tt.filename, tt.content, tt.fields))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				return
+			}
+
+			var response models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != "invalid_parameter" {
+				t.Errorf("Error code = %q, want invalid_parameter", response.Code)
+			}
+		})
+	}
+}
+
+const odsNonFinite = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+    xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+    xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+    xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+  <office:body>
+    <office:spreadsheet>
+      <table:table table:name="Sheet1">
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Cached</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Computed</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="float" office:value="NaN"><text:p>NaN</text:p></table:table-cell>
+          <table:table-cell office:value-type="float" office:value="-Infinity"><text:p>-Infinity</text:p></table:table-cell>
+        </table:table-row>
+      </table:table>
+    </office:spreadsheet>
+  </office:body>
+</office:document-content>`
+
+func TestParser_ParseWithOptions_NonFiniteNumbers(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	// A cell written from a NaN float, and a formula that
+	// computes text which strconv would take for infinity.
+	f := excelize.NewFile()
+	defer f.Close()
+	for cell, value := range map[string]interface{}{"A1": "Cached", "B1": "Computed", "A2": math.NaN()} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("Failed to set cell: %v", err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "B2", `"Infinity"`); err != nil {
+		t.Fatalf("Failed to set formula: %v", err)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		opts    xlsx.Options
+		want    map[string]interface{}
+	}{
+		{
+			name:    "ods floats",
+			content: buildODS(t, odsNonFinite),
+			opts:    xlsx.Options{Format: xlsx.FormatODS},
+			want:    map[string]interface{}{"Transaction Index": 1, "Cached": "NaN", "Computed": "-Infinity"},
+		},
+		{
+			name:    "recalculated",
+			content: buf.Bytes(),
+			opts:    xlsx.Options{Formulas: xlsx.FormulasRecalc},
+			want:    map[string]interface{}{"Transaction Index": 1, "Cached": "NaN", "Computed": "Infinity"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Records) != 1 {
+				t.Fatalf("Got %d records, want 1", len(result.Records))
+			}
+			if got := result.Records[0].Data; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Record data = %#v, want %#v", got, tt.want)
+			}
+			if _, err := json.Marshal(result.Records); err != nil {
+				t.Errorf("json.Marshal(records) error = %v", err)
+			}
+		})
+	}
+}
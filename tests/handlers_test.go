@@ -1,14 +1,23 @@
 package tests
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
 	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
 	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
 	"github.com/rs/zerolog"
 )
 
@@ -44,7 +53,7 @@ func TestListHandler_Handle(t *testing.T) {
 		{ID: "2", UploadID: "upload-1", Data: map[string]interface{}{"name": "Jane"}},
 		{ID: "3", UploadID: "upload-1", Data: map[string]interface{}{"name": "Bob"}},
 	}
-	store.Store(testRecords)
+	mustNoError(t, storage.CommitUpload(context.Background(), store, storage.UploadBatch{Upload: models.Upload{ID: "upload-1"}, Records: testRecords}))
 
 	handler := handlers.NewListHandler(store, &logger)
 
@@ -116,3 +125,328 @@ func TestListHandler_Handle(t *testing.T) {
 		})
 	}
 }
+
+// newUploadRequest builds a multipart upload of the given workbook bytes with
+// optional extra form fields.
+func newUploadRequest(t *testing.T, filename string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("Failed to write field: %v", err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/uploads", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// withURLParam attaches a chi route parameter to a request.
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestUploadHandler_Rejections(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	rejectionsHandler := handlers.NewRejectionsHandler(store, &logger)
+
+	rows := [][]interface{}{{"Name", "Amount"}}
+	for i := 0; i < 15; i++ {
+		rows = append(rows, []interface{}{fmt.Sprintf("Item %d", i), i}, []interface{}{})
+	}
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: rows})
+
+	w := httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "data.xlsx", workbook.Bytes(), nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	var upload models.UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The last blank row trails the sheet and is not part of it.
+	if upload.RowsRejected != 14 {
+		t.Errorf("RowsRejected = %d, want 14", upload.RowsRejected)
+	}
+	if len(upload.Errors) != 10 {
+		t.Fatalf("Got %d errors in upload response, want the first 10", len(upload.Errors))
+	}
+	if first := upload.Errors[0]; first.Code != xlsx.CodeEmptyRow || first.Row != 3 || first.Sheet != "Sheet1" {
+		t.Errorf("First error = %+v, want empty_row on Sheet1 row 3", first)
+	}
+
+	tests := []struct {
+		name           string
+		uploadID       string
+		query          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "all errors", uploadID: upload.UploadID, expectedStatus: http.StatusOK, query: "?limit=100", expectedCount: 14},
+		{name: "paginated", uploadID: upload.UploadID, expectedStatus: http.StatusOK, query: "?limit=5&offset=10", expectedCount: 4},
+		{name: "unknown upload", uploadID: "missing", expectedStatus: http.StatusNotFound},
+		{name: "invalid limit", uploadID: upload.UploadID, query: "?limit=x", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/uploads/"+tt.uploadID+"/errors"+tt.query, nil)
+			w := httptest.NewRecorder()
+			rejectionsHandler.Handle(w, withURLParam(req, "id", tt.uploadID))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.ListRowErrorsResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Errors) != tt.expectedCount {
+				t.Errorf("Got %d errors, want %d", len(response.Errors), tt.expectedCount)
+			}
+			if response.Total != 14 {
+				t.Errorf("Total = %d, want 14", response.Total)
+			}
+		})
+	}
+}
+
+func TestSchemaHandler_Upload(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	registry := schema.NewRegistry()
+	schemaHandler := handlers.NewSchemaHandler(registry, &logger)
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), registry, mapping.NewRegistry(), 10, &logger)
+
+	definition := `{"name":"bank","columns":[{"name":"Ref","required":true,"unique":true},{"name":"Amount","type":"number"}]}`
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "register", body: definition, expectedStatus: http.StatusCreated},
+		{name: "duplicate", body: definition, expectedStatus: http.StatusConflict},
+		{name: "invalid", body: `{"name":"bad","columns":[{"name":"Ref","type":"money"}]}`, expectedStatus: http.StatusBadRequest},
+		{name: "unknown field", body: `{"name":"bad","cols":[]}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			schemaHandler.Create(w, httptest.NewRequest(http.MethodPost, "/v1/schemas", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	schemaHandler.Get(w, withURLParam(httptest.NewRequest(http.MethodGet, "/v1/schemas/bank", nil), "name", "bank"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Get status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	schemaHandler.List(w, httptest.NewRequest(http.MethodGet, "/v1/schemas", nil))
+	var list handlers.ListSchemasResponse
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Schemas) != 1 || list.Schemas[0].Name != "bank" {
+		t.Errorf("List = %+v, want the bank schema", list.Schemas)
+	}
+
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{
+		{"Ref", "Amount"},
+		{"A", 1},
+		{"A", 2},
+		{"B", "n/a"},
+	}})
+
+	w = httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "data.xlsx", workbook.Bytes(), map[string]string{"schema": "bank"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+	}
+	var upload models.UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if upload.Schema != "bank" || upload.RowsAccepted != 1 || upload.RowsRejected != 2 {
+		t.Errorf("Upload = %+v, want bank schema with 1 accepted and 2 rejected", upload)
+	}
+
+	w = httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "data.xlsx", workbook.Bytes(), map[string]string{"schema": "missing"}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Upload with unknown schema status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestProfileHandler_Upload(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	registry := mapping.NewRegistry()
+	profileHandler := handlers.NewProfileHandler(registry, &logger)
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), registry, 10, &logger)
+
+	definition := `{"name":"banks","foldCase":true,"snakeCase":true,"fields":[{"name":"date","aliases":["Txn Date"]}]}`
+	w := httptest.NewRecorder()
+	profileHandler.Create(w, httptest.NewRequest(http.MethodPost, "/v1/profiles", bytes.NewBufferString(definition)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	profileHandler.Get(w, withURLParam(httptest.NewRequest(http.MethodGet, "/v1/profiles/missing", nil), "name", "missing"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Get unknown profile status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{
+		{"TXN DATE", "Memo Text"},
+		{"2024-01-02", "coffee"},
+	}})
+
+	w = httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "data.xlsx", workbook.Bytes(), map[string]string{"profile": "banks"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	records, err := store.GetByUploadID(decodeUploadID(t, w))
+	if err != nil {
+		t.Fatalf("GetByUploadID() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("GetByUploadID() = %d records, want 1", len(records))
+	}
+	if records[0].Data["date"] != "2024-01-02" || records[0].Data["memo_text"] != "coffee" {
+		t.Errorf("Record data = %v, want date and memo_text keys", records[0].Data)
+	}
+}
+
+func decodeUploadID(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var upload models.UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return upload.UploadID
+}
+
+func TestUploadHandler_FileTypes(t *testing.T) {
+	logger := zerolog.Nop()
+	uploadHandler := handlers.NewUploadHandler(storage.NewMemoryStorage(), xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{{"Name"}, {"John"}}})
+
+	tests := []struct {
+		name           string
+		filename       string
+		content        []byte
+		expectedStatus int
+	}{
+		{name: "xlsx", filename: "data.xlsx", content: workbook.Bytes(), expectedStatus: http.StatusOK},
+		{name: "xls", filename: "data.xls", content: buildXLSWorkbook(t, false), expectedStatus: http.StatusOK},
+		{name: "ods", filename: "data.ods", content: buildODS(t, odsContent), expectedStatus: http.StatusOK},
+		{name: "csv", filename: "data.csv", content: []byte("Name,Age\nJohn,30\n"), expectedStatus: http.StatusOK},
+		{name: "tsv", filename: "data.tsv", content: []byte("Name\tAge\nJohn\t30\n"), expectedStatus: http.StatusOK},
+		{name: "unsupported extension", filename: "data.pdf", content: []byte("%PDF"), expectedStatus: http.StatusBadRequest},
+		{name: "binary content", filename: "data.csv", content: append([]byte("%PDF-1.7\n"), make([]byte, 64)...), expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			uploadHandler.Handle(w, newUploadRequest(t, tt.filename, tt.content, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// failingStore is a storage backend whose uploads fail to commit, or with
+// failRecords set, to take their records.
+type failingStore struct {
+	*storage.MemoryStorage
+	failRecords bool
+}
+
+func (s failingStore) BeginUpload(ctx context.Context, uploadID string) (storage.UploadWriter, error) {
+	writer, err := s.MemoryStorage.BeginUpload(ctx, uploadID)
+	return failingWriter{UploadWriter: writer, failRecords: s.failRecords}, err
+}
+
+type failingWriter struct {
+	storage.UploadWriter
+	failRecords bool
+}
+
+func (w failingWriter) WriteRecords(records []models.Record) error {
+	if w.failRecords {
+		return errors.New("disk full")
+	}
+	return w.UploadWriter.WriteRecords(records)
+}
+
+func (failingWriter) Commit(storage.UploadBatch) error {
+	return errors.New("disk full")
+}
+
+func TestUploadHandler_StorageFailure(t *testing.T) {
+	rows := [][]interface{}{{"Name"}}
+	for i := 0; i < 2500; i++ {
+		rows = append(rows, []interface{}{fmt.Sprintf("Person %d", i)})
+	}
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: rows}).Bytes()
+
+	for _, failRecords := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failRecords=%v", failRecords), func(t *testing.T) {
+			logger := zerolog.Nop()
+			store := failingStore{MemoryStorage: storage.NewMemoryStorage(), failRecords: failRecords}
+			handler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+
+			w := httptest.NewRecorder()
+			handler.Handle(w, newUploadRequest(t, "data.xlsx", workbook, nil))
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("Status = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != "internal_error" {
+				t.Errorf("Code = %q, want internal_error", response.Code)
+			}
+			if count, _ := store.Count(); count != 0 {
+				t.Errorf("Stored %d records of a failed upload", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/ledger"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/rs/zerolog"
+)
+
+func mustLocale(t *testing.T, value string) ledger.Locale {
+	t.Helper()
+	locale, err := ledger.ParseLocale(value)
+	if err != nil {
+		t.Fatalf("ParseLocale(%q) error = %v", value, err)
+	}
+	return locale
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		locale  string
+		want    float64
+		wantOK  bool
+		wantErr bool
+	}{
+		{value: 12.5, want: 12.5, wantOK: true},
+		{value: int64(-3), want: -3, wantOK: true},
+		{value: "", wantOK: false},
+		{value: "-", wantOK: false},
+		{value: "1,234.56", want: 1234.56, wantOK: true},
+		{value: "1.234,56", want: 1234.56, wantOK: true},
+		{value: "12,50", want: 12.5, wantOK: true},
+		{value: "1,234", want: 1234, wantOK: true},
+		{value: "1.234", locale: "en-US", want: 1.234, wantOK: true},
+		{value: "1.234", locale: "de-DE", want: 1234, wantOK: true},
+		{value: "1'234.50", locale: "de-CH", want: 1234.5, wantOK: true},
+		{value: "1 234,50 €", locale: "fr-FR", want: 1234.5, wantOK: true},
+		{value: "-€3.50", want: -3.5, wantOK: true},
+		{value: "3.50-", want: -3.5, wantOK: true},
+		{value: "(3.50)", want: -3.5, wantOK: true},
+		{value: "EUR 3.50 DR", want: -3.5, wantOK: true},
+		{value: "3.50 CR", want: 3.5, wantOK: true},
+		{value: "1,234.56", locale: "de-DE", wantErr: true},
+		{value: "12-34", wantErr: true},
+		{value: "n/a", wantErr: true},
+		{value: true, wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "Infinity", wantErr: true},
+		{value: math.Inf(-1), wantErr: true},
+		{value: math.NaN(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, ok, err := ledger.ParseAmount(tt.value, mustLocale(t, tt.locale))
+		if (err != nil) != tt.wantErr || ok != tt.wantOK || got != tt.want {
+			t.Errorf("ParseAmount(%#v, %q) = %v, %v, %v, want %v, %v, error %v", tt.value, tt.locale, got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		locale  string
+		want    string
+		wantErr bool
+	}{
+		{value: "2024-01-02", want: "2024-01-02"},
+		{value: "2024-01-02T10:30:00", want: "2024-01-02"},
+		{value: "20240102", want: "2024-01-02"},
+		{value: "02.01.2024", want: "2024-01-02"},
+		{value: "02/01/24", want: "2024-01-02"},
+		{value: "01/13/2024", want: "2024-01-13"},
+		{value: "01/02/2024", locale: "en-US", want: "2024-01-02"},
+		{value: "01/02/2024", locale: "en-GB", want: "2024-02-01"},
+		{value: "2 Jan 2024", want: "2024-01-02"},
+		{value: "02-Jan-24", want: "2024-01-02"},
+		{value: "January 2, 2024", want: "2024-01-02"},
+		{value: "", want: ""},
+		{value: "31/02/2024", wantErr: true},
+		{value: "soon", wantErr: true},
+		{value: 45293.0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, _, err := ledger.ParseDate(tt.value, mustLocale(t, tt.locale))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDate(%#v, %q) = %q, %v, want %q, error %v", tt.value, tt.locale, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	for _, value := range []string{"", "auto", "en", "de-DE", "pt_BR"} {
+		if _, err := ledger.ParseLocale(value); err != nil {
+			t.Errorf("ParseLocale(%q) error = %v", value, err)
+		}
+	}
+	if _, err := ledger.ParseLocale("not a locale"); !errors.Is(err, ledger.ErrInvalidLocale) {
+		t.Errorf("ParseLocale() error = %v, want ErrInvalidLocale", err)
+	}
+}
+
+func TestParser_ParseWithOptions_Transactions(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	signed := buildWorkbook(t, testSheet{name: "Checking", rows: [][]interface{}{
+		{"Currency:", "EUR"},
+		{},
+		{"Date", "Value Date", "Description", "Amount", "Balance", "Reference"},
+		{"2024-01-02", "2024-01-03", "Coffee", -3.5, 96.5, 1001},
+		{"2024-01-03", "2024-01-03", "Salary", 1000, 1096.5, 1002},
+		{"2024-01-04", "", "Fee", "n/a", 1090, 1003},
+	}}).Bytes()
+
+	tests := []struct {
+		name       string
+		content    []byte
+		opts       xlsx.Options
+		want       []models.Transaction
+		wantErrors []models.RowError
+	}{
+		{
+			name:    "signed amounts",
+			content: signed,
+			opts:    xlsx.Options{Normalize: xlsx.NormalizeTransactions},
+			want: []models.Transaction{
+				{BookingDate: "2024-01-02", ValueDate: "2024-01-03", Description: "Coffee", Debit: 3.5, Amount: -3.5, Balance: float64Ptr(96.5), Currency: "EUR", Reference: "1001"},
+				{BookingDate: "2024-01-03", ValueDate: "2024-01-03", Description: "Salary", Credit: 1000, Amount: 1000, Balance: float64Ptr(1096.5), Currency: "EUR", Reference: "1002"},
+			},
+			wantErrors: []models.RowError{
+				{Sheet: "Checking", Row: 6, Column: "Amount", Cell: "D6", Code: ledger.CodeInvalidAmount, Message: "value is not an amount", Value: "n/a"},
+			},
+		},
+		{
+			name:    "german debit and credit columns",
+			content: []byte("Buchungstag;Verwendungszweck;Soll;Haben;Saldo;Währung\n02.01.2024;Kaffee;3,50;;1.096,50;eur\n03.01.2024;Gehalt;;1.000,00;2.096,50;EUR\n"),
+			opts:    xlsx.Options{Format: xlsx.FormatCSV, Normalize: xlsx.NormalizeTransactions, Locale: mustLocale(t, "de-DE")},
+			want: []models.Transaction{
+				{BookingDate: "2024-01-02", Description: "Kaffee", Debit: 3.5, Amount: -3.5, Balance: float64Ptr(1096.5), Currency: "EUR"},
+				{BookingDate: "2024-01-03", Description: "Gehalt", Credit: 1000, Amount: 1000, Balance: float64Ptr(2096.5), Currency: "EUR"},
+			},
+		},
+		{
+			name:    "unsigned amounts with a direction column",
+			content: []byte("Posting Date,Details,Amount,Dr/Cr\n01/13/2024,Rent,\"1,200.00\",DR\n01/14/2024,Refund,25.00,CR\n"),
+			opts:    xlsx.Options{Format: xlsx.FormatCSV, Normalize: xlsx.NormalizeTransactions, Locale: mustLocale(t, "en-US")},
+			want: []models.Transaction{
+				{BookingDate: "2024-01-13", Description: "Rent", Debit: 1200, Amount: -1200},
+				{BookingDate: "2024-01-14", Description: "Refund", Credit: 25, Amount: 25},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Transactions) != len(tt.want) {
+				t.Fatalf("Got %d transactions, want %d", len(result.Transactions), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := result.Transactions[i]
+				record := result.Records[i]
+				if got.ID == "" || got.RecordID != record.ID || got.UploadID != "upload-1" || got.Row != record.Row || got.SourceRow != record.SourceRow {
+					t.Errorf("Transaction %d does not point at record %d: %+v", i, i, got)
+				}
+				got.ID, got.UploadID, got.RecordID, got.Sheet, got.Row, got.SourceRow = "", "", "", "", 0, 0
+				got.CreatedAt = want.CreatedAt
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Transaction %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if len(result.Errors) != len(tt.wantErrors) || (len(tt.wantErrors) > 0 && !reflect.DeepEqual(result.Errors, tt.wantErrors)) {
+				t.Errorf("Errors = %+v, want %+v", result.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_NotTransactions(t *testing.T) {
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{{"Name", "City"}, {"John", "Austin"}}})
+
+	_, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), workbook, "upload-1", xlsx.Options{Normalize: xlsx.NormalizeTransactions})
+	if !errors.Is(err, ledger.ErrNotTransactions) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrNotTransactions", err)
+	}
+}
+
+func TestTransactionsHandler_Handle(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	transactionsHandler := handlers.NewTransactionsHandler(store, &logger)
+
+	content := []byte("Date,Description,Amount\n2024-01-02,Coffee,-3.50\n2024-01-03,Salary,1000\n")
+	var uploadIDs []string
+	for _, fields := range []map[string]string{{"normalize": "transactions"}, {"normalize": "transactions"}, nil} {
+		w := httptest.NewRecorder()
+		uploadHandler.Handle(w, newUploadRequest(t, "statement.csv", content, fields))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+		}
+		var upload models.UploadResponse
+		if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		uploadIDs = append(uploadIDs, upload.UploadID)
+	}
+
+	w := httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "statement.csv", content, map[string]string{"normalize": "ledger"}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Upload with unknown normalize mode status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCount  int
+		expectedTotal  int
+	}{
+		{name: "all uploads", query: "", expectedStatus: http.StatusOK, expectedCount: 4, expectedTotal: 4},
+		{name: "one upload", query: "?uploadId=" + uploadIDs[0], expectedStatus: http.StatusOK, expectedCount: 2, expectedTotal: 2},
+		{name: "upload without transactions", query: "?uploadId=" + uploadIDs[2], expectedStatus: http.StatusOK, expectedCount: 0, expectedTotal: 0},
+		{name: "paginated", query: "?limit=1&offset=3", expectedStatus: http.StatusOK, expectedCount: 1, expectedTotal: 4},
+		{name: "invalid limit", query: "?limit=abc", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			transactionsHandler.Handle(w, httptest.NewRequest(http.MethodGet, "/v1/transactions"+tt.query, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Status = %d, want %d, body %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.ListTransactionsResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Transactions) != tt.expectedCount || response.Total != tt.expectedTotal {
+				t.Errorf("Got %d transactions of %d, want %d of %d", len(response.Transactions), response.Total, tt.expectedCount, tt.expectedTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"Txn Date", "txn_date"},
+		{"  Transaction   Date ", "transaction_date"},
+		{"TxnDate", "txn_date"},
+		{"txn-date", "txn_date"},
+		{"Amount (EUR)", "amount_eur"},
+		{"IBANNumber", "iban_number"},
+		{"Balance2", "balance2"},
+		{"already_snake", "already_snake"},
+		{"Straße Nr.", "straße_nr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := mapping.SnakeCase(tt.header); got != tt.want {
+				t.Errorf("SnakeCase(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfile_Compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile mapping.Profile
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			profile: mapping.Profile{Name: "banks", Fields: []mapping.Field{{Name: "date", Aliases: []string{"Txn Date"}}}},
+		},
+		{
+			name:    "missing name",
+			profile: mapping.Profile{Fields: []mapping.Field{{Name: "date"}}},
+			wantErr: true,
+		},
+		{
+			name:    "no fields",
+			profile: mapping.Profile{Name: "banks"},
+			wantErr: true,
+		},
+		{
+			name:    "preamble only",
+			profile: mapping.Profile{Name: "banks", Preamble: []mapping.PreambleField{{Name: "accountNumber", Patterns: []string{`konto(nummer)?`}}}},
+		},
+		{
+			name:    "invalid preamble pattern",
+			profile: mapping.Profile{Name: "banks", Preamble: []mapping.PreambleField{{Name: "accountNumber", Patterns: []string{`konto(`}}}},
+			wantErr: true,
+		},
+		{
+			name:    "blank alias",
+			profile: mapping.Profile{Name: "banks", Fields: []mapping.Field{{Name: "date", Aliases: []string{" "}}}, FoldWhitespace: true},
+			wantErr: true,
+		},
+		{
+			name: "alias claimed twice after folding",
+			profile: mapping.Profile{Name: "banks", FoldCase: true, Fields: []mapping.Field{
+				{Name: "date", Aliases: []string{"Date"}},
+				{Name: "value_date", Aliases: []string{"DATE"}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, mapping.ErrInvalidProfile) {
+				t.Errorf("Compile() error = %v, want ErrInvalidProfile", err)
+			}
+		})
+	}
+}
+
+func TestProfile_Apply(t *testing.T) {
+	fields := []mapping.Field{
+		{Name: "date", Aliases: []string{"Txn Date", "Transaction Date"}},
+		{Name: "amount", Aliases: []string{"Amount (EUR)"}},
+	}
+	headers := []string{"TXN  DATE", "Amount (EUR)", "Memo Text", ""}
+
+	tests := []struct {
+		name    string
+		profile mapping.Profile
+		want    []string
+	}{
+		{
+			name:    "exact aliases only",
+			profile: mapping.Profile{Fields: fields},
+			want:    []string{"TXN  DATE", "amount", "Memo Text", ""},
+		},
+		{
+			name:    "folded",
+			profile: mapping.Profile{Fields: fields, FoldCase: true, FoldWhitespace: true},
+			want:    []string{"date", "amount", "Memo Text", ""},
+		},
+		{
+			name:    "snake case",
+			profile: mapping.Profile{Fields: fields, FoldCase: true, FoldWhitespace: true, SnakeCase: true},
+			want:    []string{"date", "amount", "memo_text", ""},
+		},
+		{
+			name:    "drop unmapped",
+			profile: mapping.Profile{Fields: fields, FoldCase: true, FoldWhitespace: true, DropUnmapped: true},
+			want:    []string{"date", "amount", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.profile.Name = "banks"
+			if err := tt.profile.Compile(); err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if got := tt.profile.Apply(headers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_Profile(t *testing.T) {
+	profile := &mapping.Profile{
+		Name:           "banks",
+		FoldCase:       true,
+		FoldWhitespace: true,
+		DropUnmapped:   true,
+		Fields: []mapping.Field{
+			{Name: "date", Aliases: []string{"Txn Date", "Transaction Date"}},
+			{Name: "amount", Aliases: []string{"Betrag", "Amount"}},
+		},
+	}
+	if err := profile.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	s := &schema.Schema{Name: "canonical", Columns: []schema.Column{
+		{Name: "date", Required: true},
+		{Name: "amount", Required: true, Type: schema.TypeNumber},
+	}}
+	if err := s.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	workbook := buildWorkbook(t,
+		testSheet{name: "Bank A", rows: [][]interface{}{{"Txn Date", "Amount", "Memo"}, {"2024-01-02", 10, "coffee"}}},
+		testSheet{name: "Bank B", rows: [][]interface{}{{"TRANSACTION DATE", "Betrag"}, {"2024-01-03", 20}}},
+	)
+
+	result, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), workbook, "upload-1", xlsx.Options{
+		Sheets:  xlsx.SheetSelector{All: true},
+		Profile: profile,
+		Schema:  s,
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+
+	if len(result.Records) != 2 {
+		t.Fatalf("Got %d records, want 2", len(result.Records))
+	}
+	for _, record := range result.Records {
+		if _, ok := record.Data["date"]; !ok {
+			t.Errorf("Record from %s has no date field: %v", record.Sheet, record.Data)
+		}
+		if _, ok := record.Data["amount"]; !ok {
+			t.Errorf("Record from %s has no amount field: %v", record.Sheet, record.Data)
+		}
+		if _, ok := record.Data["Memo"]; ok {
+			t.Errorf("Record from %s kept the unmapped Memo column", record.Sheet)
+		}
+	}
+}
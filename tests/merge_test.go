@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/xuri/excelize/v2"
+)
+
+// buildMergedWorkbook writes rows to Sheet1 and merges the given ranges,
+// e.g. "B1:C1".
+func buildMergedWorkbook(t *testing.T, rows [][]interface{}, merges ...string) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for r, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, r+1)
+		row := row
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+	for _, ref := range merges {
+		first, last, _ := strings.Cut(ref, ":")
+		if err := f.MergeCell("Sheet1", first, last); err != nil {
+			t.Fatalf("Failed to merge %s: %v", ref, err)
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	return buf.Bytes()
+}
+
+const odsMergedContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+    xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+    xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+    xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+  <office:body>
+    <office:spreadsheet>
+      <table:table table:name="Sheet1">
+        <table:table-row>
+          <table:table-cell table:number-rows-spanned="2" office:value-type="string"><text:p>Date</text:p></table:table-cell>
+          <table:table-cell table:number-columns-spanned="2" office:value-type="string"><text:p>Amount</text:p></table:table-cell>
+          <table:covered-table-cell/>
+        </table:table-row>
+        <table:table-row>
+          <table:covered-table-cell/>
+          <table:table-cell office:value-type="string"><text:p>Debit</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Credit</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="date" office:date-value="2024-01-02"><text:p>02/01/24</text:p></table:table-cell>
+          <table:table-cell office:value-type="float" office:value="5"><text:p>5</text:p></table:table-cell>
+          <table:table-cell/>
+        </table:table-row>
+      </table:table>
+    </office:spreadsheet>
+  </office:body>
+</office:document-content>`
+
+func TestParser_ParseWithOptions_MergedHeaders(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		content        []byte
+		opts           xlsx.Options
+		wantHeaderRow  int
+		wantHeaderRows int
+		wantRows       []map[string]interface{}
+	}{
+		{
+			name: "merged two-row header",
+			content: buildMergedWorkbook(t, [][]interface{}{
+				{"Date", "Amount", nil, "Balance"},
+				{nil, "Debit", "Credit", nil},
+				{"2024-01-02", 10, nil, 90},
+				{"2024-01-03", nil, 5, 95},
+			}, "A1:A2", "B1:C1", "D1:D2"),
+			wantHeaderRow:  1,
+			wantHeaderRows: 2,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Amount.Debit": int64(10), "Amount.Credit": nil, "Balance": int64(90)},
+				{"Transaction Index": 2, "Date": "2024-01-03", "Amount.Debit": nil, "Amount.Credit": int64(5), "Balance": int64(95)},
+			},
+		},
+		{
+			name: "group row below a merged title",
+			content: buildMergedWorkbook(t, [][]interface{}{
+				{"Account statement"},
+				{},
+				{"Date", "Amount", nil, "Balance"},
+				{nil, "Debit", "Credit", nil},
+				{"2024-01-02", 10, nil, 90},
+			}, "A1:D1", "B3:C3"),
+			wantHeaderRow:  3,
+			wantHeaderRows: 2,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Amount.Debit": int64(10), "Amount.Credit": nil, "Balance": int64(90)},
+			},
+		},
+		{
+			name: "merged header over data",
+			content: buildMergedWorkbook(t, [][]interface{}{
+				{"Name", "Amount", nil},
+				{"John", 10, 20},
+			}, "B1:C1"),
+			wantHeaderRow: 1,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "John", "Amount": int64(10), "Amount_2": int64(20)},
+			},
+		},
+		{
+			name: "requested header rows",
+			content: buildMergedWorkbook(t, [][]interface{}{
+				{"Name", "Contact", nil},
+				{nil, "Email", "Phone"},
+				{"John", "john@example.com", "555"},
+			}),
+			opts:           xlsx.Options{HeaderRows: 2},
+			wantHeaderRow:  1,
+			wantHeaderRows: 2,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "John", "Contact.Email": "john@example.com", "Phone": "555"},
+			},
+		},
+		{
+			name: "explicit header row below a tall merge",
+			content: buildMergedWorkbook(t, [][]interface{}{
+				{"Date", "Statement"},
+				{nil, "Account: 12345678"},
+				{},
+				{},
+				{},
+				{},
+				{nil, "Amount"},
+				{"2024-01-02", 10},
+			}, "A1:A7"),
+			opts:          xlsx.Options{HeaderRow: 7},
+			wantHeaderRow: 7,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Amount": int64(10)},
+			},
+		},
+		{
+			name:           "spanned ods cells",
+			content:        buildODS(t, odsMergedContent),
+			opts:           xlsx.Options{Format: xlsx.FormatODS},
+			wantHeaderRow:  1,
+			wantHeaderRows: 2,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Amount.Debit": int64(5), "Amount.Credit": nil},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			sheet := result.Sheets[0]
+			if sheet.HeaderRow != tt.wantHeaderRow || sheet.HeaderRows != tt.wantHeaderRows {
+				t.Errorf("HeaderRow, HeaderRows = %d, %d, want %d, %d", sheet.HeaderRow, sheet.HeaderRows, tt.wantHeaderRow, tt.wantHeaderRows)
+			}
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantRows))
+			}
+			for i, want := range tt.wantRows {
+				if got := result.Records[i].Data; !reflect.DeepEqual(got, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_MergedCells(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	content := buildMergedWorkbook(t, [][]interface{}{
+		{"Category", "Memo", "Amount"},
+		{"Food", "Lunch", 10},
+		{nil, "Dinner", 20},
+		{"Travel", "n/a", nil},
+	}, "A2:A3", "B4:C4")
+
+	tests := []struct {
+		name     string
+		policy   xlsx.MergePolicy
+		wantRows []map[string]interface{}
+	}{
+		{
+			name:   "keep",
+			policy: xlsx.MergedKeep,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Category": "Food", "Memo": "Lunch", "Amount": int64(10)},
+				{"Transaction Index": 2, "Category": nil, "Memo": "Dinner", "Amount": int64(20)},
+				{"Transaction Index": 3, "Category": "Travel", "Memo": "n/a", "Amount": nil},
+			},
+		},
+		{
+			name:   "fill",
+			policy: xlsx.MergedFill,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Category": "Food", "Memo": "Lunch", "Amount": int64(10)},
+				{"Transaction Index": 2, "Category": "Food", "Memo": "Dinner", "Amount": int64(20)},
+				{"Transaction Index": 3, "Category": "Travel", "Memo": "n/a", "Amount": "n/a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(context.Background(), bytes.NewReader(content), "upload-1", xlsx.Options{MergedCells: tt.policy})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantRows))
+			}
+			for i, want := range tt.wantRows {
+				if got := result.Records[i].Data; !reflect.DeepEqual(got, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
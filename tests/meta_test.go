@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/xuri/excelize/v2"
+)
+
+// buildMetaWorkbook writes an invoice list whose cells carry hyperlinks, a
+// comment, number formats and rich text.
+func buildMetaWorkbook(t *testing.T) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	rows := [][]interface{}{
+		{"Invoice", "Amount", "Status"},
+		{"INV-1", 1250.5, nil},
+		{"INV-2", 80, "Open"},
+	}
+	for r, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, r+1)
+		row := row
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Failed to build workbook: %v", err)
+		}
+	}
+	// A long tooltip pushes the relationship ID far into the element, and
+	// the location is escaped in the XML.
+	tooltip := strings.Repeat("Open the scanned invoice. ", 20)
+	must(f.SetCellHyperLink("Sheet1", "A2", "https://example.com/invoices/1.pdf", "External", excelize.HyperlinkOpts{Tooltip: &tooltip}))
+	must(f.SetCellHyperLink("Sheet1", "A3", "'R&D'!B3", "Location"))
+	must(f.AddComment("Sheet1", excelize.Comment{Cell: "B2", Author: "Reviewer", Text: "Checked against PO"}))
+	must(f.SetCellRichText("Sheet1", "C2", []excelize.RichTextRun{
+		{Text: "Paid ", Font: &excelize.Font{Bold: true}},
+		{Text: "late", Font: &excelize.Font{Italic: true, Color: "FF0000"}},
+	}))
+
+	style, err := f.NewStyle(&excelize.Style{NumFmt: 4})
+	must(err)
+	must(f.SetCellStyle("Sheet1", "B2", "B2", style))
+	format := `"$"#,##0.00`
+	custom, err := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	must(err)
+	must(f.SetCellStyle("Sheet1", "B3", "B3", custom))
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	return buf.Bytes()
+}
+
+const odsMetaContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+    xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+    xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+    xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+    xmlns:xlink="http://www.w3.org/1999/xlink"
+    xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <office:body>
+    <office:spreadsheet>
+      <table:table table:name="Sheet1">
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Invoice</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Amount</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p><text:a xlink:href="https://example.com/invoices/1.pdf">INV-1</text:a></text:p></table:table-cell>
+          <table:table-cell office:value-type="float" office:value="1250.5">
+            <office:annotation><dc:creator>Reviewer</dc:creator><dc:date>2024-01-02T10:00:00</dc:date><text:p>Checked against PO</text:p></office:annotation>
+            <text:p>1250.5</text:p>
+          </table:table-cell>
+        </table:table-row>
+      </table:table>
+    </office:spreadsheet>
+  </office:body>
+</office:document-content>`
+
+func TestParser_ParseWithOptions_Meta(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+	workbook := buildMetaWorkbook(t)
+
+	comment := &models.CellComment{Author: "Reviewer", Text: "Checked against PO"}
+	richText := []models.RichTextRun{
+		{Text: "Paid ", Bold: true},
+		{Text: "late", Italic: true, Color: "FFFF0000"},
+	}
+	workbookMeta := []map[string]models.CellMeta{
+		{
+			"Invoice": {Hyperlink: "https://example.com/invoices/1.pdf"},
+			"Amount":  {Comment: comment, NumberFormat: "#,##0.00"},
+			"Status":  {RichText: richText},
+		},
+		{
+			"Invoice": {Hyperlink: "#'R&D'!B3"},
+			"Amount":  {NumberFormat: `"$"#,##0.00`},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		content  []byte
+		opts     xlsx.Options
+		wantMeta []map[string]models.CellMeta
+		wantData map[string]interface{}
+	}{
+		{
+			name:     "typed values",
+			content:  workbook,
+			opts:     xlsx.Options{Meta: true},
+			wantMeta: workbookMeta,
+			wantData: map[string]interface{}{"Transaction Index": 1, "Invoice": "INV-1", "Amount": 1250.5, "Status": "Paid late"},
+		},
+		{
+			name:     "display strings",
+			content:  workbook,
+			opts:     xlsx.Options{Meta: true, Values: xlsx.ValuesString},
+			wantMeta: workbookMeta,
+			wantData: map[string]interface{}{"Transaction Index": 1, "Invoice": "INV-1", "Amount": "1,250.50", "Status": "Paid late"},
+		},
+		{
+			name:     "not requested",
+			content:  workbook,
+			wantMeta: []map[string]models.CellMeta{nil, nil},
+			wantData: map[string]interface{}{"Transaction Index": 1, "Invoice": "INV-1", "Amount": 1250.5, "Status": "Paid late"},
+		},
+		{
+			name:    "ods links and annotations",
+			content: buildODS(t, odsMetaContent),
+			opts:    xlsx.Options{Format: xlsx.FormatODS, Meta: true},
+			wantMeta: []map[string]models.CellMeta{{
+				"Invoice": {Hyperlink: "https://example.com/invoices/1.pdf"},
+				"Amount":  {Comment: comment},
+			}},
+			wantData: map[string]interface{}{"Transaction Index": 1, "Invoice": "INV-1", "Amount": 1250.5},
+		},
+		{
+			name:    "xls links and comments",
+			content: buildXLSWorkbook(t, false),
+			opts:    xlsx.Options{Format: xlsx.FormatXLS, Meta: true},
+			wantMeta: []map[string]models.CellMeta{
+				{
+					"Date":   {NumberFormat: "dd/mm/yyyy"},
+					"Memo":   {Hyperlink: "https://example.com/receipts/1"},
+					"Amount": {Comment: comment},
+				},
+				{
+					"Date": {NumberFormat: "mm-dd-yy"},
+					"Memo": {Hyperlink: "../receipts/2.pdf"},
+				},
+				{
+					"Memo": {Hyperlink: "#Other!A1"},
+				},
+			},
+			wantData: map[string]interface{}{"Transaction Index": 1, "Date": "2024-01-02", "Memo": "Café latte", "Amount": -12.5, "Fee": int64(3), "Cleared": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Records) != len(tt.wantMeta) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantMeta))
+			}
+			if got := result.Records[0].Data; !reflect.DeepEqual(got, tt.wantData) {
+				t.Errorf("Record 0 data = %#v, want %#v", got, tt.wantData)
+			}
+			for i, want := range tt.wantMeta {
+				if got := result.Records[i].Meta; !reflect.DeepEqual(got, want) {
+					t.Errorf("Record %d meta = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMetaFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr error
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "1", want: true},
+		{value: "false", want: false},
+		{value: "yes", wantErr: xlsx.ErrInvalidMetaFlag},
+	}
+
+	for _, tt := range tests {
+		got, err := xlsx.ParseMetaFlag(tt.value)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("ParseMetaFlag(%q) = %v, %v, want %v, %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+)
+
+const odsContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+    xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+    xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+    xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+    xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <office:body>
+    <office:spreadsheet>
+      <table:table table:name="Statement">
+        <table:table-column table:number-columns-repeated="5"/>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Account statement</text:p></table:table-cell>
+          <table:table-cell table:number-columns-repeated="1023"/>
+        </table:table-row>
+        <table:table-row table:number-rows-repeated="2">
+          <table:table-cell table:number-columns-repeated="1024"/>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Date</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Memo</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Amount</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Cleared</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Time</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="date" office:date-value="2024-01-02"><text:p>02/01/24</text:p></table:table-cell>
+          <table:table-cell office:value-type="string">
+            <office:annotation><dc:creator>Ann</dc:creator><text:p>a comment</text:p></office:annotation>
+            <text:p>two<text:s text:c="2"/>spaces</text:p><text:p>second line</text:p>
+          </table:table-cell>
+          <table:table-cell office:value-type="currency" office:currency="EUR" office:value="-12.5"><text:p>-12,50 €</text:p></table:table-cell>
+          <table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+          <table:table-cell office:value-type="time" office:time-value="PT09H05M30S"><text:p>09:05:30</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row table:number-rows-repeated="2">
+          <table:table-cell office:value-type="date" office:date-value="2024-01-03T10:15:00"><text:p>03/01/24 10:15</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>repeat</text:p></table:table-cell>
+          <table:table-cell office:value-type="percentage" office:value="0.25" table:number-columns-repeated="1"><text:p>25%</text:p></table:table-cell>
+          <table:table-cell table:number-columns-repeated="1021"/>
+        </table:table-row>
+        <table:table-row table:number-rows-repeated="1048570">
+          <table:table-cell table:number-columns-repeated="1024"/>
+        </table:table-row>
+      </table:table>
+      <table:table table:name="Other">
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Name</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>John</text:p></table:table-cell>
+        </table:table-row>
+      </table:table>
+    </office:spreadsheet>
+  </office:body>
+</office:document-content>`
+
+func buildODS(t *testing.T, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		t.Fatalf("Failed to create mimetype: %v", err)
+	}
+	mimetype.Write([]byte("application/vnd.oasis.opendocument.spreadsheet"))
+
+	part, err := zw.Create("content.xml")
+	if err != nil {
+		t.Fatalf("Failed to create content part: %v", err)
+	}
+	part.Write([]byte(content))
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write ods file: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParser_ParseWithOptions_ODS(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+	ods := buildODS(t, odsContent)
+
+	tests := []struct {
+		name       string
+		opts       xlsx.Options
+		wantHeader int
+		wantRows   []map[string]interface{}
+		wantSource []int
+	}{
+		{
+			name:       "typed",
+			opts:       xlsx.Options{Format: xlsx.FormatODS},
+			wantHeader: 4,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Memo": "two  spaces\nsecond line", "Amount": -12.5, "Cleared": true, "Time": "09:05:30"},
+				{"Transaction Index": 2, "Date": "2024-01-03T10:15:00", "Memo": "repeat", "Amount": 0.25, "Cleared": nil, "Time": nil},
+				{"Transaction Index": 3, "Date": "2024-01-03T10:15:00", "Memo": "repeat", "Amount": 0.25, "Cleared": nil, "Time": nil},
+			},
+			wantSource: []int{5, 6, 7},
+		},
+		{
+			name:       "display strings with detected format",
+			opts:       xlsx.Options{Format: xlsx.FormatAuto, Values: xlsx.ValuesString},
+			wantHeader: 4,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "02/01/24", "Memo": "two  spaces\nsecond line", "Amount": "-12,50 €", "Cleared": "TRUE", "Time": "09:05:30"},
+				{"Transaction Index": 2, "Date": "03/01/24 10:15", "Memo": "repeat", "Amount": "25%", "Cleared": nil, "Time": nil},
+				{"Transaction Index": 3, "Date": "03/01/24 10:15", "Memo": "repeat", "Amount": "25%", "Cleared": nil, "Time": nil},
+			},
+			wantSource: []int{5, 6, 7},
+		},
+		{
+			name:       "second sheet",
+			opts:       xlsx.Options{Format: xlsx.FormatODS, Sheets: xlsx.SheetSelector{Refs: []string{"Other"}}},
+			wantHeader: 1,
+			wantRows:   []map[string]interface{}{{"Transaction Index": 1, "Name": "John"}},
+			wantSource: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(ods), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if got := result.Sheets[0].HeaderRow; got != tt.wantHeader {
+				t.Errorf("HeaderRow = %d, want %d", got, tt.wantHeader)
+			}
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantRows))
+			}
+			for i, want := range tt.wantRows {
+				record := result.Records[i]
+				if !reflect.DeepEqual(record.Data, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, record.Data, want)
+				}
+				if record.SourceRow != tt.wantSource[i] {
+					t.Errorf("Record %d SourceRow = %d, want %d", i, record.SourceRow, tt.wantSource[i])
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,21 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
 	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/xuri/excelize/v2"
 )
 
 func TestParser_ParseRow(t *testing.T) {
@@ -95,3 +105,756 @@ func TestParser_Parse_InvalidInput(t *testing.T) {
 		})
 	}
 }
+
+type testSheet struct {
+	name string
+	rows [][]interface{}
+}
+
+func buildWorkbook(t *testing.T, sheets ...testSheet) *bytes.Buffer {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheet.name); err != nil {
+				t.Fatalf("Failed to rename sheet: %v", err)
+			}
+		} else if _, err := f.NewSheet(sheet.name); err != nil {
+			t.Fatalf("Failed to create sheet: %v", err)
+		}
+
+		for r, row := range sheet.rows {
+			cell, _ := excelize.CoordinatesToCellName(1, r+1)
+			row := row
+			if err := f.SetSheetRow(sheet.name, cell, &row); err != nil {
+				t.Fatalf("Failed to write row: %v", err)
+			}
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	return buf
+}
+
+func TestParser_ParseWithOptions_Sheets(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	sheets := []testSheet{
+		{name: "Checking", rows: [][]interface{}{{"Name", "Amount"}, {"Rent", "1200"}, {"Food", "300"}}},
+		{name: "Savings", rows: [][]interface{}{{"Name", "Amount"}, {"Interest", "12"}}},
+		{name: "Notes"},
+	}
+
+	tests := []struct {
+		name       string
+		sheets     string
+		wantSheets []string
+		wantRows   int
+		wantErr    error
+	}{
+		{name: "default parses first sheet", sheets: "", wantSheets: []string{"Checking"}, wantRows: 2},
+		{name: "all sheets skips empty ones", sheets: "all", wantSheets: []string{"Checking", "Savings"}, wantRows: 3},
+		{name: "by name", sheets: "Savings", wantSheets: []string{"Savings"}, wantRows: 1},
+		{name: "by index", sheets: "1,0", wantSheets: []string{"Savings", "Checking"}, wantRows: 3},
+		{name: "unknown sheet", sheets: "Loans", wantErr: xlsx.ErrSheetNotFound},
+		{name: "index out of range", sheets: "5", wantErr: xlsx.ErrSheetNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := xlsx.ParseSheetSelector(tt.sheets)
+			if err != nil {
+				t.Fatalf("ParseSheetSelector() error = %v", err)
+			}
+
+			result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, sheets...), "upload-1", xlsx.Options{Sheets: selector})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseWithOptions() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if len(result.Sheets) != len(tt.wantSheets) {
+				t.Fatalf("Got %d sheet summaries, want %d", len(result.Sheets), len(tt.wantSheets))
+			}
+			for i, name := range tt.wantSheets {
+				if result.Sheets[i].Name != name {
+					t.Errorf("Sheet %d = %s, want %s", i, result.Sheets[i].Name, name)
+				}
+			}
+
+			if result.RowsAccepted != tt.wantRows {
+				t.Errorf("RowsAccepted = %d, want %d", result.RowsAccepted, tt.wantRows)
+			}
+
+			for _, record := range result.Records {
+				if record.Sheet == "" {
+					t.Errorf("Record %s has no sheet name", record.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_HeaderRow(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	statement := func(preamble ...[]interface{}) testSheet {
+		rows := append([][]interface{}{}, preamble...)
+		rows = append(rows,
+			[]interface{}{"Date", "Description", "Debit", "Credit", "Balance"},
+			[]interface{}{"2024-01-02", "Opening deposit", nil, 500, 500},
+			[]interface{}{"2024-01-05", "Coffee", 4.5, nil, 495.5},
+			[]interface{}{"2024-01-09", "Groceries", 60, nil, 435.5},
+		)
+		return testSheet{name: "Statement", rows: rows}
+	}
+
+	tests := []struct {
+		name          string
+		sheet         testSheet
+		headerRow     int
+		wantHeaderRow int
+		wantAccepted  int
+		wantErr       bool
+	}{
+		{
+			name:          "plain table",
+			sheet:         statement(),
+			wantHeaderRow: 1,
+			wantAccepted:  3,
+		},
+		{
+			name: "seven row preamble",
+			sheet: statement(
+				[]interface{}{"Account Statement"},
+				[]interface{}{"Account Name", "Jane Doe"},
+				[]interface{}{"Account Number", 12345678},
+				[]interface{}{"Currency", "USD"},
+				[]interface{}{"Period", "2024-01-01", "2024-01-31"},
+				[]interface{}{},
+				[]interface{}{"Generated by online banking"},
+			),
+			wantHeaderRow: 8,
+			wantAccepted:  3,
+		},
+		{
+			name: "three row preamble",
+			sheet: statement(
+				[]interface{}{"Transactions export"},
+				[]interface{}{"Account", 12345678},
+				[]interface{}{},
+			),
+			wantHeaderRow: 4,
+			wantAccepted:  3,
+		},
+		{
+			name:          "explicit header row",
+			sheet:         statement([]interface{}{"Title"}),
+			headerRow:     2,
+			wantHeaderRow: 2,
+			wantAccepted:  3,
+		},
+		{
+			name:      "explicit header row past the data",
+			sheet:     statement(),
+			headerRow: 4,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, tt.sheet), "upload-1", xlsx.Options{HeaderRow: tt.headerRow})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ParseWithOptions() expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if got := result.Sheets[0].HeaderRow; got != tt.wantHeaderRow {
+				t.Errorf("HeaderRow = %d, want %d", got, tt.wantHeaderRow)
+			}
+			if result.RowsAccepted != tt.wantAccepted {
+				t.Errorf("RowsAccepted = %d, want %d", result.RowsAccepted, tt.wantAccepted)
+			}
+			if _, ok := result.Records[0].Data["Description"]; !ok {
+				t.Errorf("Record is missing the Description column: %v", result.Records[0].Data)
+			}
+		})
+	}
+}
+
+func TestParseHeaderRow(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "auto", want: 0},
+		{value: "8", want: 8},
+		{value: "0", wantErr: true},
+		{value: "first", wantErr: true},
+		{value: "1048576", want: 1048576},
+		{value: "1048577", wantErr: true},
+		{value: "9223372036854775807", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := xlsx.ParseHeaderRow(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHeaderRow(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseHeaderRow(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParser_ParseWithOptions_TypedValues(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	build := func(t *testing.T, date1904 bool) *bytes.Buffer {
+		t.Helper()
+
+		f := excelize.NewFile()
+		defer f.Close()
+
+		if date1904 {
+			enabled := true
+			if err := f.SetWorkbookProps(&excelize.WorkbookPropsOptions{Date1904: &enabled}); err != nil {
+				t.Fatalf("Failed to set workbook props: %v", err)
+			}
+		}
+
+		header := []interface{}{"Name", "Amount", "Count", "Active", "Booked", "Posted", "Reference"}
+		if err := f.SetSheetRow("Sheet1", "A1", &header); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		row := []interface{}{"Rent", 1200.5, 3, true, 45292, 45292.75, "00123"}
+		if err := f.SetSheetRow("Sheet1", "A2", &row); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+
+		dateStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 14})
+		customFmt := "dd/mm/yyyy hh:mm"
+		dateTimeStyle, _ := f.NewStyle(&excelize.Style{CustomNumFmt: &customFmt})
+		f.SetCellStyle("Sheet1", "E2", "E2", dateStyle)
+		f.SetCellStyle("Sheet1", "F2", "F2", dateTimeStyle)
+
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatalf("Failed to write workbook: %v", err)
+		}
+		return buf
+	}
+
+	tests := []struct {
+		name     string
+		date1904 bool
+		values   xlsx.ValueMode
+		want     map[string]interface{}
+	}{
+		{
+			name: "typed values",
+			want: map[string]interface{}{
+				"Name":      "Rent",
+				"Amount":    1200.5,
+				"Count":     int64(3),
+				"Active":    true,
+				"Booked":    "2024-01-01",
+				"Posted":    "2024-01-01T18:00:00",
+				"Reference": "00123",
+			},
+		},
+		{
+			name:     "1904 date system",
+			date1904: true,
+			want: map[string]interface{}{
+				"Booked": "2028-01-02",
+			},
+		},
+		{
+			name:   "string values",
+			values: xlsx.ValuesString,
+			want: map[string]interface{}{
+				"Amount": "1200.5",
+				"Count":  "3",
+				"Active": "TRUE",
+				"Booked": "01-01-24",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, build(t, tt.date1904), "upload-1", xlsx.Options{Values: tt.values})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Records) != 1 {
+				t.Fatalf("Got %d records, want 1", len(result.Records))
+			}
+
+			data := result.Records[0].Data
+			for column, want := range tt.want {
+				if got := data[column]; got != want {
+					t.Errorf("%s = %#v, want %#v", column, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseValueMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    xlsx.ValueMode
+		wantErr bool
+	}{
+		{value: "", want: xlsx.ValuesTyped},
+		{value: "typed", want: xlsx.ValuesTyped},
+		{value: "string", want: xlsx.ValuesString},
+		{value: "numbers", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := xlsx.ParseValueMode(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseValueMode(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseValueMode(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParser_Parse_ReaderKinds(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	workbook := buildWorkbook(t, testSheet{
+		name: "Sheet1",
+		rows: [][]interface{}{{"Name", "Amount"}, {"Rent", 1200}, {}, {"Food", 300}, {}, {}},
+	}).Bytes()
+
+	path := filepath.Join(t.TempDir(), "upload.xlsx")
+	if err := os.WriteFile(path, workbook, 0o600); err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		reader func(t *testing.T) io.Reader
+	}{
+		{
+			name:   "in-memory reader",
+			reader: func(t *testing.T) io.Reader { return bytes.NewReader(workbook) },
+		},
+		{
+			name: "file on disk",
+			reader: func(t *testing.T) io.Reader {
+				file, err := os.Open(path)
+				if err != nil {
+					t.Fatalf("Failed to open workbook: %v", err)
+				}
+				t.Cleanup(func() { file.Close() })
+				return file
+			},
+		},
+		{
+			name:   "sequential reader",
+			reader: func(t *testing.T) io.Reader { return io.MultiReader(bytes.NewReader(workbook)) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.Parse(ctx, tt.reader(t), "upload-1")
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+
+			// The blank row between the data rows is rejected; the trailing
+			// blank rows are not part of the sheet.
+			if result.RowsAccepted != 2 || result.RowsRejected != 1 {
+				t.Errorf("Accepted/rejected = %d/%d, want 2/1", result.RowsAccepted, result.RowsRejected)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_PreservesRowOrder(t *testing.T) {
+	parser := xlsx.NewParser(8)
+	ctx := context.Background()
+
+	rows := [][]interface{}{
+		{"Statement"},
+		{},
+		{"Index", "Description", "Amount"},
+	}
+	const dataRows = 500
+	for i := 1; i <= dataRows; i++ {
+		if i == 10 {
+			rows = append(rows, []interface{}{})
+		}
+		rows = append(rows, []interface{}{i, fmt.Sprintf("Item %d", i), i * 10})
+	}
+
+	result, err := parser.Parse(ctx, buildWorkbook(t, testSheet{name: "Sheet1", rows: rows}), "upload-1")
+	if err != nil {
+		t.Fatalf("Parse() unexpected error = %v", err)
+	}
+
+	if len(result.Records) != dataRows {
+		t.Fatalf("Got %d records, want %d", len(result.Records), dataRows)
+	}
+
+	for i, record := range result.Records {
+		want := int64(i + 1)
+		if got := record.Data["Index"]; got != want {
+			t.Fatalf("Record %d has Index %v, want %d", i, got, want)
+		}
+
+		// Data starts on row 4; the blank row 13 shifts everything after it.
+		wantRow := i + 4
+		wantOrdinal := i + 1
+		if i >= 9 {
+			wantRow++
+			wantOrdinal++
+		}
+		if record.SourceRow != wantRow {
+			t.Errorf("Record %d SourceRow = %d, want %d", i, record.SourceRow, wantRow)
+		}
+		if record.SourceRef != fmt.Sprintf("A%d", wantRow) {
+			t.Errorf("Record %d SourceRef = %s, want A%d", i, record.SourceRef, wantRow)
+		}
+		if record.Row != wantOrdinal {
+			t.Errorf("Record %d Row = %d, want %d", i, record.Row, wantOrdinal)
+		}
+	}
+}
+
+func TestParser_ParseWithOptions_RecordSink(t *testing.T) {
+	parser := xlsx.NewParser(8)
+	ctx := context.Background()
+
+	rows := [][]interface{}{{"Index"}}
+	const dataRows = 2500
+	for i := 1; i <= dataRows; i++ {
+		rows = append(rows, []interface{}{i})
+	}
+	sheets := []testSheet{{name: "First", rows: rows}, {name: "Second", rows: rows[:11]}}
+
+	var batches []int
+	var sunk []models.Record
+	result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, sheets...), "upload-1", xlsx.Options{
+		Sheets: xlsx.SheetSelector{All: true},
+		RecordSink: func(records []models.Record) error {
+			batches = append(batches, len(records))
+			sunk = append(sunk, records...)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+	}
+
+	if len(result.Records) != 0 {
+		t.Errorf("Result kept %d records, want none", len(result.Records))
+	}
+	if result.RowsAccepted != dataRows+10 {
+		t.Errorf("RowsAccepted = %d, want %d", result.RowsAccepted, dataRows+10)
+	}
+	if want := []int{1000, 1000, 500 + 10}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("Batches = %v, want %v", batches, want)
+	}
+	for i, record := range sunk[:dataRows] {
+		if got := record.Data["Index"]; got != int64(i+1) {
+			t.Fatalf("Record %d has Index %v, want %d", i, got, i+1)
+		}
+	}
+	if sunk[dataRows].Sheet != "Second" {
+		t.Errorf("Record %d is from sheet %s, want Second", dataRows, sunk[dataRows].Sheet)
+	}
+
+	failure := errors.New("disk full")
+	_, err = parser.ParseWithOptions(ctx, buildWorkbook(t, sheets...), "upload-2", xlsx.Options{
+		RecordSink: func([]models.Record) error { return failure },
+	})
+	if !errors.Is(err, xlsx.ErrRecordSink) || !errors.Is(err, failure) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrRecordSink wrapping the sink error", err)
+	}
+}
+
+func TestParser_ParseWithOptions_TransactionSink(t *testing.T) {
+	parser := xlsx.NewParser(8)
+	ctx := context.Background()
+
+	// The balance jumps by 5 at transaction 1500, and the rows after the
+	// transactions have no usable amount.
+	const transactions, rejected = 2500, 1100
+	rows := [][]interface{}{{"Date", "Description", "Amount", "Balance"}}
+	for i := 1; i <= transactions; i++ {
+		balance := i
+		if i >= 1500 {
+			balance += 5
+		}
+		rows = append(rows, []interface{}{"2024-01-02", "Transfer", 1, balance})
+	}
+	for i := 0; i < rejected; i++ {
+		rows = append(rows, []interface{}{"2024-01-03", "Unknown", "n/a", nil})
+	}
+
+	var txBatches, rejectionBatches []int
+	var sunk []models.Transaction
+	result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, testSheet{name: "Statement", rows: rows}), "upload-1", xlsx.Options{
+		Normalize:  xlsx.NormalizeTransactions,
+		RecordSink: func([]models.Record) error { return nil },
+		TransactionSink: func(batch []models.Transaction) error {
+			txBatches = append(txBatches, len(batch))
+			sunk = append(sunk, batch...)
+			return nil
+		},
+		RejectionSink: func(batch []models.RowError) error {
+			rejectionBatches = append(rejectionBatches, len(batch))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+	}
+
+	if len(result.Transactions) != 0 || len(result.Errors) != 0 {
+		t.Errorf("Result kept %d transactions and %d errors, want none", len(result.Transactions), len(result.Errors))
+	}
+	if want := []int{1000, 1000, 500}; !reflect.DeepEqual(txBatches, want) {
+		t.Errorf("Transaction batches = %v, want %v", txBatches, want)
+	}
+	if want := []int{1000, 100}; !reflect.DeepEqual(rejectionBatches, want) {
+		t.Errorf("Rejection batches = %v, want %v", rejectionBatches, want)
+	}
+	for i, tx := range sunk {
+		if tx.Row != i+1 || tx.BalanceBreak != (i == 1499) {
+			t.Fatalf("Transaction %d has Row %d and BalanceBreak %v", i, tx.Row, tx.BalanceBreak)
+		}
+	}
+	if rec := result.Sheets[0].Reconciliation; rec == nil || rec.BreakCount != 1 {
+		t.Errorf("Reconciliation = %+v, want one break", rec)
+	}
+}
+
+func TestParser_ParseWithOptions_HeaderPolicies(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	rows := [][]interface{}{
+		{"Date", "Amount", nil, "Amount", "Amount_2"},
+		{"2024-01-02", 10, "note", 20, "x", "overflow"},
+	}
+
+	tests := []struct {
+		name          string
+		opts          xlsx.Options
+		wantData      map[string]interface{}
+		wantConflicts []models.HeaderConflict
+		wantErr       error
+	}{
+		{
+			name: "suffix by default",
+			wantData: map[string]interface{}{
+				"Transaction Index": 1, "Date": "2024-01-02", "Amount": int64(10), "Amount_2": "x", "Amount_3": int64(20),
+			},
+			wantConflicts: []models.HeaderConflict{
+				{Header: "Amount", Cells: []string{"B1", "D1"}, Resolution: xlsx.ResolutionSuffixed, Names: []string{"Amount", "Amount_3"}},
+			},
+		},
+		{
+			name: "array",
+			opts: xlsx.Options{DuplicateHeaders: xlsx.DuplicatesArray},
+			wantData: map[string]interface{}{
+				"Transaction Index": 1, "Date": "2024-01-02", "Amount": []interface{}{int64(10), int64(20)}, "Amount_2": "x",
+			},
+			wantConflicts: []models.HeaderConflict{
+				{Header: "Amount", Cells: []string{"B1", "D1"}, Resolution: xlsx.ResolutionArray, Names: []string{"Amount"}},
+			},
+		},
+		{
+			name:    "reject",
+			opts:    xlsx.Options{DuplicateHeaders: xlsx.DuplicatesReject},
+			wantErr: xlsx.ErrDuplicateHeaders,
+		},
+		{
+			name: "keep blank headers",
+			opts: xlsx.Options{BlankHeaders: xlsx.BlankKeep},
+			wantData: map[string]interface{}{
+				"Transaction Index": 1, "Date": "2024-01-02", "Amount": int64(10), "column_C": "note",
+				"Amount_2": "x", "Amount_3": int64(20), "column_F": "overflow",
+			},
+			wantConflicts: []models.HeaderConflict{
+				{Header: "Amount", Cells: []string{"B1", "D1"}, Resolution: xlsx.ResolutionSuffixed, Names: []string{"Amount", "Amount_3"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, testSheet{name: "Sheet1", rows: rows}), "upload-1", tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseWithOptions() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if got := result.Records[0].Data; !reflect.DeepEqual(got, tt.wantData) {
+				t.Errorf("Data = %#v, want %#v", got, tt.wantData)
+			}
+			if got := result.Sheets[0].HeaderConflicts; !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("HeaderConflicts = %+v, want %+v", got, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_GeneratedHeaderClash(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	// The cell in column D has no header, and its generated name is taken
+	// by the header of column B.
+	rows := [][]interface{}{
+		{"Date", "column_D"},
+		{"2024-01-02", "real", nil, "generated"},
+	}
+
+	tests := []struct {
+		name          string
+		opts          xlsx.Options
+		wantData      map[string]interface{}
+		wantConflicts []models.HeaderConflict
+		wantErr       error
+	}{
+		{
+			name: "suffix",
+			opts: xlsx.Options{BlankHeaders: xlsx.BlankKeep},
+			wantData: map[string]interface{}{
+				"Transaction Index": 1, "Date": "2024-01-02", "column_D": "real", "column_C": nil, "column_D_2": "generated",
+			},
+			wantConflicts: []models.HeaderConflict{
+				{Header: "column_D", Cells: []string{"B1", "D1"}, Resolution: xlsx.ResolutionSuffixed, Names: []string{"column_D", "column_D_2"}},
+			},
+		},
+		{
+			name: "array",
+			opts: xlsx.Options{BlankHeaders: xlsx.BlankKeep, DuplicateHeaders: xlsx.DuplicatesArray},
+			wantData: map[string]interface{}{
+				"Transaction Index": 1, "Date": "2024-01-02", "column_D": []interface{}{"real", "generated"}, "column_C": nil,
+			},
+			wantConflicts: []models.HeaderConflict{
+				{Header: "column_D", Cells: []string{"B1", "D1"}, Resolution: xlsx.ResolutionArray, Names: []string{"column_D"}},
+			},
+		},
+		{
+			name:    "reject",
+			opts:    xlsx.Options{BlankHeaders: xlsx.BlankKeep, DuplicateHeaders: xlsx.DuplicatesReject},
+			wantErr: xlsx.ErrDuplicateHeaders,
+		},
+		{
+			name:     "blank headers dropped",
+			wantData: map[string]interface{}{"Transaction Index": 1, "Date": "2024-01-02", "column_D": "real"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, testSheet{name: "Sheet1", rows: rows}), "upload-1", tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseWithOptions() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if got := result.Records[0].Data; !reflect.DeepEqual(got, tt.wantData) {
+				t.Errorf("Data = %#v, want %#v", got, tt.wantData)
+			}
+			if got := result.Sheets[0].HeaderConflicts; !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("HeaderConflicts = %+v, want %+v", got, tt.wantConflicts)
+			}
+		})
+	}
+
+	// A profile can map the generated name onto a header's field.
+	profile := &mapping.Profile{Name: "notes", Fields: []mapping.Field{{Name: "Note", Aliases: []string{"Remark", "column_D"}}}}
+	mustNoError(t, profile.Compile())
+	workbook := buildWorkbook(t, testSheet{name: "Sheet1", rows: [][]interface{}{{"Date", "Remark"}, {"2024-01-02", "real", nil, "generated"}}})
+	result, err := parser.ParseWithOptions(ctx, workbook, "upload-1", xlsx.Options{BlankHeaders: xlsx.BlankKeep, Profile: profile})
+	mustNoError(t, err)
+	if got := result.Records[0].Data["Note_2"]; got != "generated" {
+		t.Errorf("Data = %#v, want the generated column as Note_2", result.Records[0].Data)
+	}
+}
+
+func TestParseHeaderPolicies(t *testing.T) {
+	if got, err := xlsx.ParseDuplicatePolicy("Array"); err != nil || got != xlsx.DuplicatesArray {
+		t.Errorf("ParseDuplicatePolicy(Array) = %v, %v", got, err)
+	}
+	if _, err := xlsx.ParseDuplicatePolicy("merge"); !errors.Is(err, xlsx.ErrInvalidDuplicatePolicy) {
+		t.Errorf("ParseDuplicatePolicy(merge) error = %v, want ErrInvalidDuplicatePolicy", err)
+	}
+	if got, err := xlsx.ParseBlankPolicy("keep"); err != nil || got != xlsx.BlankKeep {
+		t.Errorf("ParseBlankPolicy(keep) = %v, %v", got, err)
+	}
+	if _, err := xlsx.ParseBlankPolicy("name"); !errors.Is(err, xlsx.ErrInvalidBlankPolicy) {
+		t.Errorf("ParseBlankPolicy(name) error = %v, want ErrInvalidBlankPolicy", err)
+	}
+	if got, err := xlsx.ParseMergePolicy("fill"); err != nil || got != xlsx.MergedFill {
+		t.Errorf("ParseMergePolicy(fill) = %v, %v", got, err)
+	}
+	if _, err := xlsx.ParseMergePolicy("unmerge"); !errors.Is(err, xlsx.ErrInvalidMergePolicy) {
+		t.Errorf("ParseMergePolicy(unmerge) error = %v, want ErrInvalidMergePolicy", err)
+	}
+	if got, err := xlsx.ParseHeaderRows("2"); err != nil || got != 2 {
+		t.Errorf("ParseHeaderRows(2) = %v, %v", got, err)
+	}
+	if _, err := xlsx.ParseHeaderRows("11"); !errors.Is(err, xlsx.ErrInvalidHeaderRows) {
+		t.Errorf("ParseHeaderRows(11) error = %v, want ErrInvalidHeaderRows", err)
+	}
+	if got, err := xlsx.ParseHiddenPolicy("tag"); err != nil || got != xlsx.HiddenTag {
+		t.Errorf("ParseHiddenPolicy(tag) = %v, %v", got, err)
+	}
+	if _, err := xlsx.ParseHiddenPolicy("skip"); !errors.Is(err, xlsx.ErrInvalidHiddenPolicy) {
+		t.Errorf("ParseHiddenPolicy(skip) error = %v, want ErrInvalidHiddenPolicy", err)
+	}
+	if got, err := xlsx.ParseFormulaMode("recalc"); err != nil || got != xlsx.FormulasRecalc {
+		t.Errorf("ParseFormulaMode(recalc) = %v, %v", got, err)
+	}
+	if _, err := xlsx.ParseFormulaMode("evaluate"); !errors.Is(err, xlsx.ErrInvalidFormulaMode) {
+		t.Errorf("ParseFormulaMode(evaluate) error = %v, want ErrInvalidFormulaMode", err)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/rs/zerolog"
+)
+
+// statementSheet is a bank statement export with its account details above
+// the transactions.
+var statementSheet = testSheet{name: "Statement", rows: [][]interface{}{
+	{"Statement of Account"},
+	{"Account Name: Jane Doe"},
+	{"Account Number", "12345678"},
+	{"Currency:", "EUR"},
+	{"Statement Period: 2024-01-01 to 2024-01-31"},
+	{"Opened at 10:30"},
+	{},
+	{"Date", "Description", "Amount", "Balance"},
+	{"2024-01-02", "Coffee", -3.5, 96.5},
+	{"2024-01-03", "Salary", 1000, 1096.5},
+}}
+
+func TestParser_ParseWithOptions_Preamble(t *testing.T) {
+	profile := &mapping.Profile{
+		Name:      "bank-de",
+		SnakeCase: true,
+		Preamble: []mapping.PreambleField{
+			{Name: "accountNumber", Patterns: []string{`konto(nummer)?`}},
+			{Name: "holder", Patterns: []string{`kontoinhaber`, `account\s*name`}},
+		},
+	}
+	if err := profile.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sheet     testSheet
+		profile   *mapping.Profile
+		headerRow int
+		want      map[string]string
+	}{
+		{
+			name:  "default labels",
+			sheet: statementSheet,
+			want: map[string]string{
+				"accountName":     "Jane Doe",
+				"accountNumber":   "12345678",
+				"currency":        "EUR",
+				"statementPeriod": "2024-01-01 to 2024-01-31",
+			},
+		},
+		{
+			name: "profile labels",
+			sheet: testSheet{name: "Umsätze", rows: [][]interface{}{
+				{"Kontoinhaber:", "Max Mustermann"},
+				{"Kontonummer: DE00 1234"},
+				{"Währung", "EUR"},
+				{},
+				{"Buchungstag", "Verwendungszweck", "Betrag"},
+				{"02.01.2024", "Kaffee", -3.5},
+			}},
+			profile: profile,
+			want: map[string]string{
+				"holder":        "Max Mustermann",
+				"accountNumber": "DE00 1234",
+				"währung":       "EUR",
+			},
+		},
+		{
+			name:      "explicit header row",
+			sheet:     statementSheet,
+			headerRow: 8,
+			want: map[string]string{
+				"accountName":     "Jane Doe",
+				"accountNumber":   "12345678",
+				"currency":        "EUR",
+				"statementPeriod": "2024-01-01 to 2024-01-31",
+			},
+		},
+		{
+			name:    "profile takes over a default name",
+			sheet:   statementSheet,
+			profile: profile,
+			want: map[string]string{
+				"holder":          "Jane Doe",
+				"accountNumber":   "12345678",
+				"currency":        "EUR",
+				"statementPeriod": "2024-01-01 to 2024-01-31",
+			},
+		},
+		{
+			name:  "no preamble",
+			sheet: testSheet{name: "Sheet1", rows: [][]interface{}{{"Date", "Amount"}, {"2024-01-02", 1}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), buildWorkbook(t, tt.sheet), "upload-1", xlsx.Options{Profile: tt.profile, HeaderRow: tt.headerRow})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if got := result.Sheets[0].Metadata; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sheet metadata = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(result.Metadata, tt.want) {
+				t.Errorf("Metadata = %v, want %v", result.Metadata, tt.want)
+			}
+			if len(result.Records) == 0 {
+				t.Errorf("Got no records below the preamble")
+			}
+		})
+	}
+}
+
+func TestUploadsHandler_Get(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	uploadsHandler := handlers.NewUploadsHandler(store, &logger)
+
+	w := httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "statement.xlsx", buildWorkbook(t, statementSheet).Bytes(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	var response models.UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Metadata["accountNumber"] != "12345678" {
+		t.Errorf("Upload response metadata = %v, want accountNumber 12345678", response.Metadata)
+	}
+
+	tests := []struct {
+		name           string
+		uploadID       string
+		expectedStatus int
+	}{
+		{name: "found", uploadID: response.UploadID, expectedStatus: http.StatusOK},
+		{name: "unknown upload", uploadID: "missing", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withURLParam(httptest.NewRequest(http.MethodGet, "/v1/uploads/"+tt.uploadID, nil), "id", tt.uploadID)
+			w := httptest.NewRecorder()
+			uploadsHandler.Get(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Status = %d, want %d, body %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var upload models.Upload
+			if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if upload.Filename != "statement.xlsx" || upload.RowsAccepted != 2 {
+				t.Errorf("Upload = %+v, want statement.xlsx with 2 rows accepted", upload)
+			}
+			if !reflect.DeepEqual(upload.Metadata, response.Metadata) {
+				t.Errorf("Upload metadata = %v, want %v", upload.Metadata, response.Metadata)
+			}
+		})
+	}
+}
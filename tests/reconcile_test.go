@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/ledger"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/rs/zerolog"
+)
+
+// statementRows builds transactions from amount and balance pairs; a nil
+// balance leaves it out.
+func statementRows(entries ...[2]*float64) []models.Transaction {
+	transactions := make([]models.Transaction, len(entries))
+	for i, entry := range entries {
+		tx := models.Transaction{Row: i + 1, SourceRow: i + 2, Amount: *entry[0], Balance: entry[1]}
+		if tx.Amount < 0 {
+			tx.Debit = -tx.Amount
+		} else {
+			tx.Credit = tx.Amount
+		}
+		transactions[i] = tx
+	}
+	return transactions
+}
+
+func entry(amount float64, balance *float64) [2]*float64 {
+	return [2]*float64{&amount, balance}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []models.Transaction
+		opening      *float64
+		closing      *float64
+		wantStatus   string
+		wantOrder    string
+		wantOpening  *float64
+		wantClosing  *float64
+		wantBreaks   []models.BalanceBreak
+		wantFlagged  []bool
+		wantChecked  int
+		wantClosDiff float64
+	}{
+		{
+			name:         "reconciled",
+			transactions: statementRows(entry(-3.5, float64Ptr(96.5)), entry(1000, float64Ptr(1096.5)), entry(-0.1, float64Ptr(1096.4))),
+			wantStatus:   ledger.StatusReconciled,
+			wantOrder:    "ascending",
+			wantOpening:  float64Ptr(100),
+			wantClosing:  float64Ptr(1096.4),
+			wantFlagged:  []bool{false, false, false},
+			wantChecked:  2,
+		},
+		{
+			name:         "newest first",
+			transactions: statementRows(entry(-0.1, float64Ptr(1096.4)), entry(1000, float64Ptr(1096.5)), entry(-3.5, float64Ptr(96.5))),
+			wantStatus:   ledger.StatusReconciled,
+			wantOrder:    "descending",
+			wantOpening:  float64Ptr(100),
+			wantClosing:  float64Ptr(1096.4),
+			wantFlagged:  []bool{false, false, false},
+			wantChecked:  2,
+		},
+		{
+			name:         "break resumes from the balance found",
+			transactions: statementRows(entry(-3.5, float64Ptr(96.5)), entry(1000, float64Ptr(1090)), entry(-10, float64Ptr(1080))),
+			wantStatus:   ledger.StatusBroken,
+			wantOrder:    "ascending",
+			wantOpening:  float64Ptr(100),
+			wantClosing:  float64Ptr(1080),
+			wantBreaks:   []models.BalanceBreak{{Row: 2, SourceRow: 3, Expected: 1096.5, Actual: 1090, Difference: -6.5}},
+			wantFlagged:  []bool{false, true, false},
+			wantChecked:  2,
+		},
+		{
+			name:         "newest first with a break",
+			transactions: statementRows(entry(-0.1, float64Ptr(1089.9)), entry(1000, float64Ptr(1090)), entry(-3.5, float64Ptr(96.5))),
+			wantStatus:   ledger.StatusBroken,
+			wantOrder:    "descending",
+			wantOpening:  float64Ptr(100),
+			wantClosing:  float64Ptr(1089.9),
+			wantBreaks:   []models.BalanceBreak{{Row: 2, SourceRow: 3, Expected: 1096.5, Actual: 1090, Difference: -6.5}},
+			wantFlagged:  []bool{false, true, false},
+			wantChecked:  2,
+		},
+		{
+			name:         "rows without a balance",
+			transactions: statementRows(entry(-5, nil), entry(-3.5, float64Ptr(91.5)), entry(20, nil), entry(10, float64Ptr(121.5))),
+			wantStatus:   ledger.StatusReconciled,
+			wantOrder:    "ascending",
+			wantOpening:  float64Ptr(100),
+			wantClosing:  float64Ptr(121.5),
+			wantFlagged:  []bool{false, false, false, false},
+			wantChecked:  1,
+		},
+		{
+			name:         "stated opening balance",
+			transactions: statementRows(entry(-3.5, float64Ptr(96.5))),
+			opening:      float64Ptr(90),
+			wantStatus:   ledger.StatusBroken,
+			wantOrder:    "ascending",
+			wantOpening:  float64Ptr(90),
+			wantClosing:  float64Ptr(96.5),
+			wantBreaks:   []models.BalanceBreak{{Row: 1, SourceRow: 2, Expected: 86.5, Actual: 96.5, Difference: 10}},
+			wantFlagged:  []bool{true},
+			wantChecked:  1,
+		},
+		{
+			name:         "stated closing balance",
+			transactions: statementRows(entry(-3.5, float64Ptr(96.5)), entry(1000, float64Ptr(1096.5))),
+			closing:      float64Ptr(1100),
+			wantStatus:   ledger.StatusBroken,
+			wantOrder:    "ascending",
+			wantOpening:  float64Ptr(100),
+			wantClosing:  float64Ptr(1096.5),
+			wantFlagged:  []bool{false, false},
+			wantChecked:  1,
+			wantClosDiff: 3.5,
+		},
+		{
+			name:         "no balances",
+			transactions: statementRows(entry(-3.5, nil), entry(1000, nil)),
+			wantStatus:   ledger.StatusUnchecked,
+			wantOrder:    "ascending",
+			wantFlagged:  []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ledger.Reconcile(tt.transactions, tt.opening, tt.closing)
+
+			if rec.Status != tt.wantStatus || rec.Order != tt.wantOrder || rec.Checked != tt.wantChecked {
+				t.Errorf("Status, Order, Checked = %s, %s, %d, want %s, %s, %d", rec.Status, rec.Order, rec.Checked, tt.wantStatus, tt.wantOrder, tt.wantChecked)
+			}
+			if !reflect.DeepEqual(rec.OpeningBalance, tt.wantOpening) || !reflect.DeepEqual(rec.ClosingBalance, tt.wantClosing) {
+				t.Errorf("Opening, Closing = %v, %v, want %v, %v", rec.OpeningBalance, rec.ClosingBalance, tt.wantOpening, tt.wantClosing)
+			}
+			if !reflect.DeepEqual(rec.Breaks, tt.wantBreaks) || rec.BreakCount != len(tt.wantBreaks) {
+				t.Errorf("Breaks = %d %+v, want %+v", rec.BreakCount, rec.Breaks, tt.wantBreaks)
+			}
+			if rec.ClosingDifference != tt.wantClosDiff {
+				t.Errorf("ClosingDifference = %v, want %v", rec.ClosingDifference, tt.wantClosDiff)
+			}
+			for i, want := range tt.wantFlagged {
+				if got := tt.transactions[i].BalanceBreak; got != want {
+					t.Errorf("Transaction %d BalanceBreak = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_Reconcile(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	broken := testSheet{name: "Checking", rows: [][]interface{}{
+		{"Opening Balance:", 100},
+		{"Closing Balance:", 1086.5},
+		{},
+		{"Date", "Description", "Amount", "Balance"},
+		{"2024-01-02", "Coffee", -3.5, 96.5},
+		{"2024-01-03", "Salary", 1000, 1090},
+		{"2024-01-04", "Fee", -3.5, 1086.5},
+	}}
+	reconciled := testSheet{name: "Savings", rows: [][]interface{}{
+		{"Date", "Description", "Amount", "Balance"},
+		{"2024-01-02", "Interest", 1.25, 501.25},
+		{"2024-01-03", "Transfer", 100, 601.25},
+	}}
+
+	result, err := parser.ParseWithOptions(ctx, buildWorkbook(t, broken, reconciled), "upload-1", xlsx.Options{
+		Sheets:    xlsx.SheetSelector{All: true},
+		Normalize: xlsx.NormalizeTransactions,
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+	}
+	if result.Reconciliation != ledger.StatusBroken {
+		t.Errorf("Reconciliation = %q, want broken", result.Reconciliation)
+	}
+	first, second := result.Sheets[0].Reconciliation, result.Sheets[1].Reconciliation
+	if first == nil || first.Status != ledger.StatusBroken || first.BreakCount != 1 || first.Breaks[0].SourceRow != 6 {
+		t.Errorf("Checking reconciliation = %+v, want one break at row 6", first)
+	}
+	if second == nil || second.Status != ledger.StatusReconciled {
+		t.Errorf("Savings reconciliation = %+v, want reconciled", second)
+	}
+	if !result.Transactions[1].BalanceBreak || result.Transactions[0].BalanceBreak {
+		t.Errorf("Only the second transaction should be flagged")
+	}
+
+	_, err = parser.ParseWithOptions(ctx, buildWorkbook(t, broken), "upload-1", xlsx.Options{
+		Normalize: xlsx.NormalizeTransactions,
+		Reconcile: xlsx.ReconcileReject,
+	})
+	if !errors.Is(err, ledger.ErrNotReconciled) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrNotReconciled", err)
+	}
+}
+
+func TestUploadHandler_Reconcile(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	handler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+
+	content := []byte("Date,Description,Amount,Balance\n2024-01-02,Coffee,-3.50,96.50\n2024-01-03,Salary,1000,1090.00\n")
+
+	tests := []struct {
+		name           string
+		fields         map[string]string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "reported", fields: map[string]string{"normalize": "transactions"}, expectedStatus: http.StatusOK},
+		{name: "rejected", fields: map[string]string{"normalize": "transactions", "reconcile": "reject"}, expectedStatus: http.StatusUnprocessableEntity, expectedCode: "not_reconciled"},
+		{name: "invalid policy", fields: map[string]string{"normalize": "transactions", "reconcile": "ignore"}, expectedStatus: http.StatusBadRequest, expectedCode: "invalid_parameter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.Clear()
+			w := httptest.NewRecorder()
+			handler.Handle(w, newUploadRequest(t, "statement.csv", content, tt.fields))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Status = %d, want %d, body %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				var response models.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Code != tt.expectedCode {
+					t.Errorf("Code = %q, want %q", response.Code, tt.expectedCode)
+				}
+				if count, _ := store.Count(); count != 0 {
+					t.Errorf("Stored %d records for a refused upload", count)
+				}
+				return
+			}
+
+			var response models.UploadResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Reconciliation != ledger.StatusBroken {
+				t.Errorf("Reconciliation = %q, want broken", response.Reconciliation)
+			}
+		})
+	}
+}
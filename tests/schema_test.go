@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestSchema_Compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  schema.Schema
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			schema: schema.Schema{Name: "bank", Columns: []schema.Column{{Name: "Amount", Type: schema.TypeNumber, Min: float(0), Max: float(10)}}},
+		},
+		{
+			name:    "missing name",
+			schema:  schema.Schema{Columns: []schema.Column{{Name: "Amount"}}},
+			wantErr: true,
+		},
+		{
+			name:    "no columns",
+			schema:  schema.Schema{Name: "bank"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate column",
+			schema:  schema.Schema{Name: "bank", Columns: []schema.Column{{Name: "Amount"}, {Name: "Amount"}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			schema:  schema.Schema{Name: "bank", Columns: []schema.Column{{Name: "Amount", Type: "money"}}},
+			wantErr: true,
+		},
+		{
+			name:    "bad pattern",
+			schema:  schema.Schema{Name: "bank", Columns: []schema.Column{{Name: "Ref", Pattern: "("}}},
+			wantErr: true,
+		},
+		{
+			name:    "min above max",
+			schema:  schema.Schema{Name: "bank", Columns: []schema.Column{{Name: "Amount", Min: float(5), Max: float(1)}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, schema.ErrInvalidSchema) {
+				t.Errorf("Compile() error = %v, want ErrInvalidSchema", err)
+			}
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s := schema.Schema{
+		Name: "bank",
+		Columns: []schema.Column{
+			{Name: "Date", Required: true, Type: schema.TypeDate},
+			{Name: "Amount", Type: schema.TypeNumber, Min: float(-1000), Max: float(1000)},
+			{Name: "Count", Type: schema.TypeInteger},
+			{Name: "Cleared", Type: schema.TypeBoolean},
+			{Name: "Ref", Pattern: `^TX-\d+$`},
+			{Name: "Currency", Enum: []string{"EUR", "USD"}},
+			{Name: "Note", Max: float(5)},
+		},
+	}
+	if err := s.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		wantCode string
+	}{
+		{name: "valid typed", data: map[string]interface{}{"Date": "2024-01-31", "Amount": 12.5, "Count": int64(3), "Cleared": true, "Ref": "TX-1", "Currency": "EUR"}},
+		{name: "valid strings", data: map[string]interface{}{"Date": "2024-01-31T10:00:00", "Amount": "12.5", "Count": "3", "Cleared": "false"}},
+		{name: "missing required", data: map[string]interface{}{"Date": "  "}, wantCode: schema.CodeRequired},
+		{name: "not a date", data: map[string]interface{}{"Date": "31/01/2024"}, wantCode: schema.CodeInvalidType},
+		{name: "not a number", data: map[string]interface{}{"Date": "2024-01-31", "Amount": "twelve"}, wantCode: schema.CodeInvalidType},
+		{name: "not a finite number", data: map[string]interface{}{"Date": "2024-01-31", "Amount": "NaN"}, wantCode: schema.CodeInvalidType},
+		{name: "infinite number", data: map[string]interface{}{"Date": "2024-01-31", "Amount": "-Infinity"}, wantCode: schema.CodeInvalidType},
+		{name: "not an integer", data: map[string]interface{}{"Date": "2024-01-31", "Count": 1.5}, wantCode: schema.CodeInvalidType},
+		{name: "not a boolean", data: map[string]interface{}{"Date": "2024-01-31", "Cleared": "maybe"}, wantCode: schema.CodeInvalidType},
+		{name: "below min", data: map[string]interface{}{"Date": "2024-01-31", "Amount": int64(-5000)}, wantCode: schema.CodeBelowMin},
+		{name: "above max", data: map[string]interface{}{"Date": "2024-01-31", "Amount": 1000.01}, wantCode: schema.CodeAboveMax},
+		{name: "pattern", data: map[string]interface{}{"Date": "2024-01-31", "Ref": "TX-A"}, wantCode: schema.CodePatternMismatch},
+		{name: "enum", data: map[string]interface{}{"Date": "2024-01-31", "Currency": "GBP"}, wantCode: schema.CodeNotInEnum},
+		{name: "string length", data: map[string]interface{}{"Date": "2024-01-31", "Note": "too long"}, wantCode: schema.CodeAboveMax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := s.Validate(tt.data)
+			if tt.wantCode == "" {
+				if len(violations) != 0 {
+					t.Errorf("Validate() = %+v, want no violations", violations)
+				}
+				return
+			}
+			if len(violations) != 1 || violations[0].Code != tt.wantCode {
+				t.Errorf("Validate() = %+v, want a single %s violation", violations, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_Schema(t *testing.T) {
+	s := &schema.Schema{
+		Name: "bank",
+		Columns: []schema.Column{
+			{Name: "Ref", Required: true, Unique: true},
+			{Name: "Amount", Type: schema.TypeNumber, Min: float(0)},
+		},
+	}
+	if err := s.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	workbook := buildWorkbook(t,
+		testSheet{name: "Jan", rows: [][]interface{}{
+			{"Ref", "Amount"},
+			{"A", 10},
+			{"B", -1},
+			{"A", 5},
+			{nil, 3},
+		}},
+		testSheet{name: "Feb", rows: [][]interface{}{
+			{"Ref", "Amount"},
+			{"B", 1},
+			{"C", 2},
+			{"C", 4},
+		}},
+	)
+
+	result, err := xlsx.NewParser(4).ParseWithOptions(context.Background(), workbook, "upload-1", xlsx.Options{
+		Sheets: xlsx.SheetSelector{All: true},
+		Schema: s,
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+
+	if result.RowsAccepted != 3 || result.RowsRejected != 4 {
+		t.Errorf("accepted/rejected = %d/%d, want 3/4", result.RowsAccepted, result.RowsRejected)
+	}
+
+	want := []struct {
+		sheet string
+		cell  string
+		code  string
+	}{
+		{"Jan", "B3", schema.CodeBelowMin},
+		{"Jan", "A4", schema.CodeDuplicateValue},
+		{"Jan", "A5", schema.CodeRequired},
+		{"Feb", "A4", schema.CodeDuplicateValue},
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("Got %d errors, want %d: %+v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, w := range want {
+		got := result.Errors[i]
+		if got.Sheet != w.sheet || got.Cell != w.cell || got.Code != w.code {
+			t.Errorf("Errors[%d] = %+v, want %s %s %s", i, got, w.sheet, w.cell, w.code)
+		}
+	}
+
+	// Jan's B row was rejected, so its value does not count as seen.
+	if got := result.Records[1]; got.Sheet != "Feb" || got.Data["Ref"] != "B" {
+		t.Errorf("Records[1] = %+v, want Feb row with Ref B", got)
+	}
+
+	missing := &schema.Schema{Name: "strict", Columns: []schema.Column{{Name: "IBAN", Required: true}}}
+	if err := missing.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	_, err = xlsx.NewParser(2).ParseWithOptions(context.Background(), buildWorkbook(t, testSheet{name: "Jan", rows: [][]interface{}{{"Ref"}, {"A"}}}), "upload-2", xlsx.Options{Schema: missing})
+	if !errors.Is(err, xlsx.ErrSchemaMismatch) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrSchemaMismatch", err)
+	}
+}
@@ -1,9 +1,19 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/joelovien/go-xlsx-api/internal/config"
 	"github.com/joelovien/go-xlsx-api/internal/models"
 	"github.com/joelovien/go-xlsx-api/internal/storage"
 )
@@ -50,7 +60,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 			}
 
 			// Verify count
-			if count := s.Count(); count != len(tt.records) {
+			if count, err := s.Count(); err != nil || count != len(tt.records) {
 				t.Errorf("Count() = %v, want %v", count, len(tt.records))
 			}
 		})
@@ -173,10 +183,565 @@ func TestMemoryStorage_GetByUploadID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := s.GetByUploadID(tt.uploadID)
+			got, err := s.GetByUploadID(tt.uploadID)
+			if err != nil {
+				t.Fatalf("GetByUploadID() error = %v", err)
+			}
 			if len(got) != tt.wantCount {
 				t.Errorf("GetByUploadID() returned %v records, want %v", len(got), tt.wantCount)
 			}
 		})
 	}
 }
+
+func TestMemoryStorage_Rejections(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	rejections := []models.RowError{
+		{Sheet: "Sheet1", Row: 3, Code: "empty_row", Message: "empty row"},
+		{Sheet: "Sheet1", Row: 7, Code: "empty_row", Message: "empty row"},
+		{Sheet: "Sheet1", Row: 9, Code: "empty_row", Message: "empty row"},
+	}
+	mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: models.Upload{ID: "upload-1"}, Rejections: rejections}))
+	mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: models.Upload{ID: "upload-2"}}))
+
+	tests := []struct {
+		name      string
+		uploadID  string
+		limit     int
+		offset    int
+		wantCount int
+		wantTotal int
+		wantErr   error
+	}{
+		{name: "first page", uploadID: "upload-1", limit: 2, offset: 0, wantCount: 2, wantTotal: 3},
+		{name: "last page", uploadID: "upload-1", limit: 2, offset: 2, wantCount: 1, wantTotal: 3},
+		{name: "upload without rejections", uploadID: "upload-2", limit: 10, wantCount: 0, wantTotal: 0},
+		{name: "unknown upload", uploadID: "upload-3", limit: 10, wantErr: storage.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := s.ListRejections(tt.uploadID, tt.limit, tt.offset)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListRejections() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantCount {
+				t.Errorf("ListRejections() returned %d rejections, want %d", len(got), tt.wantCount)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("ListRejections() total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		backend  string
+		wantType string
+		wantErr  error
+	}{
+		{backend: ""},
+		{backend: "memory"},
+		{backend: "Memory"},
+		{backend: "file", wantType: "*storage.FileStorage"},
+		{backend: "mongodb", wantErr: storage.ErrUnknownBackend},
+	}
+
+	for _, tt := range tests {
+		store, err := storage.Open(&config.Config{StorageBackend: tt.backend, StoragePath: t.TempDir()})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Open(%q) error = %v, want %v", tt.backend, err, tt.wantErr)
+			continue
+		}
+		if err == nil {
+			want := tt.wantType
+			if want == "" {
+				want = "*storage.MemoryStorage"
+			}
+			if got := fmt.Sprintf("%T", store); got != want {
+				t.Errorf("Open(%q) = %s, want %s", tt.backend, got, want)
+			}
+			store.Close()
+		}
+	}
+}
+
+func TestFileStorage_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+	records := []models.Record{
+		{ID: "1", UploadID: "upload-1", Sheet: "Sheet1", Row: 1, Data: map[string]interface{}{"name": "John", "age": 30.0}, CreatedAt: created},
+		{ID: "2", UploadID: "upload-1", Sheet: "Sheet1", Row: 2, Data: map[string]interface{}{"name": "Jane", "age": 25.0}, CreatedAt: created},
+	}
+	balance := 96.5
+	writer, err := s.BeginUpload(context.Background(), "upload-1")
+	mustNoError(t, err)
+	mustNoError(t, writer.WriteRecords(records[:1]))
+	mustNoError(t, writer.Commit(storage.UploadBatch{
+		Upload:       models.Upload{ID: "upload-1", Filename: "people.xlsx", RowsAccepted: 2, RowsRejected: 1, CreatedAt: created},
+		Records:      records[1:],
+		Transactions: []models.Transaction{{ID: "tx-1", UploadID: "upload-1", Amount: -3.5, Balance: &balance}},
+		Rejections:   []models.RowError{{Sheet: "Sheet1", Row: 4, Code: "empty_row"}},
+	}))
+	mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: models.Upload{ID: "upload-2", CreatedAt: created}}))
+	mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{
+		Upload:  models.Upload{ID: "upload-3", Filename: "wrong.xlsx", RowsAccepted: 1, CreatedAt: created},
+		Records: []models.Record{{ID: "3", UploadID: "upload-3", Data: map[string]interface{}{"name": "Bob"}, CreatedAt: created}},
+	}))
+	if _, err := s.RollbackUpload("upload-3", created); err != nil {
+		t.Fatalf("RollbackUpload() error = %v", err)
+	}
+	// An upload a crash cuts short leaves staged records behind.
+	writer, err = s.BeginUpload(context.Background(), "upload-4")
+	mustNoError(t, err)
+	mustNoError(t, writer.WriteRecords([]models.Record{{ID: "4", UploadID: "upload-4", Data: map[string]interface{}{}, CreatedAt: created}}))
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: models.Upload{ID: "upload-5"}}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("CommitUpload() after Close error = %v, want os.ErrClosed", err)
+	}
+	if _, _, err := s.List(10, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("List() after Close error = %v, want os.ErrClosed", err)
+	}
+
+	s, err = storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage() reopen error = %v", err)
+	}
+	defer s.Close()
+
+	got, total, err := s.List(10, 0)
+	if err != nil || total != 2 || !reflect.DeepEqual(got, records) {
+		t.Errorf("List() = %+v, %d, %v, want %+v", got, total, err, records)
+	}
+	if got, err := s.GetByUploadID("upload-4"); err != nil || len(got) != 0 {
+		t.Errorf("GetByUploadID(uncommitted) = %d records, %v, want none", len(got), err)
+	}
+	if rejections, total, err := s.ListRejections("upload-1", 10, 0); err != nil || total != 1 || rejections[0].Row != 4 {
+		t.Errorf("ListRejections(upload-1) = %+v, %d, %v", rejections, total, err)
+	}
+	if _, _, err := s.ListRejections("upload-2", 10, 0); err != nil {
+		t.Errorf("ListRejections(upload-2) error = %v, want an empty report", err)
+	}
+	if transactions, _, err := s.ListTransactions("upload-1", 10, 0); err != nil || len(transactions) != 1 || *transactions[0].Balance != balance {
+		t.Errorf("ListTransactions() = %+v, %v", transactions, err)
+	}
+	upload, err := s.GetUpload("upload-1")
+	if err != nil || upload.RowsRejected != 1 || !upload.CreatedAt.Equal(created) || upload.Status != models.UploadStatusCommitted {
+		t.Errorf("GetUpload() = %+v, %v, want it committed", upload, err)
+	}
+	if upload, err := s.GetUpload("upload-3"); err != nil || upload.Status != models.UploadStatusRolledBack {
+		t.Errorf("GetUpload(upload-3) = %+v, %v, want it rolled back", upload, err)
+	}
+}
+
+func TestFileStorage_PagesAcrossEntries(t *testing.T) {
+	s, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+	defer s.Close()
+
+	// Nine records over three stage entries and a commit entry, so that
+	// pages start and end inside entries.
+	writer, err := s.BeginUpload(context.Background(), "upload-1")
+	mustNoError(t, err)
+	for batch := 0; batch < 3; batch++ {
+		records := make([]models.Record, 3)
+		for i := range records {
+			records[i] = models.Record{ID: fmt.Sprint(batch*3 + i + 1), UploadID: "upload-1", Data: map[string]interface{}{}}
+		}
+		mustNoError(t, writer.WriteRecords(records))
+	}
+	mustNoError(t, writer.Commit(storage.UploadBatch{
+		Upload:  models.Upload{ID: "upload-1"},
+		Records: []models.Record{{ID: "10", UploadID: "upload-1", Data: map[string]interface{}{}}},
+	}))
+
+	tests := []struct {
+		name    string
+		limit   int
+		offset  int
+		wantIDs []string
+	}{
+		{name: "first page", limit: 4, offset: 0, wantIDs: []string{"1", "2", "3", "4"}},
+		{name: "inside an entry", limit: 4, offset: 4, wantIDs: []string{"5", "6", "7", "8"}},
+		{name: "into the commit entry", limit: 4, offset: 8, wantIDs: []string{"9", "10"}},
+		{name: "offset beyond total", limit: 4, offset: 12, wantIDs: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := s.List(tt.limit, tt.offset)
+			if err != nil || total != 10 {
+				t.Fatalf("List() total = %d, error = %v, want 10", total, err)
+			}
+			ids := make([]string, 0, len(got))
+			for _, record := range got {
+				ids = append(ids, record.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("List() IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestFileStorage_DamagedLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		damage    func(log []byte) []byte
+		wantErr   error
+		wantCount int
+	}{
+		{
+			name:      "torn last entry",
+			damage:    func(log []byte) []byte { return append(log, `0badc0de {"op":"commit","rec`...) },
+			wantCount: 2,
+		},
+		{
+			name: "corrupt last entry",
+			damage: func(log []byte) []byte {
+				return append(log, "00000000 {\"op\":\"commit\"}\n"...)
+			},
+			wantCount: 2,
+		},
+		{
+			name: "corrupt entry before the end",
+			damage: func(log []byte) []byte {
+				damaged := bytes.Replace(log, []byte("John"), []byte("Joan"), 1)
+				return append(damaged, log...)
+			},
+			wantErr: storage.ErrCorruptLog,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := storage.NewFileStorage(dir)
+			if err != nil {
+				t.Fatalf("NewFileStorage() error = %v", err)
+			}
+			mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{
+				Upload:  models.Upload{ID: "upload-1"},
+				Records: []models.Record{{ID: "1", UploadID: "upload-1", Data: map[string]interface{}{"name": "John"}}},
+			}))
+			mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{
+				Upload:  models.Upload{ID: "upload-2"},
+				Records: []models.Record{{ID: "2", UploadID: "upload-2", Data: map[string]interface{}{"name": "Jane"}}},
+			}))
+			s.Close()
+
+			logs, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+			if len(logs) != 1 {
+				t.Fatalf("Found logs %v, want one", logs)
+			}
+			log, err := os.ReadFile(logs[0])
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if err := os.WriteFile(logs[0], tt.damage(log), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			s, err = storage.NewFileStorage(dir)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewFileStorage() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer s.Close()
+
+			// Writes after a recovered crash must survive the next replay.
+			mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{
+				Upload:  models.Upload{ID: "upload-3"},
+				Records: []models.Record{{ID: "3", UploadID: "upload-3"}},
+			}))
+			s.Close()
+			s, err = storage.NewFileStorage(dir)
+			if err != nil {
+				t.Fatalf("NewFileStorage() reopen error = %v", err)
+			}
+			if count, _ := s.Count(); count != tt.wantCount+1 {
+				t.Errorf("Count() = %d, want %d", count, tt.wantCount+1)
+			}
+		})
+	}
+}
+
+func mustNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error = %v", err)
+	}
+}
+
+// TestStore_Contract runs the same checks against every backend. The
+// postgres backend needs TEST_DATABASE_URL; the checks only look at their
+// own uploads, so a shared database will do.
+func TestStore_Contract(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) storage.Store
+	}{
+		{name: "memory", open: func(t *testing.T) storage.Store { return storage.NewMemoryStorage() }},
+		{name: "file", open: func(t *testing.T) storage.Store {
+			s, err := storage.NewFileStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStorage() error = %v", err)
+			}
+			return s
+		}},
+		{name: "postgres", open: func(t *testing.T) storage.Store {
+			url := os.Getenv("TEST_DATABASE_URL")
+			if url == "" {
+				t.Skip("TEST_DATABASE_URL is not set")
+			}
+			s, err := storage.NewPostgresStorage(context.Background(), url)
+			if err != nil {
+				t.Fatalf("NewPostgresStorage() error = %v", err)
+			}
+			return s
+		}},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			s := backend.open(t)
+			defer s.Close()
+
+			uploadID := uuid.New().String()
+			created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			records := []models.Record{
+				{ID: uuid.New().String(), UploadID: uploadID, Sheet: "Sheet1", Row: 1, SourceRow: 2, SourceRef: "A2:B2", Data: map[string]interface{}{"name": "John", "age": 30.0}, CreatedAt: created},
+				{ID: uuid.New().String(), UploadID: uploadID, Sheet: "Sheet1", Row: 2, SourceRow: 3, SourceRef: "A3:B3", Data: map[string]interface{}{"name": "Jane", "age": nil}, CreatedAt: created,
+					Meta: map[string]models.CellMeta{"name": {Hyperlink: "https://example.com"}}},
+			}
+
+			balance := 96.5
+			transactions := []models.Transaction{
+				{ID: uuid.New().String(), UploadID: uploadID, RecordID: records[0].ID, Amount: -3.5, Debit: 3.5, Balance: &balance, CreatedAt: created},
+				{ID: uuid.New().String(), UploadID: uploadID, RecordID: records[1].ID, Amount: 10, Credit: 10, CreatedAt: created},
+			}
+			rejections := []models.RowError{{Sheet: "Sheet1", Row: 4, Code: "empty_row", Message: "empty row"}, {Sheet: "Sheet1", Row: 5, Code: "empty_row", Message: "empty row"}}
+			upload := models.Upload{ID: uploadID, Filename: "people.xlsx", RowsAccepted: 2, RowsRejected: 2, CreatedAt: created}
+
+			before, err := s.Count()
+			mustNoError(t, err)
+			mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: upload, Records: records, Transactions: transactions, Rejections: rejections}))
+			upload.Status = models.UploadStatusCommitted
+			mustNoError(t, s.Store(nil))
+			if count, err := s.Count(); err != nil || count != before+2 {
+				t.Errorf("Count() = %d, %v, want %d", count, err, before+2)
+			}
+			if got, err := s.GetByUploadID(uploadID); err != nil || !reflect.DeepEqual(got, records) {
+				t.Errorf("GetByUploadID() = %+v, %v, want %+v", got, err, records)
+			}
+			if got, err := s.GetByUploadID(uuid.New().String()); err != nil || got == nil || len(got) != 0 {
+				t.Errorf("GetByUploadID(unknown) = %#v, %v, want an empty slice", got, err)
+			}
+			if count, err := s.CountByUploadID(uploadID); err != nil || count != 2 {
+				t.Errorf("CountByUploadID() = %d, %v, want 2", count, err)
+			}
+			if count, err := s.CountByUploadID(uuid.New().String()); err != nil || count != 0 {
+				t.Errorf("CountByUploadID(unknown) = %d, %v, want 0", count, err)
+			}
+			if got, total, err := s.List(1, before+1); err != nil || total != before+2 || len(got) != 1 || got[0].ID != records[1].ID {
+				t.Errorf("List(1, %d) = %+v, %d, %v, want the second record", before+1, got, total, err)
+			}
+
+			if got, total, err := s.ListRejections(uploadID, 1, 1); err != nil || total != 2 || !reflect.DeepEqual(got, rejections[1:]) {
+				t.Errorf("ListRejections() = %+v, %d, %v", got, total, err)
+			}
+			emptyID := uuid.New().String()
+			mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: models.Upload{ID: emptyID, CreatedAt: created}}))
+			if got, total, err := s.ListRejections(emptyID, 10, 0); err != nil || total != 0 || len(got) != 0 {
+				t.Errorf("ListRejections(no rejections) = %+v, %d, %v", got, total, err)
+			}
+			if _, _, err := s.ListRejections(uuid.New().String(), 10, 0); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("ListRejections(unknown) error = %v, want ErrNotFound", err)
+			}
+
+			if got, err := s.GetUpload(uploadID); err != nil || !reflect.DeepEqual(got, upload) {
+				t.Errorf("GetUpload() = %+v, %v, want %+v", got, err, upload)
+			}
+			if _, err := s.GetUpload(uuid.New().String()); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("GetUpload(unknown) error = %v, want ErrNotFound", err)
+			}
+
+			if got, total, err := s.ListTransactions(uploadID, 10, 0); err != nil || total != 2 || !reflect.DeepEqual(got, transactions) {
+				t.Errorf("ListTransactions() = %+v, %d, %v, want %+v", got, total, err, transactions)
+			}
+			if _, total, err := s.ListTransactions("", 10, 0); err != nil || total < 2 {
+				t.Errorf("ListTransactions(all) total = %d, %v, want at least 2", total, err)
+			}
+
+			committed := models.Upload{ID: uuid.New().String(), Filename: "ledger.csv", RowsAccepted: 1, RowsRejected: 1, CreatedAt: created}
+			batch := storage.UploadBatch{
+				Upload:       committed,
+				Records:      []models.Record{{ID: uuid.New().String(), UploadID: committed.ID, Data: map[string]interface{}{"amount": 10.0}, CreatedAt: created}},
+				Transactions: []models.Transaction{{ID: uuid.New().String(), UploadID: committed.ID, Amount: 10, Credit: 10, CreatedAt: created}},
+				Rejections:   []models.RowError{{Sheet: "Sheet1", Row: 3, Code: "empty_row", Message: "empty row"}},
+			}
+			mustNoError(t, storage.CommitUpload(context.Background(), s, batch))
+			committed.Status = models.UploadStatusCommitted
+			if got, err := s.GetUpload(committed.ID); err != nil || !reflect.DeepEqual(got, committed) {
+				t.Errorf("GetUpload(committed) = %+v, %v, want %+v", got, err, committed)
+			}
+			if got, err := s.GetByUploadID(committed.ID); err != nil || !reflect.DeepEqual(got, batch.Records) {
+				t.Errorf("GetByUploadID(committed) = %+v, %v", got, err)
+			}
+			if _, total, err := s.ListRejections(committed.ID, 10, 0); err != nil || total != 1 {
+				t.Errorf("ListRejections(committed) total = %d, %v, want 1", total, err)
+			}
+
+			streamed := models.Upload{ID: uuid.New().String(), Filename: "streamed.csv", RowsAccepted: 2, CreatedAt: created}
+			streamedRecords := []models.Record{
+				{ID: uuid.New().String(), UploadID: streamed.ID, Row: 1, Data: map[string]interface{}{"amount": 1.0}, CreatedAt: created},
+				{ID: uuid.New().String(), UploadID: streamed.ID, Row: 2, Data: map[string]interface{}{"amount": 2.0}, CreatedAt: created},
+			}
+			streamedTransactions := []models.Transaction{
+				{ID: uuid.New().String(), UploadID: streamed.ID, Row: 1, Amount: 1, Credit: 1, CreatedAt: created},
+				{ID: uuid.New().String(), UploadID: streamed.ID, Row: 2, Amount: 2, Credit: 2, CreatedAt: created},
+			}
+			streamedRejections := []models.RowError{
+				{Sheet: "Sheet1", Row: 4, Code: "empty_row", Message: "empty row"},
+				{Sheet: "Sheet1", Row: 5, Code: "empty_row", Message: "empty row"},
+			}
+			writer, err := s.BeginUpload(context.Background(), streamed.ID)
+			mustNoError(t, err)
+			mustNoError(t, writer.WriteRecords(streamedRecords[:1]))
+			mustNoError(t, writer.WriteTransactions(streamedTransactions[:1]))
+			mustNoError(t, writer.WriteRejections(streamedRejections[:1]))
+			if got, err := s.GetByUploadID(streamed.ID); err != nil || len(got) != 0 {
+				t.Errorf("GetByUploadID(uncommitted) = %d records, %v, want none", len(got), err)
+			}
+			if _, total, err := s.ListTransactions(streamed.ID, 10, 0); err != nil || total != 0 {
+				t.Errorf("ListTransactions(uncommitted) total = %d, %v, want 0", total, err)
+			}
+			mustNoError(t, writer.Commit(storage.UploadBatch{
+				Upload:       streamed,
+				Records:      streamedRecords[1:],
+				Transactions: streamedTransactions[1:],
+				Rejections:   streamedRejections[1:],
+			}))
+			mustNoError(t, writer.Abort())
+			if got, err := s.GetByUploadID(streamed.ID); err != nil || !reflect.DeepEqual(got, streamedRecords) {
+				t.Errorf("GetByUploadID(streamed) = %+v, %v, want %+v", got, err, streamedRecords)
+			}
+			if got, _, err := s.ListTransactions(streamed.ID, 10, 0); err != nil || !reflect.DeepEqual(got, streamedTransactions) {
+				t.Errorf("ListTransactions(streamed) = %+v, %v, want %+v", got, err, streamedTransactions)
+			}
+			if got, _, err := s.ListRejections(streamed.ID, 10, 0); err != nil || !reflect.DeepEqual(got, streamedRejections) {
+				t.Errorf("ListRejections(streamed) = %+v, %v, want %+v", got, err, streamedRejections)
+			}
+
+			aborted := uuid.New().String()
+			writer, err = s.BeginUpload(context.Background(), aborted)
+			mustNoError(t, err)
+			mustNoError(t, writer.WriteRecords([]models.Record{{ID: uuid.New().String(), UploadID: aborted, Data: map[string]interface{}{}, CreatedAt: created}}))
+			mustNoError(t, writer.Abort())
+			if err := writer.Commit(storage.UploadBatch{Upload: models.Upload{ID: aborted}}); err == nil {
+				t.Error("Commit() after Abort succeeded")
+			}
+			if got, err := s.GetByUploadID(aborted); err != nil || len(got) != 0 {
+				t.Errorf("GetByUploadID(aborted) = %d records, %v, want none", len(got), err)
+			}
+			if _, err := s.GetUpload(aborted); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("GetUpload(aborted) error = %v, want ErrNotFound", err)
+			}
+
+			// A writer stops once the request it works for is gone, and still
+			// cleans up after it.
+			cancelled := uuid.New().String()
+			ctx, cancel := context.WithCancel(context.Background())
+			writer, err = s.BeginUpload(ctx, cancelled)
+			mustNoError(t, err)
+			mustNoError(t, writer.WriteRecords([]models.Record{{ID: uuid.New().String(), UploadID: cancelled, Data: map[string]interface{}{}, CreatedAt: created}}))
+			cancel()
+			if err := writer.WriteRecords([]models.Record{{ID: uuid.New().String(), UploadID: cancelled, Data: map[string]interface{}{}, CreatedAt: created}}); !errors.Is(err, context.Canceled) {
+				t.Errorf("WriteRecords() after cancel error = %v, want context.Canceled", err)
+			}
+			if err := writer.Commit(storage.UploadBatch{Upload: models.Upload{ID: cancelled, CreatedAt: created}}); !errors.Is(err, context.Canceled) {
+				t.Errorf("Commit() after cancel error = %v, want context.Canceled", err)
+			}
+			mustNoError(t, writer.Abort())
+			if _, err := s.GetUpload(cancelled); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("GetUpload(cancelled) error = %v, want ErrNotFound", err)
+			}
+
+			rolledBackAt := created.Add(time.Hour)
+			got, err := s.RollbackUpload(committed.ID, rolledBackAt)
+			if err != nil || got.Status != models.UploadStatusRolledBack || got.RolledBackAt == nil || !got.RolledBackAt.Equal(rolledBackAt) {
+				t.Errorf("RollbackUpload() = %+v, %v, want it rolled back", got, err)
+			}
+			if records, err := s.GetByUploadID(committed.ID); err != nil || len(records) != 0 {
+				t.Errorf("GetByUploadID(rolled back) = %d records, %v, want none", len(records), err)
+			}
+			if count, err := s.CountByUploadID(committed.ID); err != nil || count != 0 {
+				t.Errorf("CountByUploadID(rolled back) = %d, %v, want 0", count, err)
+			}
+			if _, total, err := s.ListTransactions(committed.ID, 10, 0); err != nil || total != 0 {
+				t.Errorf("ListTransactions(rolled back) total = %d, %v, want 0", total, err)
+			}
+			if _, total, err := s.ListRejections(committed.ID, 10, 0); err != nil || total != 1 {
+				t.Errorf("ListRejections(rolled back) total = %d, %v, want the report kept", total, err)
+			}
+			if records, err := s.GetByUploadID(uploadID); err != nil || len(records) != 2 {
+				t.Errorf("GetByUploadID(other upload) = %d records, %v, want 2", len(records), err)
+			}
+			if got, err := s.GetUpload(committed.ID); err != nil || got.Status != models.UploadStatusRolledBack {
+				t.Errorf("GetUpload(rolled back) = %+v, %v", got, err)
+			}
+			if _, err := s.RollbackUpload(committed.ID, rolledBackAt); !errors.Is(err, storage.ErrRolledBack) {
+				t.Errorf("RollbackUpload() again error = %v, want ErrRolledBack", err)
+			}
+			if _, err := s.RollbackUpload(uuid.New().String(), rolledBackAt); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("RollbackUpload(unknown) error = %v, want ErrNotFound", err)
+			}
+
+			// Uploads of a window of time no other run uses.
+			window := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC).Add(rand.N(1000000 * time.Hour))
+			listed := make([]models.Upload, 3)
+			for i := range listed {
+				listed[i] = models.Upload{ID: uuid.New().String(), Filename: "listed.csv", CreatedAt: window.Add(time.Duration(i) * time.Minute)}
+				mustNoError(t, storage.CommitUpload(context.Background(), s, storage.UploadBatch{Upload: listed[i]}))
+			}
+			_, err = s.RollbackUpload(listed[1].ID, window)
+			mustNoError(t, err)
+
+			listTests := []struct {
+				filter    storage.UploadFilter
+				limit     int
+				offset    int
+				wantIDs   []string
+				wantTotal int
+			}{
+				{filter: storage.UploadFilter{From: window, To: window.Add(time.Hour)}, limit: 10, wantIDs: []string{listed[2].ID, listed[1].ID, listed[0].ID}, wantTotal: 3},
+				{filter: storage.UploadFilter{From: window, To: window.Add(time.Hour)}, limit: 1, offset: 1, wantIDs: []string{listed[1].ID}, wantTotal: 3},
+				{filter: storage.UploadFilter{From: window.Add(time.Minute), To: window.Add(2 * time.Minute)}, limit: 10, wantIDs: []string{listed[1].ID}, wantTotal: 1},
+				{filter: storage.UploadFilter{Status: models.UploadStatusCommitted, From: window, To: window.Add(time.Hour)}, limit: 10, wantIDs: []string{listed[2].ID, listed[0].ID}, wantTotal: 2},
+			}
+			for _, lt := range listTests {
+				uploads, total, err := s.ListUploads(lt.filter, lt.limit, lt.offset)
+				ids := make([]string, len(uploads))
+				for i, upload := range uploads {
+					ids[i] = upload.ID
+				}
+				if err != nil || total != lt.wantTotal || !reflect.DeepEqual(ids, lt.wantIDs) {
+					t.Errorf("ListUploads(%+v, %d, %d) = %v, %d, %v, want %v, %d", lt.filter, lt.limit, lt.offset, ids, total, err, lt.wantIDs, lt.wantTotal)
+				}
+			}
+		})
+	}
+}
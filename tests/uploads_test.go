@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joelovien/go-xlsx-api/internal/api/handlers"
+	"github.com/joelovien/go-xlsx-api/internal/mapping"
+	"github.com/joelovien/go-xlsx-api/internal/models"
+	"github.com/joelovien/go-xlsx-api/internal/schema"
+	"github.com/joelovien/go-xlsx-api/internal/storage"
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/rs/zerolog"
+)
+
+func TestUploadsHandler_Rollback(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	uploadsHandler := handlers.NewUploadsHandler(store, &logger)
+
+	upload := func(content string) string {
+		w := httptest.NewRecorder()
+		uploadHandler.Handle(w, newUploadRequest(t, "people.csv", []byte(content), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+		}
+		var response models.UploadResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.UploadID
+	}
+	kept := upload("Name,Age\nJohn,30\n")
+	rolledBack := upload("Name,Age\nJane,25\n,\nBob,40\n")
+
+	tests := []struct {
+		name           string
+		uploadID       string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "rolled back", uploadID: rolledBack, expectedStatus: http.StatusOK},
+		{name: "twice", uploadID: rolledBack, expectedStatus: http.StatusConflict, expectedCode: "already_rolled_back"},
+		{name: "unknown upload", uploadID: "missing", expectedStatus: http.StatusNotFound, expectedCode: "not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withURLParam(httptest.NewRequest(http.MethodPost, "/v1/uploads/"+tt.uploadID+"/rollback", nil), "id", tt.uploadID)
+			w := httptest.NewRecorder()
+			uploadsHandler.Rollback(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Status = %d, want %d, body %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				var response models.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Code != tt.expectedCode {
+					t.Errorf("Code = %q, want %q", response.Code, tt.expectedCode)
+				}
+				return
+			}
+
+			var upload models.Upload
+			if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if upload.Status != models.UploadStatusRolledBack || upload.RolledBackAt == nil || upload.RowsAccepted != 2 {
+				t.Errorf("Upload = %+v, want it rolled back with its counts", upload)
+			}
+		})
+	}
+
+	if records, _ := store.GetByUploadID(rolledBack); len(records) != 0 {
+		t.Errorf("Rolled back upload still has %d records", len(records))
+	}
+	if records, _ := store.GetByUploadID(kept); len(records) != 1 {
+		t.Errorf("Other upload has %d records, want 1", len(records))
+	}
+	if upload, _ := store.GetUpload(kept); upload.Status != models.UploadStatusCommitted {
+		t.Errorf("Other upload status = %q, want committed", upload.Status)
+	}
+
+	// The row errors of a rolled back upload stay available, unlike those
+	// of an upload that never existed.
+	rejectionsHandler := handlers.NewRejectionsHandler(store, &logger)
+	for _, tt := range []struct {
+		uploadID       string
+		expectedStatus int
+		expectedTotal  int
+	}{
+		{uploadID: rolledBack, expectedStatus: http.StatusOK, expectedTotal: 1},
+		{uploadID: "missing", expectedStatus: http.StatusNotFound},
+	} {
+		req := withURLParam(httptest.NewRequest(http.MethodGet, "/v1/uploads/"+tt.uploadID+"/errors", nil), "id", tt.uploadID)
+		w := httptest.NewRecorder()
+		rejectionsHandler.Handle(w, req)
+		if w.Code != tt.expectedStatus {
+			t.Errorf("Errors of %s status = %d, want %d", tt.uploadID, w.Code, tt.expectedStatus)
+			continue
+		}
+		if tt.expectedStatus != http.StatusOK {
+			continue
+		}
+		var response models.ListRowErrorsResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Total != tt.expectedTotal || len(response.Errors) != tt.expectedTotal {
+			t.Errorf("Errors of rolled back upload = %+v, want %d", response.Errors, tt.expectedTotal)
+		}
+	}
+}
+
+func TestUploadsHandler_List(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	handler := handlers.NewUploadsHandler(store, &logger)
+
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"upload-1", "upload-2", "upload-3"} {
+		upload := models.Upload{ID: id, Filename: id + ".csv", CreatedAt: day.AddDate(0, 0, i)}
+		if err := storage.CommitUpload(context.Background(), store, storage.UploadBatch{Upload: upload}); err != nil {
+			t.Fatalf("CommitUpload() error = %v", err)
+		}
+	}
+	if _, err := store.RollbackUpload("upload-2", day); err != nil {
+		t.Fatalf("RollbackUpload() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []string
+		expectedTotal  int
+	}{
+		{name: "newest first", query: "", expectedStatus: http.StatusOK, expectedIDs: []string{"upload-3", "upload-2", "upload-1"}, expectedTotal: 3},
+		{name: "paginated", query: "?limit=1&offset=1", expectedStatus: http.StatusOK, expectedIDs: []string{"upload-2"}, expectedTotal: 3},
+		{name: "by status", query: "?status=rolled_back", expectedStatus: http.StatusOK, expectedIDs: []string{"upload-2"}, expectedTotal: 1},
+		{name: "dates include the last day", query: "?from=2024-03-02&to=2024-03-03", expectedStatus: http.StatusOK, expectedIDs: []string{"upload-3", "upload-2"}, expectedTotal: 2},
+		{name: "times", query: "?from=2024-03-01T10:00:00Z&to=2024-03-03T09:00:00Z", expectedStatus: http.StatusOK, expectedIDs: []string{"upload-2"}, expectedTotal: 1},
+		{name: "invalid status", query: "?status=pending", expectedStatus: http.StatusBadRequest},
+		{name: "invalid date", query: "?from=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=-1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.List(w, httptest.NewRequest(http.MethodGet, "/v1/uploads"+tt.query, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Status = %d, want %d, body %s", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				var response models.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Code != "invalid_parameter" {
+					t.Errorf("Code = %q, want invalid_parameter", response.Code)
+				}
+				return
+			}
+
+			var response models.ListUploadsResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			ids := make([]string, len(response.Uploads))
+			for i, upload := range response.Uploads {
+				ids[i] = upload.ID
+			}
+			if !reflect.DeepEqual(ids, tt.expectedIDs) || response.Total != tt.expectedTotal {
+				t.Errorf("Uploads = %v of %d, want %v of %d", ids, response.Total, tt.expectedIDs, tt.expectedTotal)
+			}
+		})
+	}
+}
+
+func TestUploadsHandler_Get_Counts(t *testing.T) {
+	logger := zerolog.Nop()
+	store := storage.NewMemoryStorage()
+	uploadHandler := handlers.NewUploadHandler(store, xlsx.NewParser(2), schema.NewRegistry(), mapping.NewRegistry(), 10, &logger)
+	uploadsHandler := handlers.NewUploadsHandler(store, &logger)
+
+	content := []byte("Name,Age\nJohn,30\n,\nJane,25\n")
+	w := httptest.NewRecorder()
+	uploadHandler.Handle(w, newUploadRequest(t, "people.csv", content, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload status = %d, body %s", w.Code, w.Body.String())
+	}
+	var response models.UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	get := func() models.UploadDetail {
+		req := withURLParam(httptest.NewRequest(http.MethodGet, "/v1/uploads/"+response.UploadID, nil), "id", response.UploadID)
+		w := httptest.NewRecorder()
+		uploadsHandler.Get(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Status = %d, body %s", w.Code, w.Body.String())
+		}
+		var detail models.UploadDetail
+		if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return detail
+	}
+
+	detail := get()
+	if detail.Filename != "people.csv" || detail.Size != int64(len(content)) || detail.Status != models.UploadStatusCommitted {
+		t.Errorf("Upload = %+v, want people.csv of %d bytes, committed", detail.Upload, len(content))
+	}
+	if detail.RowsAccepted != 2 || detail.RowsRejected != 1 || detail.StoredRecords != 2 || detail.CreatedAt.IsZero() {
+		t.Errorf("Counts = %d accepted, %d rejected, %d stored, want 2, 1, 2", detail.RowsAccepted, detail.RowsRejected, detail.StoredRecords)
+	}
+
+	if _, err := store.RollbackUpload(response.UploadID, time.Now()); err != nil {
+		t.Fatalf("RollbackUpload() error = %v", err)
+	}
+	if detail := get(); detail.StoredRecords != 0 || detail.RowsAccepted != 2 {
+		t.Errorf("After rollback = %d stored, %d accepted, want 0, 2", detail.StoredRecords, detail.RowsAccepted)
+	}
+}
@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+	"github.com/xuri/excelize/v2"
+)
+
+// buildHiddenWorkbook writes a sheet with a hidden scratch row and helper
+// column, followed by a hidden sheet.
+func buildHiddenWorkbook(t *testing.T) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	rows := [][]interface{}{
+		{"Name", "Amount", "Helper"},
+		{"John", 10, "x"},
+		{"scratch", 99, "y"},
+		{"Jane", 20, "z"},
+	}
+	for r, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, r+1)
+		row := row
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+	if _, err := f.NewSheet("Lookup"); err != nil {
+		t.Fatalf("Failed to add sheet: %v", err)
+	}
+	if err := f.SetSheetRow("Lookup", "A1", &[]interface{}{"Code"}); err != nil {
+		t.Fatalf("Failed to write row: %v", err)
+	}
+	if err := f.SetSheetRow("Lookup", "A2", &[]interface{}{"A"}); err != nil {
+		t.Fatalf("Failed to write row: %v", err)
+	}
+
+	for _, err := range []error{
+		f.SetRowVisible("Sheet1", 3, false),
+		f.SetColVisible("Sheet1", "C", false),
+		f.SetSheetVisible("Lookup", false),
+	} {
+		if err != nil {
+			t.Fatalf("Failed to hide cells: %v", err)
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	return buf.Bytes()
+}
+
+const odsHiddenContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+    xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+    xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+    xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+    xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+  <office:automatic-styles>
+    <style:style style:name="ta1" style:family="table"><style:table-properties table:display="true"/></style:style>
+    <style:style style:name="ta2" style:family="table"><style:table-properties table:display="false"/></style:style>
+  </office:automatic-styles>
+  <office:body>
+    <office:spreadsheet>
+      <table:table table:name="Sheet1" table:style-name="ta1">
+        <table:table-column table:number-columns-repeated="2"/>
+        <table:table-column table:visibility="collapse"/>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Name</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Amount</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>Helper</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>John</text:p></table:table-cell>
+          <table:table-cell office:value-type="float" office:value="10"><text:p>10</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>x</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row table:visibility="collapse">
+          <table:table-cell office:value-type="string"><text:p>scratch</text:p></table:table-cell>
+          <table:table-cell office:value-type="float" office:value="99"><text:p>99</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>y</text:p></table:table-cell>
+        </table:table-row>
+        <table:table-row>
+          <table:table-cell office:value-type="string"><text:p>Jane</text:p></table:table-cell>
+          <table:table-cell office:value-type="float" office:value="20"><text:p>20</text:p></table:table-cell>
+          <table:table-cell office:value-type="string"><text:p>z</text:p></table:table-cell>
+        </table:table-row>
+      </table:table>
+      <table:table table:name="Lookup" table:style-name="ta2">
+        <table:table-row><table:table-cell office:value-type="string"><text:p>Code</text:p></table:table-cell></table:table-row>
+        <table:table-row><table:table-cell office:value-type="string"><text:p>A</text:p></table:table-cell></table:table-row>
+      </table:table>
+    </office:spreadsheet>
+  </office:body>
+</office:document-content>`
+
+func TestParser_ParseWithOptions_Hidden(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+	workbook := buildHiddenWorkbook(t)
+	ods := buildODS(t, odsHiddenContent)
+
+	excluded := []map[string]interface{}{
+		{"Transaction Index": 1, "Name": "John", "Amount": int64(10)},
+		{"Transaction Index": 2, "Name": "Jane", "Amount": int64(20)},
+	}
+
+	tests := []struct {
+		name              string
+		content           []byte
+		opts              xlsx.Options
+		wantSheets        []string
+		wantRows          []map[string]interface{}
+		wantTagged        []bool
+		wantHiddenRows    int
+		wantHiddenColumns []string
+	}{
+		{
+			name:       "included",
+			content:    workbook,
+			opts:       xlsx.Options{Sheets: xlsx.SheetSelector{All: true}},
+			wantSheets: []string{"Sheet1", "Lookup"},
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "John", "Amount": int64(10), "Helper": "x"},
+				{"Transaction Index": 2, "Name": "scratch", "Amount": int64(99), "Helper": "y"},
+				{"Transaction Index": 3, "Name": "Jane", "Amount": int64(20), "Helper": "z"},
+				{"Transaction Index": 1, "Code": "A"},
+			},
+			wantTagged: []bool{false, false, false, false},
+		},
+		{
+			name:              "excluded",
+			content:           workbook,
+			opts:              xlsx.Options{Hidden: xlsx.HiddenExclude, Sheets: xlsx.SheetSelector{All: true}},
+			wantSheets:        []string{"Sheet1"},
+			wantRows:          excluded,
+			wantTagged:        []bool{false, false},
+			wantHiddenRows:    1,
+			wantHiddenColumns: []string{"Helper"},
+		},
+		{
+			name:              "excluded display strings",
+			content:           workbook,
+			opts:              xlsx.Options{Hidden: xlsx.HiddenExclude, Values: xlsx.ValuesString},
+			wantSheets:        []string{"Sheet1"},
+			wantRows:          []map[string]interface{}{{"Transaction Index": 1, "Name": "John", "Amount": "10"}, {"Transaction Index": 2, "Name": "Jane", "Amount": "20"}},
+			wantTagged:        []bool{false, false},
+			wantHiddenRows:    1,
+			wantHiddenColumns: []string{"Helper"},
+		},
+		{
+			name:       "hidden sheet named explicitly",
+			content:    workbook,
+			opts:       xlsx.Options{Hidden: xlsx.HiddenExclude, Sheets: xlsx.SheetSelector{Refs: []string{"Lookup"}}},
+			wantSheets: []string{"Lookup"},
+			wantRows:   []map[string]interface{}{{"Transaction Index": 1, "Code": "A"}},
+			wantTagged: []bool{false},
+		},
+		{
+			name:       "tagged",
+			content:    workbook,
+			opts:       xlsx.Options{Hidden: xlsx.HiddenTag},
+			wantSheets: []string{"Sheet1"},
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Name": "John", "Amount": int64(10), "Helper": "x"},
+				{"Transaction Index": 2, "Name": "scratch", "Amount": int64(99), "Helper": "y"},
+				{"Transaction Index": 3, "Name": "Jane", "Amount": int64(20), "Helper": "z"},
+			},
+			wantTagged:        []bool{false, true, false},
+			wantHiddenRows:    1,
+			wantHiddenColumns: []string{"Helper"},
+		},
+		{
+			name:              "ods excluded",
+			content:           ods,
+			opts:              xlsx.Options{Format: xlsx.FormatODS, Hidden: xlsx.HiddenExclude, Sheets: xlsx.SheetSelector{All: true}},
+			wantSheets:        []string{"Sheet1"},
+			wantRows:          excluded,
+			wantTagged:        []bool{false, false},
+			wantHiddenRows:    1,
+			wantHiddenColumns: []string{"Helper"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			var sheets []string
+			for _, sheet := range result.Sheets {
+				sheets = append(sheets, sheet.Name)
+			}
+			if !reflect.DeepEqual(sheets, tt.wantSheets) {
+				t.Errorf("Sheets = %v, want %v", sheets, tt.wantSheets)
+			}
+			first := result.Sheets[0]
+			if first.HiddenRows != tt.wantHiddenRows || !reflect.DeepEqual(first.HiddenColumns, tt.wantHiddenColumns) {
+				t.Errorf("HiddenRows, HiddenColumns = %d, %v, want %d, %v", first.HiddenRows, first.HiddenColumns, tt.wantHiddenRows, tt.wantHiddenColumns)
+			}
+
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantRows))
+			}
+			for i, want := range tt.wantRows {
+				if got := result.Records[i].Data; !reflect.DeepEqual(got, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, got, want)
+				}
+				if got := result.Records[i].Hidden; got != tt.wantTagged[i] {
+					t.Errorf("Record %d hidden = %v, want %v", i, got, tt.wantTagged[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_HiddenSheets(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Name"}); err != nil {
+		t.Fatalf("Failed to write row: %v", err)
+	}
+	// The active sheet cannot be hidden, so another one takes its place
+	// until Sheet1 is hidden.
+	if _, err := f.NewSheet("Visible"); err != nil {
+		t.Fatalf("Failed to add sheet: %v", err)
+	}
+	f.SetActiveSheet(1)
+	if err := f.SetSheetVisible("Sheet1", false); err != nil {
+		t.Fatalf("Failed to hide sheet: %v", err)
+	}
+	if err := f.DeleteSheet("Visible"); err != nil {
+		t.Fatalf("Failed to delete sheet: %v", err)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+
+	_, err = xlsx.NewParser(2).ParseWithOptions(context.Background(), bytes.NewReader(buf.Bytes()), "upload-1", xlsx.Options{Hidden: xlsx.HiddenExclude})
+	if !errors.Is(err, xlsx.ErrNoSheets) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrNoSheets", err)
+	}
+
+	result, err := xlsx.NewParser(2).ParseWithOptions(context.Background(), bytes.NewReader(buildHiddenWorkbook(t)), "upload-1", xlsx.Options{
+		Hidden: xlsx.HiddenTag,
+		Sheets: xlsx.SheetSelector{All: true},
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+	}
+	if result.Sheets[0].Hidden || !result.Sheets[1].Hidden {
+		t.Errorf("Sheet hidden = %v, %v, want false, true", result.Sheets[0].Hidden, result.Sheets[1].Hidden)
+	}
+}
@@ -0,0 +1,406 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/joelovien/go-xlsx-api/internal/xlsx"
+)
+
+// biffRecord encodes a BIFF record header and body.
+func biffRecord(typ uint16, fields ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, field := range fields {
+		binary.Write(&body, binary.LittleEndian, field)
+	}
+	record := binary.LittleEndian.AppendUint16(nil, typ)
+	record = binary.LittleEndian.AppendUint16(record, uint16(body.Len()))
+	return append(record, body.Bytes()...)
+}
+
+// biffString encodes an XLUnicodeString with 8-bit characters.
+func biffString(s string) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, uint16(len(s)))
+	return append(append(b, 0), s...)
+}
+
+func biffWideString(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := binary.LittleEndian.AppendUint16(nil, uint16(len(units)))
+	b = append(b, 1)
+	for _, u := range units {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func biffCell(row, col, xf uint16) []uint16 {
+	return []uint16{row, col, xf}
+}
+
+// hyperlinkString encodes a HyperlinkString: a character count including
+// the terminating NUL, and the UTF-16 characters.
+func hyperlinkString(s string) []byte {
+	text := utf16z(s)
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(text)/2)), text...)
+}
+
+// urlMoniker encodes a URL moniker: its CLSID, a byte count and the URL.
+func urlMoniker(url string) []byte {
+	b := []byte{0xE0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B}
+	text := utf16z(url)
+	return append(binary.LittleEndian.AppendUint32(b, uint32(len(text))), text...)
+}
+
+func utf16z(s string) []byte {
+	var b []byte
+	for _, u := range append(utf16.Encode([]rune(s)), 0) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// biffHyperlink encodes an HLINK record for a cell range with the given
+// flags and parts of the hyperlink object.
+func biffHyperlink(rows, cols [2]uint16, flags uint32, parts ...[]byte) []byte {
+	return biffRecord(0x01B8, rows, cols, make([]byte, 16), uint32(2), flags, bytes.Join(parts, nil))
+}
+
+// buildXLSWorkbook writes a BIFF8 workbook stream with a statement sheet
+// exercising the cell record types, hyperlinks and a comment, and a second
+// plain sheet.
+func buildXLSWorkbook(t *testing.T, encrypted bool) []byte {
+	t.Helper()
+
+	sheetBOF := biffRecord(0x0809, uint16(0x0600), uint16(0x0010), uint16(0), uint16(0), uint32(0), uint32(0))
+	eof := biffRecord(0x000A)
+
+	statement := bytes.Join([][]byte{
+		sheetBOF,
+		biffRecord(0x00FD, biffCell(0, 0, 0), uint32(0)),
+		biffRecord(0x00FD, biffCell(2, 0, 0), uint32(1)),
+		biffRecord(0x00FD, biffCell(2, 1, 0), uint32(2)),
+		biffRecord(0x00FD, biffCell(2, 2, 0), uint32(3)),
+		biffRecord(0x00FD, biffCell(2, 3, 0), uint32(4)),
+		biffRecord(0x00FD, biffCell(2, 4, 0), uint32(5)),
+		// Custom date format, shared string split over a CONTINUE record,
+		// a double, an integer RK and a boolean.
+		biffRecord(0x0203, biffCell(3, 0, 1), float64(45293)),
+		biffRecord(0x00FD, biffCell(3, 1, 0), uint32(6)),
+		biffRecord(0x0203, biffCell(3, 2, 0), float64(-12.5)),
+		biffRecord(0x027E, biffCell(3, 3, 0), uint32(3<<2|0x02)),
+		biffRecord(0x0205, biffCell(3, 4, 0), uint8(1), uint8(0)),
+		// Built-in date format, formula strings, MULRK and a formula bool.
+		biffRecord(0x027E, biffCell(4, 0, 2), uint32(45294<<2|0x02)),
+		biffRecord(0x0006, biffCell(4, 1, 0), []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF}, uint16(0), uint32(0), uint16(0)),
+		biffRecord(0x0207, biffWideString("computed")),
+		biffRecord(0x00BD, uint16(4), uint16(2), uint16(0), uint32(1234<<2|0x03), uint16(0), uint32(0x3FE00000), uint16(3)),
+		biffRecord(0x0006, biffCell(4, 4, 0), []byte{1, 0, 0, 0, 0, 0, 0xFF, 0xFF}, uint16(0), uint32(0), uint16(0)),
+		// Inline label and an error value.
+		biffRecord(0x0204, biffCell(5, 1, 0), biffString("error")),
+		biffRecord(0x0205, biffCell(5, 2, 0), uint8(0x07), uint8(1)),
+		// A comment whose text box spills into a second, wide CONTINUE
+		// record, then an embedded chart substream.
+		biffRecord(0x005D, uint16(0x0015), uint16(0x0012), uint16(0x0019), uint16(1), make([]byte, 14)),
+		biffRecord(0x01B6, uint16(0x0212), uint16(0), make([]byte, 6), uint16(len("Reviewer:\nChecked against PO")), uint16(16), uint16(0), make([]byte, 4)),
+		biffRecord(0x003C, uint8(0), []byte("Reviewer:\nChecked ")),
+		biffRecord(0x003C, uint8(1), utf16.Encode([]rune("against PO"))),
+		biffRecord(0x003C, make([]byte, 16)),
+		sheetBOF,
+		eof,
+		// An absolute URL with a display name, a relative file path and a
+		// location in the workbook.
+		biffHyperlink([2]uint16{3, 3}, [2]uint16{1, 1}, 0x17, hyperlinkString("Receipt"), urlMoniker("https://example.com/receipts/1")),
+		biffHyperlink([2]uint16{4, 4}, [2]uint16{1, 1}, 0x01,
+			[]byte{0x03, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46},
+			[]byte{1, 0}, binary.LittleEndian.AppendUint32(nil, 15), []byte("receipts/2.pdf\x00"),
+			[]byte{0xFF, 0xFF, 0xAD, 0xDE}, make([]byte, 24)),
+		biffHyperlink([2]uint16{5, 5}, [2]uint16{1, 1}, 0x08, hyperlinkString("Other!A1")),
+		biffRecord(0x001C, biffCell(3, 2, 0), uint16(1), biffString("Reviewer"), uint8(0)),
+		eof,
+	}, nil)
+
+	other := bytes.Join([][]byte{
+		sheetBOF,
+		biffRecord(0x00FD, biffCell(0, 0, 0), uint32(7)),
+		biffRecord(0x00FD, biffCell(1, 0, 0), uint32(8)),
+		eof,
+	}, nil)
+
+	var sst bytes.Buffer
+	binary.Write(&sst, binary.LittleEndian, []uint32{9, 9})
+	for _, s := range []string{"Account statement", "Date", "Memo", "Amount", "Fee", "Cleared"} {
+		sst.Write(biffString(s))
+	}
+	// "Café latte" starts with 8-bit characters and continues as UTF-16.
+	sst.Write([]byte{10, 0, 0})
+	sst.WriteString("Caf\xe9 ")
+	var sstContinue bytes.Buffer
+	sstContinue.WriteByte(1)
+	for _, u := range utf16.Encode([]rune("latte")) {
+		binary.Write(&sstContinue, binary.LittleEndian, u)
+	}
+	sstContinue.Write(biffString("Name"))
+	sstContinue.Write(biffString("John"))
+
+	globals := func(statementOffset, otherOffset uint32) []byte {
+		records := [][]byte{
+			biffRecord(0x0809, uint16(0x0600), uint16(0x0005), uint16(0), uint16(0), uint32(0), uint32(0)),
+			biffRecord(0x0022, uint16(0)),
+			biffRecord(0x041E, uint16(164), biffString("dd/mm/yyyy")),
+			biffRecord(0x00E0, uint16(0), uint16(0), make([]byte, 16)),
+			biffRecord(0x00E0, uint16(0), uint16(164), make([]byte, 16)),
+			biffRecord(0x00E0, uint16(0), uint16(14), make([]byte, 16)),
+			biffRecord(0x0085, statementOffset, uint8(0), uint8(0), uint8(len("Statement")), uint8(0), []byte("Statement")),
+			biffRecord(0x0085, otherOffset, uint8(0), uint8(0), uint8(len("Other")), uint8(0), []byte("Other")),
+			biffRecord(0x00FC, sst.Bytes()),
+			biffRecord(0x003C, sstContinue.Bytes()),
+			eof,
+		}
+		if encrypted {
+			records = append(records[:1], append([][]byte{biffRecord(0x002F, uint16(1))}, records[1:]...)...)
+		}
+		return bytes.Join(records, nil)
+	}
+
+	size := uint32(len(globals(0, 0)))
+	stream := bytes.Join([][]byte{globals(size, size+uint32(len(statement))), statement, other}, nil)
+	return buildCompoundFile(t, "Workbook", stream)
+}
+
+// buildCompoundFile writes a version 3 compound file holding one stream. The
+// stream is padded past the mini stream cutoff so that it is stored in
+// regular sectors: sector 0 holds the FAT, sector 1 the directory and the
+// stream follows. Up to two more names for the same stream can be listed
+// before it in the directory.
+func buildCompoundFile(t *testing.T, name string, stream []byte, before ...string) []byte {
+	t.Helper()
+
+	const (
+		sectorSize = 512
+		endOfChain = 0xFFFFFFFE
+		freeSect   = 0xFFFFFFFF
+		fatSect    = 0xFFFFFFFD
+		noStream   = 0xFFFFFFFF
+	)
+
+	data := append([]byte(nil), stream...)
+	for len(data) < 4096 || len(data)%sectorSize != 0 {
+		data = append(data, 0)
+	}
+	sectors := len(data) / sectorSize
+	if 2+sectors > sectorSize/4 {
+		t.Fatalf("Stream of %d bytes does not fit in one FAT sector", len(stream))
+	}
+
+	header := make([]byte, sectorSize)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	le := binary.LittleEndian
+	le.PutUint16(header[24:], 0x003E)
+	le.PutUint16(header[26:], 0x0003)
+	le.PutUint16(header[28:], 0xFFFE)
+	le.PutUint16(header[30:], 9)
+	le.PutUint16(header[32:], 6)
+	le.PutUint32(header[44:], 1)
+	le.PutUint32(header[48:], 1)
+	le.PutUint32(header[56:], 4096)
+	le.PutUint32(header[60:], endOfChain)
+	le.PutUint32(header[68:], endOfChain)
+	le.PutUint32(header[76:], 0)
+	for i := 1; i < 109; i++ {
+		le.PutUint32(header[76+i*4:], freeSect)
+	}
+
+	fat := make([]byte, sectorSize)
+	for i := 0; i < sectorSize/4; i++ {
+		le.PutUint32(fat[i*4:], freeSect)
+	}
+	le.PutUint32(fat[0:], fatSect)
+	le.PutUint32(fat[4:], endOfChain)
+	for i := 0; i < sectors; i++ {
+		next := uint32(3 + i)
+		if i == sectors-1 {
+			next = endOfChain
+		}
+		le.PutUint32(fat[(2+i)*4:], next)
+	}
+
+	if len(before) > 2 {
+		t.Fatalf("%d extra streams do not fit in one directory sector", len(before))
+	}
+
+	entry := func(name string, typ byte, left, child, start uint32, size uint64) []byte {
+		e := make([]byte, 128)
+		units := utf16.Encode([]rune(name))
+		for i, u := range units {
+			le.PutUint16(e[i*2:], u)
+		}
+		if name != "" {
+			le.PutUint16(e[64:], uint16(len(units)*2+2))
+		}
+		e[66] = typ
+		e[67] = 1
+		le.PutUint32(e[68:], left)
+		le.PutUint32(e[72:], noStream)
+		le.PutUint32(e[76:], child)
+		le.PutUint32(e[116:], start)
+		le.PutUint64(e[120:], size)
+		return e
+	}
+	// Each stream has the one listed before it as its left sibling, which
+	// readers visit first.
+	names := append([]string{name}, before...)
+	entries := [][]byte{entry("Root Entry", 5, noStream, 1, endOfChain, 0)}
+	for i, n := range names {
+		left := uint32(noStream)
+		if i+1 < len(names) {
+			left = uint32(i + 2)
+		}
+		entries = append(entries, entry(n, 2, left, noStream, 2, uint64(len(data))))
+	}
+	for len(entries) < 4 {
+		entries = append(entries, entry("", 0, noStream, noStream, 0, 0))
+	}
+	directory := bytes.Join(entries, nil)
+
+	return bytes.Join([][]byte{header, fat, directory, data}, nil)
+}
+
+func TestParser_ParseWithOptions_XLS(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+	xls := buildXLSWorkbook(t, false)
+
+	tests := []struct {
+		name       string
+		opts       xlsx.Options
+		wantHeader int
+		wantRows   []map[string]interface{}
+		wantSource []int
+	}{
+		{
+			name:       "typed",
+			opts:       xlsx.Options{Format: xlsx.FormatXLS},
+			wantHeader: 3,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Memo": "Café latte", "Amount": -12.5, "Fee": int64(3), "Cleared": true},
+				{"Transaction Index": 2, "Date": "2024-01-03", "Memo": "computed", "Amount": 12.34, "Fee": 0.5, "Cleared": false},
+				{"Transaction Index": 3, "Date": nil, "Memo": "error", "Amount": "#DIV/0!", "Fee": nil, "Cleared": nil},
+			},
+			wantSource: []int{4, 5, 6},
+		},
+		{
+			name:       "strings with detected format",
+			opts:       xlsx.Options{Format: xlsx.FormatAuto, Values: xlsx.ValuesString},
+			wantHeader: 3,
+			wantRows: []map[string]interface{}{
+				{"Transaction Index": 1, "Date": "2024-01-02", "Memo": "Café latte", "Amount": "-12.5", "Fee": "3", "Cleared": "TRUE"},
+				{"Transaction Index": 2, "Date": "2024-01-03", "Memo": "computed", "Amount": "12.34", "Fee": "0.5", "Cleared": "FALSE"},
+				{"Transaction Index": 3, "Date": nil, "Memo": "error", "Amount": "#DIV/0!", "Fee": nil, "Cleared": nil},
+			},
+			wantSource: []int{4, 5, 6},
+		},
+		{
+			name:       "second sheet",
+			opts:       xlsx.Options{Format: xlsx.FormatXLS, Sheets: xlsx.SheetSelector{Refs: []string{"Other"}}},
+			wantHeader: 1,
+			wantRows:   []map[string]interface{}{{"Transaction Index": 1, "Name": "John"}},
+			wantSource: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(xls), "upload-1", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+
+			if got := result.Sheets[0].HeaderRow; got != tt.wantHeader {
+				t.Errorf("HeaderRow = %d, want %d", got, tt.wantHeader)
+			}
+			if len(result.Records) != len(tt.wantRows) {
+				t.Fatalf("Got %d records, want %d", len(result.Records), len(tt.wantRows))
+			}
+			for i, want := range tt.wantRows {
+				record := result.Records[i]
+				if !reflect.DeepEqual(record.Data, want) {
+					t.Errorf("Record %d data = %#v, want %#v", i, record.Data, want)
+				}
+				if record.SourceRow != tt.wantSource[i] {
+					t.Errorf("Record %d SourceRow = %d, want %d", i, record.SourceRow, tt.wantSource[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_XLSErrors(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "encrypted", content: buildXLSWorkbook(t, true)},
+		{name: "missing workbook stream", content: buildCompoundFile(t, "WordDocument", make([]byte, 16))},
+		{name: "biff5", content: buildCompoundFile(t, "Book", make([]byte, 16))},
+		{name: "truncated", content: buildXLSWorkbook(t, false)[:1024]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", xlsx.Options{Format: xlsx.FormatXLS})
+			if err == nil {
+				t.Fatal("ParseWithOptions() expected error, got nil")
+			}
+			if errors.Is(err, xlsx.ErrUnsupportedFormat) {
+				t.Errorf("ParseWithOptions() error = %v, want an xls read error", err)
+			}
+		})
+	}
+}
+
+func TestParser_ParseWithOptions_XLSStreams(t *testing.T) {
+	parser := xlsx.NewParser(2)
+	ctx := context.Background()
+
+	workbook := buildXLSWorkbook(t, false)
+	stream := workbook[3*512:]
+
+	// A shared string table claiming four billion strings must be read as
+	// far as its records go rather than allocated up front.
+	inflated := bytes.Clone(stream)
+	sstHeader := append(binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, 9), 9), biffString("Account statement")...)
+	at := bytes.Index(inflated, sstHeader)
+	if at < 0 {
+		t.Fatal("Shared string table not found in workbook stream")
+	}
+	binary.LittleEndian.PutUint32(inflated[at+4:], 0xFFFFFFFF)
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "book stream listed first", content: buildCompoundFile(t, "Workbook", stream, "Book")},
+		{name: "inflated string count", content: buildCompoundFile(t, "Workbook", inflated)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.ParseWithOptions(ctx, bytes.NewReader(tt.content), "upload-1", xlsx.Options{Format: xlsx.FormatXLS})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error = %v", err)
+			}
+			if len(result.Records) != 3 || result.Records[0].Data["Memo"] != "Café latte" {
+				t.Errorf("Records = %+v, want the statement rows", result.Records)
+			}
+		})
+	}
+}